@@ -0,0 +1,51 @@
+package app
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+const forwardedForHeader = "X-Forwarded-For"
+
+// clientIP returns the IP address of the client that sent r.
+// Requests relayed by a trusted proxy are attributed to the address the proxies recorded in X-Forwarded-For:
+// the header is read from right to left, skipping trusted proxies, since only the entries appended by
+// trusted proxies can be believed. Any other request is attributed to its remote address.
+func (app *RESTApp) clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	addr, err := netip.ParseAddr(host)
+	if err != nil || !app.isTrustedProxy(addr) {
+		return host
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values(forwardedForHeader), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+
+		addr = hop
+		if !app.isTrustedProxy(addr) {
+			break
+		}
+	}
+	return addr.Unmap().String()
+}
+
+// isTrustedProxy reports whether addr belongs to a proxy set by WithTrustedProxies.
+func (app *RESTApp) isTrustedProxy(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range app.trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -10,6 +10,9 @@ import (
 type APIError struct {
 	StatusCode  int    `json:"status_code"`
 	Description string `json:"error"`
+
+	// Path is the JSONPath of the value of the document the error is about, if any.
+	Path string `json:"path,omitempty"`
 }
 
 // Implement the error interface.
@@ -35,16 +38,111 @@ var (
 		Description: "the password is invalid",
 	}
 
+	ErrInvalidRefreshToken = APIError{
+		StatusCode:  http.StatusUnauthorized,
+		Description: "the refresh token is invalid",
+	}
+
+	ErrUnsupportedDigest = APIError{
+		StatusCode:  http.StatusBadRequest,
+		Description: "the digest algorithm is unsupported",
+	}
+
+	ErrUnsupportedEncoding = APIError{
+		StatusCode:  http.StatusBadRequest,
+		Description: "the digest encoding is unsupported",
+	}
+
+	ErrUnsupportedStringMode = APIError{
+		StatusCode:  http.StatusBadRequest,
+		Description: "the string mode is unsupported",
+	}
+
+	ErrUnsupportedLiteralPolicy = APIError{
+		StatusCode:  http.StatusBadRequest,
+		Description: "the literal policy is unsupported",
+	}
+
+	ErrUnsupportedOperation = APIError{
+		StatusCode:  http.StatusBadRequest,
+		Description: "the operation is unsupported",
+	}
+
+	ErrInvalidSelector = APIError{
+		StatusCode:  http.StatusBadRequest,
+		Description: "the selector is invalid",
+	}
+
 	ErrUnsupportedValueType = APIError{
 		StatusCode:  http.StatusUnprocessableEntity,
 		Description: "the value type is unsupported",
 	}
 
+	ErrNumberOutOfRange = APIError{
+		StatusCode:  http.StatusUnprocessableEntity,
+		Description: "the number is out of range",
+	}
+
+	ErrNoValues = APIError{
+		StatusCode:  http.StatusUnprocessableEntity,
+		Description: "there are no numbers to aggregate",
+	}
+
+	ErrNonFiniteResult = APIError{
+		StatusCode:  http.StatusUnprocessableEntity,
+		Description: "the result is not a finite number",
+	}
+
+	ErrRequestTooLarge = APIError{
+		StatusCode:  http.StatusRequestEntityTooLarge,
+		Description: "the request body is too large",
+	}
+
+	ErrBatchTooLarge = APIError{
+		StatusCode:  http.StatusRequestEntityTooLarge,
+		Description: "the batch has too many documents",
+	}
+
+	ErrDocumentTooLarge = APIError{
+		StatusCode:  http.StatusRequestEntityTooLarge,
+		Description: "the document has too many values",
+	}
+
+	ErrDocumentTooDeep = APIError{
+		StatusCode:  http.StatusUnprocessableEntity,
+		Description: "the document is nested too deeply",
+	}
+
+	ErrCredentialsMismatch = APIError{
+		StatusCode:  http.StatusUnauthorized,
+		Description: "the credentials do not match",
+	}
+
 	ErrUnauthorized = APIError{
 		StatusCode:  http.StatusUnauthorized,
 		Description: "unauthorized",
 	}
 
+	ErrForbidden = APIError{
+		StatusCode:  http.StatusForbidden,
+		Description: "the token lacks the required scope",
+	}
+
+	ErrTooManyAttempts = APIError{
+		StatusCode:  http.StatusTooManyRequests,
+		Description: "too many failed attempts, try again later",
+	}
+
+	ErrRateLimited = APIError{
+		StatusCode:  http.StatusTooManyRequests,
+		Description: "too many requests, slow down",
+	}
+
+	ErrDiscoveryUnavailable = APIError{
+		StatusCode:  http.StatusNotFound,
+		Description: "no public keys are configured to verify tokens with",
+	}
+
 	ErrInternal = APIError{
 		StatusCode:  http.StatusInternalServerError,
 		Description: "internal server error",
@@ -53,6 +151,14 @@ var (
 
 // translate service errors into transport errors.
 func toTransportError(err error) error {
+	var pathErr *service.PathError
+	if errors.As(err, &pathErr) {
+		if apiErr, ok := toTransportError(pathErr.Err).(APIError); ok {
+			apiErr.Path = pathErr.Path
+			return apiErr
+		}
+	}
+
 	if errors.Is(err, service.ErrUsernameInvalid) {
 		return ErrInvalidUsername
 	}
@@ -61,6 +167,20 @@ func toTransportError(err error) error {
 		return ErrInvalidPassword
 	}
 
+	if errors.Is(err, service.ErrCredentialsMismatch) {
+		return ErrCredentialsMismatch
+	}
+
+	if errors.Is(err, service.ErrTooManyAttempts) {
+		return ErrTooManyAttempts
+	}
+
+	if errors.Is(err, service.ErrRefreshTokenInvalid) ||
+		errors.Is(err, service.ErrRefreshTokenExpired) ||
+		errors.Is(err, service.ErrRefreshTokenReused) {
+		return ErrInvalidRefreshToken
+	}
+
 	if errors.Is(err, service.ErrTokenInvalid) {
 		return ErrUnauthorized
 	}
@@ -77,8 +197,167 @@ func toTransportError(err error) error {
 		return ErrUnauthorized
 	}
 
+	if errors.Is(err, service.ErrTokenRevoked) {
+		return ErrUnauthorized
+	}
+
+	if errors.Is(err, service.ErrTokenUnknownKey) {
+		return ErrUnauthorized
+	}
+
+	if errors.Is(err, service.ErrTokenInvalidAlgorithm) {
+		return ErrUnauthorized
+	}
+
+	if errors.Is(err, service.ErrTokenNotYetValid) {
+		return ErrUnauthorized
+	}
+
+	if errors.Is(err, service.ErrTokenInvalidIssuedAt) {
+		return ErrUnauthorized
+	}
+
 	if errors.Is(err, service.ErrUnsupportedValueType) {
 		return ErrUnsupportedValueType
 	}
+
+	if errors.Is(err, service.ErrNumberOutOfRange) {
+		return ErrNumberOutOfRange
+	}
+
+	if errors.Is(err, service.ErrNonFiniteResult) {
+		return ErrNonFiniteResult
+	}
+
+	if errors.Is(err, service.ErrDocumentInvalid) {
+		return ErrInvalidRequest
+	}
+
+	if errors.Is(err, service.ErrDocumentTooLarge) {
+		return ErrDocumentTooLarge
+	}
+
+	if errors.Is(err, service.ErrDocumentTooDeep) {
+		return ErrDocumentTooDeep
+	}
+
+	if errors.Is(err, service.ErrUnsupportedDigest) {
+		return ErrUnsupportedDigest
+	}
+
+	if errors.Is(err, service.ErrUnsupportedEncoding) {
+		return ErrUnsupportedEncoding
+	}
+
+	if errors.Is(err, service.ErrUnsupportedStringMode) {
+		return ErrUnsupportedStringMode
+	}
+
+	if errors.Is(err, service.ErrUnsupportedLiteralPolicy) {
+		return ErrUnsupportedLiteralPolicy
+	}
+
+	if errors.Is(err, service.ErrSelectorInvalid) {
+		return ErrInvalidSelector
+	}
+
+	if errors.Is(err, service.ErrUnsupportedOperation) {
+		return ErrUnsupportedOperation
+	}
+
+	if errors.Is(err, service.ErrNoValues) {
+		return ErrNoValues
+	}
 	return ErrInternal
 }
+
+// OAuthError is an error of the OAuth 2.0 endpoints, encoded as in RFC 6749 section 5.2.
+type OAuthError struct {
+	StatusCode  int    `json:"-"`
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+// Implement the error interface.
+func (e OAuthError) Error() string {
+	return e.Code
+}
+
+var (
+	// Enumerate possible OAuth errors.
+
+	ErrOAuthInvalidRequest = OAuthError{
+		StatusCode:  http.StatusBadRequest,
+		Code:        "invalid_request",
+		Description: "the request is missing a parameter or is malformed",
+	}
+
+	ErrOAuthInvalidClient = OAuthError{
+		StatusCode:  http.StatusUnauthorized,
+		Code:        "invalid_client",
+		Description: "the client authentication failed",
+	}
+
+	ErrOAuthInvalidGrant = OAuthError{
+		StatusCode:  http.StatusBadRequest,
+		Code:        "invalid_grant",
+		Description: "the credentials or refresh token are invalid",
+	}
+
+	ErrOAuthUnsupportedGrantType = OAuthError{
+		StatusCode:  http.StatusBadRequest,
+		Code:        "unsupported_grant_type",
+		Description: "the grant type is unsupported",
+	}
+
+	ErrOAuthInvalidScope = OAuthError{
+		StatusCode:  http.StatusBadRequest,
+		Code:        "invalid_scope",
+		Description: "the requested scope is invalid or exceeds the scope granted",
+	}
+
+	// RFC 6749 has no code for throttling: the grant is refused, and the status tells the client to back off.
+	ErrOAuthTooManyAttempts = OAuthError{
+		StatusCode:  http.StatusTooManyRequests,
+		Code:        "invalid_grant",
+		Description: "too many failed attempts, try again later",
+	}
+
+	ErrOAuthServerError = OAuthError{
+		StatusCode:  http.StatusInternalServerError,
+		Code:        "server_error",
+		Description: "internal server error",
+	}
+)
+
+// translate service errors into OAuth errors.
+func toOAuthError(err error) error {
+	if errors.Is(err, service.ErrClientCredentialsMismatch) {
+		return ErrOAuthInvalidClient
+	}
+
+	if errors.Is(err, service.ErrUsernameInvalid) ||
+		errors.Is(err, service.ErrPasswordInvalid) {
+		return ErrOAuthInvalidRequest
+	}
+
+	if errors.Is(err, service.ErrCredentialsMismatch) ||
+		errors.Is(err, service.ErrRefreshTokenInvalid) ||
+		errors.Is(err, service.ErrRefreshTokenExpired) ||
+		errors.Is(err, service.ErrRefreshTokenReused) {
+		return ErrOAuthInvalidGrant
+	}
+
+	if errors.Is(err, service.ErrTooManyAttempts) {
+		return ErrOAuthTooManyAttempts
+	}
+
+	if errors.Is(err, service.ErrUnsupportedGrantType) {
+		return ErrOAuthUnsupportedGrantType
+	}
+
+	if errors.Is(err, service.ErrScopeInvalid) {
+		return ErrOAuthInvalidScope
+	}
+	return ErrOAuthServerError
+}
@@ -2,7 +2,14 @@ package app
 
 import (
 	"encoding/json"
+	"errors"
+	"math"
+	"mime"
 	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/alesr/code-assignment/internal/service"
 )
 
 func writeJSONError(w http.ResponseWriter, e error) {
@@ -19,7 +26,65 @@ func writeJSONError(w http.ResponseWriter, e error) {
 	json.NewEncoder(w).Encode(ErrInternal)
 }
 
+// writeOAuthError writes e as an RFC 6749 error response, or a server_error if it isn't an OAuthError.
+func writeOAuthError(w http.ResponseWriter, e error) {
+	oauthError, ok := e.(OAuthError)
+	if !ok {
+		oauthError = ErrOAuthServerError
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+
+	// A client that tried to authenticate must be told how (RFC 6749 section 5.2).
+	if oauthError.StatusCode == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", `Basic realm="oauth"`)
+	}
+
+	w.WriteHeader(oauthError.StatusCode)
+	json.NewEncoder(w).Encode(oauthError)
+}
+
 func writeJSON(w http.ResponseWriter, v any) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(v)
 }
+
+// writeJSONStatus writes v with the given status code.
+func writeJSONStatus(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// isNDJSON reports whether the request body is newline-delimited JSON, one document per line.
+func isNDJSON(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && mediaType == ndjsonContentType
+}
+
+// queryOrHeader returns the query parameter named param, or the header named header if the former is empty.
+func queryOrHeader(r *http.Request, param, header string) string {
+	if v := r.URL.Query().Get(param); v != "" {
+		return v
+	}
+	return r.Header.Get(header)
+}
+
+// cacheControlMaxAge returns a Cache-Control value letting any cache keep a response for d.
+func cacheControlMaxAge(d time.Duration) string {
+	return "public, max-age=" + strconv.Itoa(int(d.Seconds()))
+}
+
+// setRetryAfter tells the client when to try again if err is a lockout.
+func setRetryAfter(w http.ResponseWriter, err error) {
+	var lockoutErr *service.LockoutError
+	if errors.As(err, &lockoutErr) {
+		w.Header().Set("Retry-After", ceilSeconds(lockoutErr.RetryAfter))
+	}
+}
+
+// ceilSeconds formats d as a whole number of seconds, rounded up.
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
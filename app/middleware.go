@@ -0,0 +1,87 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+
+	"go.uber.org/zap"
+
+	"github.com/alesr/code-assignment/internal/service"
+	"github.com/go-chi/chi"
+)
+
+// protect returns the middlewares that make a route require a valid bearer token granting scopes:
+//
+//	router.With(app.protect(service.ScopeSum)...).Post("/sum", app.sumHandler)
+func (app *RESTApp) protect(scopes ...string) chi.Middlewares {
+	return chi.Middlewares{app.authenticate, app.requireScopes(scopes...)}
+}
+
+// protectLimited returns the middlewares of protect, rate limited with the limit of route:
+// requests failing authentication are counted per client IP, and the others per token subject,
+// whether or not they were granted scopes.
+func (app *RESTApp) protectLimited(route string, scopes ...string) chi.Middlewares {
+	return chi.Middlewares{
+		app.rateLimitFailedAuthentication(route),
+		app.authenticate,
+		app.rateLimit(route),
+		app.requireScopes(scopes...),
+	}
+}
+
+// authenticate verifies the bearer token of the request before the next handler runs,
+// and stores the Principal it was issued to in the request context.
+// Requests without a valid token get ErrUnauthorized, and are reported to rateLimitFailedAuthentication.
+func (app *RESTApp) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokenString := extractTokenFromHeader(r.Header.Get("Authorization"))
+		if tokenString == "" {
+			app.logger.Warn("missing token")
+			reportAuthFailure(r.Context())
+			writeJSONError(w, ErrUnauthorized)
+			return
+		}
+
+		claims, err := app.svc.VerifyToken(r.Context(), tokenString)
+		if err != nil {
+			app.logger.Warn("could not verify token", zap.Error(err))
+			reportAuthFailure(r.Context())
+			writeJSONError(w, ErrUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(contextWithPrincipal(r.Context(), newPrincipal(claims))))
+	})
+}
+
+// requireScopes only lets requests through whose principal was granted every one of scopes.
+// It must run after authenticate: requests without a principal get ErrUnauthorized,
+// and those whose principal lacks a scope ErrForbidden.
+func (app *RESTApp) requireScopes(scopes ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal, ok := PrincipalFromContext(r.Context())
+			if !ok {
+				app.logger.Error("route requires scopes without authentication")
+				writeJSONError(w, ErrUnauthorized)
+				return
+			}
+
+			if !principal.HasScopes(scopes...) {
+				app.logger.Warn("token lacks the required scope",
+					zap.String("subject", principal.Subject),
+					zap.Strings("required", scopes),
+					zap.Strings("granted", principal.Scopes),
+				)
+
+				// Tell the client which scope to ask for (RFC 6750 section 3.1).
+				w.Header().Set("WWW-Authenticate",
+					fmt.Sprintf(`Bearer error="insufficient_scope", scope=%q`, service.FormatScopes(scopes)))
+				writeJSONError(w, ErrForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
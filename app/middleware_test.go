@@ -0,0 +1,140 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alesr/code-assignment/internal/service"
+	"github.com/go-chi/chi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestProtect(t *testing.T) {
+	testCases := []struct {
+		name                 string
+		givenAuthorization   string
+		givenClaims          *service.Claims
+		givenVerifyError     error
+		expectedStatus       int
+		expectedError        APIError
+		expectedAuthenticate string
+		expectedPrincipal    *Principal
+	}{
+		{
+			name:               "granted",
+			givenAuthorization: "Bearer foo-token",
+			givenClaims: func() *service.Claims {
+				claims := service.Claims{ClientID: "foo-client", Scope: "read sum", Custom: map[string]any{"tenant": "acme"}}
+				claims.Subject = "foo-user"
+				claims.Id = "foo-jti"
+				return &claims
+			}(),
+			expectedStatus: http.StatusNoContent,
+			expectedPrincipal: &Principal{
+				Subject:  "foo-user",
+				ClientID: "foo-client",
+				Scopes:   []string{"read", "sum"},
+				TokenID:  "foo-jti",
+				Claims:   map[string]any{"tenant": "acme"},
+			},
+		},
+		{
+			name:                 "missing scope",
+			givenAuthorization:   "Bearer foo-token",
+			givenClaims:          &service.Claims{Scope: "read"},
+			expectedStatus:       http.StatusForbidden,
+			expectedError:        ErrForbidden,
+			expectedAuthenticate: `Bearer error="insufficient_scope", scope="sum"`,
+		},
+		{
+			name:               "invalid token",
+			givenAuthorization: "Bearer foo-token",
+			givenVerifyError:   service.ErrTokenRevoked,
+			expectedStatus:     http.StatusUnauthorized,
+			expectedError:      ErrUnauthorized,
+		},
+		{
+			name:           "missing token",
+			expectedStatus: http.StatusUnauthorized,
+			expectedError:  ErrUnauthorized,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockSvc := &service.MockService{
+				VerifyTokenFunc: func(ctx context.Context, token string) (*service.Claims, error) {
+					return tc.givenClaims, tc.givenVerifyError
+				},
+			}
+
+			router := chi.NewRouter()
+
+			app := &RESTApp{
+				logger: zap.NewNop(),
+				svc:    mockSvc,
+			}
+
+			var observedPrincipal *Principal
+			router.With(app.protect(service.ScopeSum)...).Get("/foo", func(w http.ResponseWriter, r *http.Request) {
+				observedPrincipal, _ = PrincipalFromContext(r.Context())
+				w.WriteHeader(http.StatusNoContent)
+			})
+
+			req, err := http.NewRequest(http.MethodGet, "/foo", http.NoBody)
+			require.NoError(t, err)
+
+			req.Header.Set("Authorization", tc.givenAuthorization)
+
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.expectedStatus, w.Code)
+			assert.Equal(t, tc.expectedAuthenticate, w.Header().Get("WWW-Authenticate"))
+			assert.Equal(t, tc.expectedPrincipal, observedPrincipal)
+
+			if tc.expectedStatus != http.StatusNoContent {
+				var respErr APIError
+				require.NoError(t, json.NewDecoder(w.Body).Decode(&respErr))
+
+				assert.Equal(t, tc.expectedError, respErr)
+			}
+		})
+	}
+}
+
+func TestRequireScopes_withoutAuthenticate(t *testing.T) {
+	router := chi.NewRouter()
+
+	app := &RESTApp{logger: zap.NewNop()}
+
+	router.With(app.requireScopes(service.ScopeSum)).Get("/foo", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	req, err := http.NewRequest(http.MethodGet, "/foo", http.NoBody)
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestPrincipalFromContext(t *testing.T) {
+	_, ok := PrincipalFromContext(context.TODO())
+	assert.False(t, ok)
+
+	given := &Principal{Subject: "foo"}
+
+	observed, ok := PrincipalFromContext(contextWithPrincipal(context.TODO(), given))
+	require.True(t, ok)
+	assert.Equal(t, given, observed)
+}
@@ -1,5 +1,13 @@
 package app
 
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/alesr/code-assignment/internal/service"
+)
+
 type authenticateRequest struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
@@ -17,13 +25,297 @@ func (c *authenticateRequest) validate() error {
 }
 
 type authenticaResponse struct {
-	AccessToken string `json:"access_token"`
-	TokenType   string `json:"token_type"`
-	ExpiresIn   int64  `json:"expired_in"`
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expired_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+}
+
+func newAuthenticaResponse(token *service.Token) authenticaResponse {
+	return authenticaResponse{
+		AccessToken:  token.AccessToken,
+		TokenType:    token.TokenType,
+		ExpiresIn:    token.ExpiresIn,
+		RefreshToken: token.RefreshToken,
+	}
+}
+
+// oauthTokenResponse is the successful response of the token endpoint (RFC 6749 section 5.1).
+type oauthTokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+}
+
+func newOAuthTokenResponse(token *service.Token) oauthTokenResponse {
+	return oauthTokenResponse{
+		AccessToken:  token.AccessToken,
+		TokenType:    token.TokenType,
+		ExpiresIn:    token.ExpiresIn,
+		RefreshToken: token.RefreshToken,
+		Scope:        token.Scope,
+	}
+}
+
+// introspectionResponse is the response of the introspection endpoint (RFC 7662 section 2.2).
+// Inactive tokens are described by active alone.
+type introspectionResponse struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	Subject   string `json:"sub,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	ExpiresAt int64  `json:"exp,omitempty"`
+	IssuedAt  int64  `json:"iat,omitempty"`
+	Audience  string `json:"aud,omitempty"`
+	Issuer    string `json:"iss,omitempty"`
+	TokenID   string `json:"jti,omitempty"`
+}
+
+func newIntrospectionResponse(claims *service.Claims) introspectionResponse {
+	return introspectionResponse{
+		Active:    true,
+		Scope:     claims.Scope,
+		Subject:   claims.Subject,
+		ClientID:  claims.ClientID,
+		TokenType: "Bearer",
+		ExpiresAt: claims.ExpiresAt,
+		IssuedAt:  claims.IssuedAt,
+		Audience:  claims.Audience,
+		Issuer:    claims.Issuer,
+		TokenID:   claims.Id,
+	}
+}
+
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+func (c *refreshRequest) validate() error {
+	if c.RefreshToken == "" {
+		return ErrInvalidRequest
+	}
+	return nil
+}
+
+type logoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type revokeRequest struct {
+	TokenID string `json:"jti"`
+
+	// ExpiresAt is the Unix time the token expires at, if known.
+	ExpiresAt int64 `json:"expires_at"`
+}
+
+func (c *revokeRequest) validate() error {
+	if c.TokenID == "" {
+		return ErrInvalidRequest
+	}
+	return nil
+}
+
+func (c *revokeRequest) expiresAt() time.Time {
+	if c.ExpiresAt == 0 {
+		return time.Time{}
+	}
+	return time.Unix(c.ExpiresAt, 0)
+}
+
+// sumEnvelopeRequest wraps the document of a sum along with the selectors of the values summed,
+// for clients that would rather not put them in the URL.
+type sumEnvelopeRequest struct {
+	Document json.RawMessage `json:"document"`
+	Include  []string        `json:"include"`
+	Exclude  []string        `json:"exclude"`
+}
+
+func (c *sumEnvelopeRequest) validate() error {
+	if len(c.Document) == 0 {
+		return ErrInvalidRequest
+	}
+	return nil
+}
+
+type sumBatchResponse struct {
+	// Results are the results of the documents, in the order they were sent.
+	Results []sumItemResponse `json:"results"`
+}
+
+// sumItemResponse is the sum of one of the documents of a request, or the error that kept it from being summed.
+type sumItemResponse struct {
+	Sum         string                  `json:"sum,omitempty"`
+	Algorithm   string                  `json:"algorithm,omitempty"`
+	Encoding    string                  `json:"encoding,omitempty"`
+	Explanation *sumExplanationResponse `json:"explanation,omitempty"`
+
+	Error *APIError `json:"error,omitempty"`
+}
+
+// newSumItemResponse returns the response for the result of a document, or for err if it is not nil.
+func newSumItemResponse(result *service.SumResult, err error) sumItemResponse {
+	if err != nil {
+		apiErr, ok := err.(APIError)
+		if !ok {
+			apiErr, ok = toTransportError(err).(APIError)
+		}
+		if !ok {
+			apiErr = ErrInternal
+		}
+
+		// Explained sums tell what became of every value, the rejected ones included.
+		var explainedErr *service.ExplainedError
+		if errors.As(err, &explainedErr) {
+			return sumItemResponse{Error: &apiErr, Explanation: newSumExplanationResponse(explainedErr.Explanation)}
+		}
+		return sumItemResponse{Error: &apiErr}
+	}
+
+	sum := newSumResponse(result)
+	return sumItemResponse{
+		Sum:         sum.Sum,
+		Algorithm:   sum.Algorithm,
+		Encoding:    sum.Encoding,
+		Explanation: sum.Explanation,
+	}
+}
+
+func newSumBatchResponse(items []service.SumBatchItem) sumBatchResponse {
+	resp := sumBatchResponse{Results: make([]sumItemResponse, 0, len(items))}
+	for _, item := range items {
+		resp.Results = append(resp.Results, newSumItemResponse(item.Result, item.Err))
+	}
+	return resp
+}
+
+// sumLineResponse is the result of a line of an NDJSON /sum request, written as a line of the response.
+type sumLineResponse struct {
+	// Line is the number of the line of the request, counting from 1, blank lines included.
+	Line int `json:"line"`
+	sumItemResponse
+}
+
+type aggregateResponse struct {
+	// Results are the digests of the results, by operation.
+	Results   map[service.Operation]string `json:"results"`
+	Algorithm string                       `json:"algorithm"`
+	Encoding  string                       `json:"encoding"`
 }
 
-type sumRequest any
+func newAggregateResponse(result *service.AggregateResult) aggregateResponse {
+	return aggregateResponse{
+		Results:   result.Hashes,
+		Algorithm: result.Algorithm,
+		Encoding:  result.Encoding,
+	}
+}
 
 type sumResponse struct {
-	Sum string `json:"sum"`
+	Sum       string `json:"sum"`
+	Algorithm string `json:"algorithm"`
+	Encoding  string `json:"encoding"`
+
+	Explanation *sumExplanationResponse `json:"explanation,omitempty"`
+}
+
+func newSumResponse(sum *service.SumResult) sumResponse {
+	return sumResponse{
+		Sum:         sum.Hash,
+		Algorithm:   sum.Algorithm,
+		Encoding:    sum.Encoding,
+		Explanation: newSumExplanationResponse(sum.Explanation),
+	}
+}
+
+// sumExplanationResponse tells what the sum made of each value of the document, for ?explain=true.
+type sumExplanationResponse struct {
+	Sum    string             `json:"sum"`
+	Count  int                `json:"count"`
+	Values []sumValueResponse `json:"values"`
+}
+
+type sumValueResponse struct {
+	Pointer string          `json:"pointer"`
+	Value   json.RawMessage `json:"value"`
+	Status  string          `json:"status"`
+	Reason  string          `json:"reason,omitempty"`
+}
+
+func newSumExplanationResponse(explanation *service.SumExplanation) *sumExplanationResponse {
+	if explanation == nil {
+		return nil
+	}
+
+	values := make([]sumValueResponse, 0, len(explanation.Values))
+	for _, v := range explanation.Values {
+		values = append(values, sumValueResponse{
+			Pointer: v.Pointer,
+			Value:   json.RawMessage(v.Value),
+			Status:  string(v.Status),
+			Reason:  v.Reason,
+		})
+	}
+
+	return &sumExplanationResponse{
+		Sum:    explanation.Sum,
+		Count:  explanation.Count,
+		Values: values,
+	}
+}
+
+// explainedErrorResponse is the error of a sum asked to explain itself, along with the explanation.
+type explainedErrorResponse struct {
+	APIError
+	Explanation *sumExplanationResponse `json:"explanation"`
+}
+
+type jwkResponse struct {
+	KeyType   string `json:"kty"`
+	Use       string `json:"use"`
+	KeyID     string `json:"kid"`
+	Algorithm string `json:"alg"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+	Y         string `json:"y,omitempty"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+}
+
+type jwksResponse struct {
+	Keys []jwkResponse `json:"keys"`
+}
+
+func newJWKSResponse(keys []service.JWK) jwksResponse {
+	// Always encode an array, even when only HMAC keys are configured.
+	resp := jwksResponse{Keys: make([]jwkResponse, 0, len(keys))}
+
+	for _, key := range keys {
+		resp.Keys = append(resp.Keys, jwkResponse{
+			KeyType:   key.KeyType,
+			Use:       "sig",
+			KeyID:     key.KeyID,
+			Algorithm: key.Algorithm,
+			Curve:     key.Curve,
+			X:         key.X,
+			Y:         key.Y,
+			N:         key.N,
+			E:         key.E,
+		})
+	}
+	return resp
+}
+
+type openIDConfigurationResponse struct {
+	Issuer                            string   `json:"issuer"`
+	JWKSURI                           string   `json:"jwks_uri"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	IntrospectionEndpoint             string   `json:"introspection_endpoint"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
 }
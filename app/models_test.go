@@ -2,6 +2,7 @@ package app
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -48,3 +49,20 @@ func TestAuthenticateRequest_validate(t *testing.T) {
 		})
 	}
 }
+
+func TestRefreshRequest_validate(t *testing.T) {
+	t.Parallel()
+
+	assert.NoError(t, (&refreshRequest{RefreshToken: "foo"}).validate())
+	assert.Equal(t, ErrInvalidRequest, (&refreshRequest{}).validate())
+}
+
+func TestRevokeRequest(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, ErrInvalidRequest, (&revokeRequest{}).validate())
+	assert.NoError(t, (&revokeRequest{TokenID: "foo"}).validate())
+
+	assert.True(t, (&revokeRequest{TokenID: "foo"}).expiresAt().IsZero())
+	assert.Equal(t, time.Unix(1700000000, 0), (&revokeRequest{TokenID: "foo", ExpiresAt: 1700000000}).expiresAt())
+}
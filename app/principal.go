@@ -0,0 +1,61 @@
+package app
+
+import (
+	"context"
+
+	"github.com/alesr/code-assignment/internal/service"
+)
+
+// Principal is who a request was authenticated as, taken from its verified access token.
+type Principal struct {
+	Subject  string
+	ClientID string
+	Scopes   []string
+	TokenID  string
+
+	// Claims are the custom claims of the token, e.g. the tenant or roles of the user.
+	Claims map[string]any
+}
+
+func newPrincipal(claims *service.Claims) *Principal {
+	return &Principal{
+		Subject:  claims.Subject,
+		ClientID: claims.ClientID,
+		Scopes:   service.ParseScopes(claims.Scope),
+		TokenID:  claims.Id,
+		Claims:   claims.Custom,
+	}
+}
+
+// HasScopes reports whether the principal was granted every one of scopes.
+func (p *Principal) HasScopes(scopes ...string) bool {
+	for _, scope := range scopes {
+		if !hasScope(p.Scopes, scope) {
+			return false
+		}
+	}
+	return true
+}
+
+func hasScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+type principalKey struct{}
+
+// PrincipalFromContext returns the principal stored by the authenticate middleware,
+// and false on routes that aren't authenticated.
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok
+}
+
+// contextWithPrincipal returns a copy of ctx carrying p.
+func contextWithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
@@ -0,0 +1,223 @@
+package app
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// defaultRateLimitRoute names the limit of the routes without one of their own in WithRateLimit.
+const defaultRateLimitRoute = "*"
+
+// rateLimitSweepInterval is how often a rateLimiter drops the buckets that have refilled.
+const rateLimitSweepInterval = time.Minute
+
+// RateLimit allows Requests requests per Per. Bursts of up to Requests requests are allowed,
+// after which requests are let through as fast as the bucket refills.
+type RateLimit struct {
+	Requests int
+	Per      time.Duration
+}
+
+// rate returns how many requests the limit allows per second.
+func (l RateLimit) rate() float64 {
+	return float64(l.Requests) / l.Per.Seconds()
+}
+
+// rateLimiter keeps a token bucket per client.
+type rateLimiter struct {
+	limit RateLimit
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	nextSweep time.Time
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+func newRateLimiter(limit RateLimit) *rateLimiter {
+	return &rateLimiter{
+		limit:   limit,
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// rateLimitDecision is the outcome of a request against a rateLimiter.
+type rateLimitDecision struct {
+	allowed   bool
+	remaining int
+
+	// reset is how long until the bucket is full again.
+	reset time.Duration
+
+	// retryAfter is how long until the next request is allowed, for denied requests.
+	retryAfter time.Duration
+}
+
+// take takes a token from the bucket of key, if there is one.
+func (l *rateLimiter) take(key string, now time.Time) rateLimitDecision {
+	return l.decide(key, now, true)
+}
+
+// peek reports whether the bucket of key has a token, without taking it.
+func (l *rateLimiter) peek(key string, now time.Time) rateLimitDecision {
+	return l.decide(key, now, false)
+}
+
+func (l *rateLimiter) decide(key string, now time.Time, take bool) rateLimitDecision {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+
+	capacity, rate := float64(l.limit.Requests), l.limit.rate()
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: capacity, updated: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = math.Min(capacity, b.tokens+now.Sub(b.updated).Seconds()*rate)
+	b.updated = now
+
+	var d rateLimitDecision
+	if b.tokens >= 1 {
+		if take {
+			b.tokens--
+		}
+		d.allowed = true
+	} else {
+		d.retryAfter = seconds((1 - b.tokens) / rate)
+	}
+
+	d.remaining = int(b.tokens)
+	d.reset = seconds((capacity - b.tokens) / rate)
+	return d
+}
+
+// sweep drops the buckets that have refilled at most once per rateLimitSweepInterval,
+// since a new bucket would be just the same.
+func (l *rateLimiter) sweep(now time.Time) {
+	if now.Before(l.nextSweep) {
+		return
+	}
+	l.nextSweep = now.Add(rateLimitSweepInterval)
+
+	for key, b := range l.buckets {
+		if now.Sub(b.updated) >= l.limit.Per {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// routeLimit returns the limit set by WithRateLimit for route, or the default one, and whether there is one.
+func (app *RESTApp) routeLimit(route string) (RateLimit, bool) {
+	limit, ok := app.rateLimits[route]
+	if !ok {
+		limit, ok = app.rateLimits[defaultRateLimitRoute]
+	}
+	return limit, ok && limit.Requests > 0 && limit.Per > 0
+}
+
+// rateLimit returns a middleware limiting the requests to route with the limit set by WithRateLimit.
+// Requests are counted per token subject on routes that run it after authenticate, and per client IP otherwise.
+// Every response carries the RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers,
+// and requests over the limit get ErrRateLimited with a Retry-After header.
+func (app *RESTApp) rateLimit(route string) func(http.Handler) http.Handler {
+	limit, ok := app.routeLimit(route)
+	if !ok {
+		return func(next http.Handler) http.Handler {
+			return next
+		}
+	}
+
+	limiter := newRateLimiter(limit)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := "ip:" + app.clientIP(r)
+			if principal, ok := PrincipalFromContext(r.Context()); ok {
+				key = "sub:" + principal.Subject
+			}
+
+			d := limiter.take(key, time.Now())
+			setRateLimitHeaders(w, limit, d)
+
+			if !d.allowed {
+				app.logger.Warn("rate limit exceeded", zap.String("route", route), zap.String("key", key))
+				writeRateLimited(w, d)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// rateLimitFailedAuthentication returns a middleware counting, per client IP, the requests to route
+// that fail authentication, with the limit set by WithRateLimit. It must run before authenticate,
+// so that the requests of an IP over the limit are turned away before their token is even verified,
+// while those that authenticate are left to rateLimit to count per token subject.
+func (app *RESTApp) rateLimitFailedAuthentication(route string) func(http.Handler) http.Handler {
+	limit, ok := app.routeLimit(route)
+	if !ok {
+		return func(next http.Handler) http.Handler {
+			return next
+		}
+	}
+
+	limiter := newRateLimiter(limit)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := "ip:" + app.clientIP(r)
+
+			if d := limiter.peek(key, time.Now()); !d.allowed {
+				app.logger.Warn("failed authentication limit exceeded", zap.String("route", route), zap.String("key", key))
+
+				setRateLimitHeaders(w, limit, d)
+				writeRateLimited(w, d)
+				return
+			}
+
+			failed := func() {
+				limiter.take(key, time.Now())
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), authFailureKey{}, failed)))
+		})
+	}
+}
+
+type authFailureKey struct{}
+
+// reportAuthFailure counts a failed authentication against the client IP, on routes limited
+// by rateLimitFailedAuthentication.
+func reportAuthFailure(ctx context.Context) {
+	if failed, ok := ctx.Value(authFailureKey{}).(func()); ok {
+		failed()
+	}
+}
+
+func setRateLimitHeaders(w http.ResponseWriter, limit RateLimit, d rateLimitDecision) {
+	w.Header().Set("RateLimit-Limit", strconv.Itoa(limit.Requests))
+	w.Header().Set("RateLimit-Remaining", strconv.Itoa(d.remaining))
+	w.Header().Set("RateLimit-Reset", ceilSeconds(d.reset))
+}
+
+func writeRateLimited(w http.ResponseWriter, d rateLimitDecision) {
+	w.Header().Set("Retry-After", ceilSeconds(d.retryAfter))
+	writeJSONError(w, ErrRateLimited)
+}
@@ -0,0 +1,263 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alesr/code-assignment/internal/service"
+	"github.com/go-chi/chi"
+	"github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestRateLimiter_take(t *testing.T) {
+	limiter := newRateLimiter(RateLimit{Requests: 2, Per: time.Minute})
+	now := time.Now()
+
+	d := limiter.take("foo", now)
+	assert.True(t, d.allowed)
+	assert.Equal(t, 1, d.remaining)
+	assert.Equal(t, 30*time.Second, d.reset)
+
+	d = limiter.take("foo", now)
+	assert.True(t, d.allowed)
+	assert.Equal(t, 0, d.remaining)
+	assert.Equal(t, time.Minute, d.reset)
+
+	d = limiter.take("foo", now)
+	assert.False(t, d.allowed)
+	assert.Equal(t, 30*time.Second, d.retryAfter)
+
+	// Other clients have their own bucket.
+	assert.True(t, limiter.take("bar", now).allowed)
+
+	// Peeking leaves the token in the bucket.
+	assert.True(t, limiter.peek("bar", now).allowed)
+	assert.True(t, limiter.take("bar", now).allowed)
+	assert.False(t, limiter.peek("bar", now).allowed)
+
+	// The bucket refills a token every 30 seconds.
+	assert.True(t, limiter.take("foo", now.Add(30*time.Second)).allowed)
+	assert.False(t, limiter.take("foo", now.Add(30*time.Second)).allowed)
+
+	limiter.sweep(now.Add(2 * time.Minute))
+	assert.Empty(t, limiter.buckets)
+}
+
+func TestRateLimit(t *testing.T) {
+	app := &RESTApp{
+		logger:     zap.NewNop(),
+		rateLimits: map[string]RateLimit{"/foo": {Requests: 1, Per: time.Minute}},
+	}
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}
+
+	router := chi.NewRouter()
+	router.With(app.rateLimit("/foo")).Get("/foo", handler)
+	router.With(app.rateLimit("/bar")).Get("/bar", handler)
+	router.With(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal := &Principal{Subject: r.Header.Get("X-Subject")}
+			next.ServeHTTP(w, r.WithContext(contextWithPrincipal(r.Context(), principal)))
+		})
+	}).With(app.rateLimit("/foo")).Get("/baz", handler)
+
+	request := func(path, remoteAddr, subject string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = remoteAddr
+		if subject != "" {
+			req.Header.Set("X-Subject", subject)
+		}
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := request("/foo", "192.0.2.1:1234", "")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "1", w.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "60", w.Header().Get("RateLimit-Reset"))
+
+	w = request("/foo", "192.0.2.1:5678", "")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "60", w.Header().Get("Retry-After"))
+
+	var respErr APIError
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&respErr))
+	assert.Equal(t, ErrRateLimited, respErr)
+
+	t.Run("other clients", func(t *testing.T) {
+		assert.Equal(t, http.StatusNoContent, request("/foo", "192.0.2.2:1234", "").Code)
+	})
+
+	t.Run("routes without limit", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			w := request("/bar", "192.0.2.1:1234", "")
+			assert.Equal(t, http.StatusNoContent, w.Code)
+			assert.Empty(t, w.Header().Get("RateLimit-Limit"))
+		}
+	})
+
+	t.Run("authenticated requests are counted per subject", func(t *testing.T) {
+		assert.Equal(t, http.StatusNoContent, request("/baz", "192.0.2.3:1234", "foo").Code)
+		assert.Equal(t, http.StatusTooManyRequests, request("/baz", "192.0.2.4:1234", "foo").Code)
+		assert.Equal(t, http.StatusNoContent, request("/baz", "192.0.2.3:1234", "bar").Code)
+	})
+}
+
+func TestRateLimit_failedAuthentication(t *testing.T) {
+	var verified int
+
+	mockSvc := &service.MockService{
+		VerifyTokenFunc: func(ctx context.Context, token string) (*service.Claims, error) {
+			verified++
+			if token != "foo-token" {
+				return nil, service.ErrTokenInvalid
+			}
+			return &service.Claims{StandardClaims: jwt.StandardClaims{Subject: "foo"}, Scope: service.ScopeSum}, nil
+		},
+		SumStreamFunc: func(ctx context.Context, r io.Reader, opts service.SumOptions) (*service.SumResult, error) {
+			return &service.SumResult{Hash: "abcd", Algorithm: "sha256", Encoding: "hex"}, nil
+		},
+	}
+
+	router := chi.NewRouter()
+	NewRESTApp(zap.NewNop(), "8080", router, mockSvc, WithRateLimit("/sum", RateLimit{Requests: 2, Per: time.Minute}))
+
+	request := func(remoteAddr, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/sum", strings.NewReader(`[1]`))
+		req.RemoteAddr = remoteAddr
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, request("192.0.2.1:1234", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, request("192.0.2.1:1234", "forged-token").Code)
+
+	// The IP is over the limit, so its tokens aren't even verified.
+	for _, token := range []string{"", "forged-token", "foo-token"} {
+		w := request("192.0.2.1:1234", token)
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		assert.Equal(t, "30", w.Header().Get("Retry-After"))
+	}
+	assert.Equal(t, 1, verified)
+
+	t.Run("authenticated requests are counted per subject", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, request("192.0.2.2:1234", "foo-token").Code)
+		assert.Equal(t, http.StatusOK, request("192.0.2.3:1234", "foo-token").Code)
+		assert.Equal(t, http.StatusTooManyRequests, request("192.0.2.2:1234", "foo-token").Code)
+
+		// Successful authentications don't count against the IP.
+		assert.Equal(t, http.StatusUnauthorized, request("192.0.2.2:1234", "forged-token").Code)
+	})
+}
+
+func TestRateLimit_defaultRoute(t *testing.T) {
+	app := &RESTApp{
+		logger: zap.NewNop(),
+		rateLimits: map[string]RateLimit{
+			"*":    {Requests: 1, Per: time.Minute},
+			"/foo": {Requests: 5, Per: time.Minute},
+		},
+	}
+
+	assert.Equal(t, 5, rateLimitOf(t, app, "/foo"))
+	assert.Equal(t, 1, rateLimitOf(t, app, "/bar"))
+}
+
+// rateLimitOf returns the RateLimit-Limit of a request to the route, limited by app.
+func rateLimitOf(t *testing.T, app *RESTApp, route string) int {
+	t.Helper()
+
+	router := chi.NewRouter()
+	router.With(app.rateLimit(route)).Get(route, func(w http.ResponseWriter, r *http.Request) {})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, route, nil))
+
+	var limit int
+	require.NoError(t, json.Unmarshal([]byte(w.Header().Get("RateLimit-Limit")), &limit))
+	return limit
+}
+
+func TestClientIP(t *testing.T) {
+	app := &RESTApp{
+		trustedProxies: []netip.Prefix{
+			netip.MustParsePrefix("10.0.0.0/8"),
+			netip.MustParsePrefix("2001:db8::/32"),
+		},
+	}
+
+	testCases := []struct {
+		name            string
+		givenRemoteAddr string
+		givenForwarded  []string
+		expected        string
+	}{
+		{
+			name:            "direct",
+			givenRemoteAddr: "192.0.2.1:1234",
+			expected:        "192.0.2.1",
+		},
+		{
+			name:            "untrusted proxy",
+			givenRemoteAddr: "192.0.2.1:1234",
+			givenForwarded:  []string{"198.51.100.1"},
+			expected:        "192.0.2.1",
+		},
+		{
+			name:            "trusted proxy",
+			givenRemoteAddr: "10.0.0.1:1234",
+			givenForwarded:  []string{"198.51.100.1"},
+			expected:        "198.51.100.1",
+		},
+		{
+			name:            "spoofed entries before the trusted proxies are ignored",
+			givenRemoteAddr: "10.0.0.1:1234",
+			givenForwarded:  []string{"203.0.113.1, 198.51.100.1", "10.0.0.2"},
+			expected:        "198.51.100.1",
+		},
+		{
+			name:            "malformed entry",
+			givenRemoteAddr: "10.0.0.1:1234",
+			givenForwarded:  []string{"198.51.100.1, unknown"},
+			expected:        "10.0.0.1",
+		},
+		{
+			name:            "IPv6",
+			givenRemoteAddr: "[2001:db8::1]:1234",
+			givenForwarded:  []string{"2001:db9::1, 2001:db8::2"},
+			expected:        "2001:db9::1",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tc.givenRemoteAddr
+			for _, v := range tc.givenForwarded {
+				req.Header.Add(forwardedForHeader, v)
+			}
+
+			assert.Equal(t, tc.expected, app.clientIP(req))
+		})
+	}
+}
@@ -1,12 +1,22 @@
 package app
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"crypto/subtle"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"math"
 	"net"
 	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
 	"strings"
+	"time"
 
 	"go.uber.org/zap"
 
@@ -14,24 +24,149 @@ import (
 	"github.com/go-chi/chi"
 )
 
-const bearerPrefix = "Bearer "
+const (
+	bearerPrefix              = "Bearer "
+	digestAlgorithmHeader     = "X-Digest-Algorithm"
+	digestEncodingHeader      = "X-Digest-Encoding"
+	sumStringsHeader          = "X-Sum-Strings"
+	sumBooleansHeader         = "X-Sum-Booleans"
+	sumNullsHeader            = "X-Sum-Nulls"
+	sumExplainHeader          = "X-Sum-Explain"
+	sumEnvelopeHeader         = "X-Sum-Envelope"
+	aggregateOperationsHeader = "X-Aggregate-Operations"
+	adminKeyHeader            = "X-Admin-Key"
+
+	jwksPath                = "/.well-known/jwks.json"
+	openIDConfigurationPath = "/.well-known/openid-configuration"
+	oauthTokenPath          = "/oauth/token"
+	oauthIntrospectPath     = "/oauth/introspect"
+
+	// defaultMaxSumBytes is the default size cap of /sum and /aggregate request bodies.
+	defaultMaxSumBytes = 1 << 20
+
+	// defaultMaxBatchBytes is the default size cap of /sum/batch request bodies.
+	defaultMaxBatchBytes = 16 << 20
+
+	// defaultMaxBatchItems is the default cap of the documents of a /sum/batch request.
+	defaultMaxBatchItems = 1000
+
+	ndjsonContentType = "application/x-ndjson"
+
+	// discoveryMaxAge is how long relying parties may cache the keys and the discovery document.
+	// It should stay well below the time a retired key is kept for verification.
+	discoveryMaxAge = 5 * time.Minute
+)
 
 // RESTApp is the REST server.
 type RESTApp struct {
 	logger     *zap.Logger
 	httpServer *http.Server
 	svc        service.Service
+	adminKey   string
+
+	// baseURL is the public URL of the server, used to build the absolute URLs of the discovery document.
+	baseURL string
+
+	rateLimits     map[string]RateLimit
+	trustedProxies []netip.Prefix
+
+	// maxSumBytes caps the size of /sum and /aggregate request bodies, or of each line of NDJSON /sum requests.
+	maxSumBytes int64
+
+	// maxBatchBytes caps the size of /sum/batch request bodies.
+	maxBatchBytes int64
+
+	// maxBatchItems caps the documents of /sum/batch requests.
+	maxBatchItems int
+}
+
+// Option configures optional behaviour of a RESTApp.
+type Option func(*RESTApp)
+
+// WithAdminKey enables the admin endpoints, authenticated with the given key in the X-Admin-Key header.
+func WithAdminKey(key string) Option {
+	return func(app *RESTApp) {
+		app.adminKey = key
+	}
+}
+
+// WithBaseURL sets the public URL of the server, such as https://auth.example.com,
+// for when it is served behind a proxy. Otherwise the URL is derived from each request.
+func WithBaseURL(url string) Option {
+	return func(app *RESTApp) {
+		app.baseURL = strings.TrimSuffix(url, "/")
+	}
+}
+
+// WithRateLimit limits the requests each client can make to route, a pattern such as "/sum".
+// The route "*" sets the limit of the routes without one of their own. Routes aren't limited by default.
+func WithRateLimit(route string, limit RateLimit) Option {
+	return func(app *RESTApp) {
+		if app.rateLimits == nil {
+			app.rateLimits = make(map[string]RateLimit)
+		}
+		app.rateLimits[route] = limit
+	}
+}
+
+// WithTrustedProxies sets the proxies whose X-Forwarded-For header is believed
+// when telling clients apart for rate limiting and lockouts.
+func WithTrustedProxies(prefixes ...netip.Prefix) Option {
+	return func(app *RESTApp) {
+		app.trustedProxies = prefixes
+	}
+}
+
+// WithMaxSumBytes caps the size of /sum and /aggregate request bodies, or of each line of NDJSON /sum requests.
+// Defaults to defaultMaxSumBytes.
+func WithMaxSumBytes(n int64) Option {
+	return func(app *RESTApp) {
+		app.maxSumBytes = n
+	}
+}
+
+// WithMaxBatchBytes caps the size of /sum/batch request bodies. Defaults to defaultMaxBatchBytes.
+func WithMaxBatchBytes(n int64) Option {
+	return func(app *RESTApp) {
+		app.maxBatchBytes = n
+	}
+}
+
+// WithMaxBatchItems caps the documents of /sum/batch requests. Defaults to defaultMaxBatchItems.
+func WithMaxBatchItems(n int) Option {
+	return func(app *RESTApp) {
+		app.maxBatchItems = n
+	}
 }
 
 // NewRESTApp creates a new RESTApp instance with configured routes.
-func NewRESTApp(logger *zap.Logger, port string, router chi.Router, svc service.Service) *RESTApp {
+func NewRESTApp(logger *zap.Logger, port string, router chi.Router, svc service.Service, opts ...Option) *RESTApp {
 	app := RESTApp{
-		logger: logger,
-		svc:    svc,
+		logger:        logger,
+		svc:           svc,
+		maxSumBytes:   defaultMaxSumBytes,
+		maxBatchBytes: defaultMaxBatchBytes,
+		maxBatchItems: defaultMaxBatchItems,
+	}
+
+	for _, opt := range opts {
+		opt(&app)
 	}
 
-	router.Post("/auth", app.authHandler)
-	router.Post("/sum", app.sumHandler)
+	router.With(app.rateLimit("/auth")).Post("/auth", app.authHandler)
+	router.With(app.rateLimit("/auth/logout")).Post("/auth/logout", app.logoutHandler)
+	router.With(app.rateLimit("/token/refresh")).Post("/token/refresh", app.refreshHandler)
+	router.With(app.rateLimit(oauthTokenPath)).Post(oauthTokenPath, app.oauthTokenHandler)
+	router.With(app.rateLimit(oauthIntrospectPath)).Post(oauthIntrospectPath, app.introspectHandler)
+	router.With(app.protectLimited("/sum", service.ScopeSum)...).Post("/sum", app.sumHandler)
+	router.With(app.protectLimited("/sum/batch", service.ScopeSum)...).Post("/sum/batch", app.sumBatchHandler)
+	router.With(app.protectLimited("/aggregate", service.ScopeSum)...).Post("/aggregate", app.aggregateHandler)
+	router.With(app.rateLimit(jwksPath)).Get(jwksPath, app.jwksHandler)
+	router.With(app.rateLimit(openIDConfigurationPath)).Get(openIDConfigurationPath, app.openIDConfigurationHandler)
+
+	if app.adminKey != "" {
+		router.With(app.rateLimit("/admin/revoke")).Post("/admin/revoke", app.revokeHandler)
+	}
 
 	app.httpServer = &http.Server{
 		Handler: router,
@@ -80,23 +215,167 @@ func (app *RESTApp) authHandler(w http.ResponseWriter, r *http.Request) {
 	creds := service.Credentials{
 		Username: authReq.Username,
 		Password: authReq.Password,
+		RemoteIP: app.clientIP(r),
 	}
 
 	token, err := app.svc.GenerateToken(r.Context(), creds)
 	if err != nil {
 		app.logger.Error("could not generate token", zap.Error(err))
+		setRetryAfter(w, err)
 		writeJSONError(w, toTransportError(err))
 		return
 	}
 
-	writeJSON(w, authenticaResponse{
-		AccessToken: token.AccessToken,
-		TokenType:   token.TokenType,
-		ExpiresIn:   token.ExpiresIn,
+	writeJSON(w, newAuthenticaResponse(token))
+}
+
+func (app *RESTApp) refreshHandler(w http.ResponseWriter, r *http.Request) {
+	var refreshReq refreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&refreshReq); err != nil {
+		app.logger.Error("could not decode request", zap.Error(err))
+		writeJSONError(w, ErrInvalidRequest)
+		return
+	}
+
+	if err := refreshReq.validate(); err != nil {
+		app.logger.Error("could not validate request", zap.Error(err))
+		writeJSONError(w, err)
+		return
+	}
+
+	token, err := app.svc.RefreshToken(r.Context(), refreshReq.RefreshToken)
+	if err != nil {
+		app.logger.Warn("could not refresh token", zap.Error(err))
+		writeJSONError(w, toTransportError(err))
+		return
+	}
+
+	writeJSON(w, newAuthenticaResponse(token))
+}
+
+// oauthTokenHandler is the OAuth 2.0 token endpoint (RFC 6749 section 3.2).
+// Clients authenticate with HTTP Basic and send the grant as a form.
+func (app *RESTApp) oauthTokenHandler(w http.ResponseWriter, r *http.Request) {
+	client, ok := clientCredentialsFromRequest(r)
+	if !ok {
+		app.logger.Warn("missing client credentials")
+		writeOAuthError(w, ErrOAuthInvalidClient)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		app.logger.Error("could not parse form", zap.Error(err))
+		writeOAuthError(w, ErrOAuthInvalidRequest)
+		return
+	}
+
+	// Parameters must not be repeated (RFC 6749 section 3.2).
+	for _, values := range r.PostForm {
+		if len(values) > 1 {
+			app.logger.Error("repeated token request parameter")
+			writeOAuthError(w, ErrOAuthInvalidRequest)
+			return
+		}
+	}
+
+	grantType := r.PostForm.Get("grant_type")
+	if grantType == "" {
+		app.logger.Error("missing grant type")
+		writeOAuthError(w, ErrOAuthInvalidRequest)
+		return
+	}
+
+	token, err := app.svc.Grant(r.Context(), service.GrantRequest{
+		GrantType: grantType,
+		Client:    client,
+		Credentials: service.Credentials{
+			Username: r.PostForm.Get("username"),
+			Password: r.PostForm.Get("password"),
+			RemoteIP: app.clientIP(r),
+		},
+		RefreshToken: r.PostForm.Get("refresh_token"),
+		Scopes:       service.ParseScopes(r.PostForm.Get("scope")),
 	})
+	if err != nil {
+		app.logger.Warn("could not grant token", zap.String("grant_type", grantType), zap.Error(err))
+		setRetryAfter(w, err)
+		writeOAuthError(w, toOAuthError(err))
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+	writeJSON(w, newOAuthTokenResponse(token))
 }
 
-func (app *RESTApp) sumHandler(w http.ResponseWriter, r *http.Request) {
+// introspectHandler tells authenticated clients whether an access token is active and what it grants (RFC 7662).
+// Tokens that fail verification for any reason are reported inactive, without saying why.
+func (app *RESTApp) introspectHandler(w http.ResponseWriter, r *http.Request) {
+	client, ok := clientCredentialsFromRequest(r)
+	if !ok {
+		app.logger.Warn("missing client credentials")
+		writeOAuthError(w, ErrOAuthInvalidClient)
+		return
+	}
+
+	if _, err := app.svc.AuthenticateClient(r.Context(), client); err != nil {
+		app.logger.Warn("could not authenticate client", zap.Error(err))
+		writeOAuthError(w, toOAuthError(err))
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		app.logger.Error("could not parse form", zap.Error(err))
+		writeOAuthError(w, ErrOAuthInvalidRequest)
+		return
+	}
+
+	token := r.PostForm.Get("token")
+	if token == "" {
+		app.logger.Error("missing token")
+		writeOAuthError(w, ErrOAuthInvalidRequest)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+
+	claims, err := app.svc.VerifyToken(r.Context(), token)
+	if err != nil {
+		if toTransportError(err) != ErrUnauthorized {
+			app.logger.Error("could not verify token", zap.Error(err))
+			writeOAuthError(w, ErrOAuthServerError)
+			return
+		}
+
+		app.logger.Debug("introspected inactive token", zap.Error(err))
+		writeJSON(w, introspectionResponse{Active: false})
+		return
+	}
+
+	writeJSON(w, newIntrospectionResponse(claims))
+}
+
+// clientCredentialsFromRequest reads the client credentials from the Authorization header.
+// Both halves are form-encoded before being put in the header (RFC 6749 section 2.3.1).
+func clientCredentialsFromRequest(r *http.Request) (service.ClientCredentials, bool) {
+	id, secret, ok := r.BasicAuth()
+	if !ok {
+		return service.ClientCredentials{}, false
+	}
+
+	id, err := url.QueryUnescape(id)
+	if err != nil {
+		return service.ClientCredentials{}, false
+	}
+
+	secret, err = url.QueryUnescape(secret)
+	if err != nil {
+		return service.ClientCredentials{}, false
+	}
+	return service.ClientCredentials{ID: id, Secret: secret}, true
+}
+
+func (app *RESTApp) logoutHandler(w http.ResponseWriter, r *http.Request) {
 	tokenString := extractTokenFromHeader(r.Header.Get("Authorization"))
 	if tokenString == "" {
 		app.logger.Warn("missing token")
@@ -104,26 +383,406 @@ func (app *RESTApp) sumHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var sumReq sumRequest
-	if err := json.NewDecoder(r.Body).Decode(&sumReq); err != nil {
+	// The body is optional: clients may only want to revoke the access token.
+	var logoutReq logoutRequest
+	if err := json.NewDecoder(r.Body).Decode(&logoutReq); err != nil && !errors.Is(err, io.EOF) {
 		app.logger.Error("could not decode request", zap.Error(err))
 		writeJSONError(w, ErrInvalidRequest)
 		return
 	}
 
-	if err := app.svc.VerifyToken(r.Context(), tokenString); err != nil {
-		app.logger.Warn("could not verify token", zap.Error(err))
+	if err := app.svc.Logout(r.Context(), tokenString, logoutReq.RefreshToken); err != nil {
+		app.logger.Warn("could not log out", zap.Error(err))
+		writeJSONError(w, toTransportError(err))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *RESTApp) revokeHandler(w http.ResponseWriter, r *http.Request) {
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get(adminKeyHeader)), []byte(app.adminKey)) != 1 {
+		app.logger.Warn("invalid admin key")
 		writeJSONError(w, ErrUnauthorized)
 		return
 	}
 
-	sum, err := app.svc.Sum(r.Context(), sumReq)
+	var revokeReq revokeRequest
+	if err := json.NewDecoder(r.Body).Decode(&revokeReq); err != nil {
+		app.logger.Error("could not decode request", zap.Error(err))
+		writeJSONError(w, ErrInvalidRequest)
+		return
+	}
+
+	if err := revokeReq.validate(); err != nil {
+		app.logger.Error("could not validate request", zap.Error(err))
+		writeJSONError(w, err)
+		return
+	}
+
+	if err := app.svc.RevokeTokenID(r.Context(), revokeReq.TokenID, revokeReq.expiresAt()); err != nil {
+		app.logger.Error("could not revoke token", zap.Error(err))
+		writeJSONError(w, toTransportError(err))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *RESTApp) sumHandler(w http.ResponseWriter, r *http.Request) {
+	if isNDJSON(r) {
+		app.sumLines(w, r)
+		return
+	}
+
+	body, opts, err := app.sumDocument(w, r)
+	if err != nil {
+		writeJSONError(w, err)
+		return
+	}
+
+	// The document is summed as it is read, so that its size doesn't matter.
+	sum, err := app.svc.SumStream(r.Context(), body, opts)
 	if err != nil {
 		app.logger.Error("could not sum", zap.Error(err))
+
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			writeJSONError(w, ErrRequestTooLarge)
+			return
+		}
+
+		// Explained sums tell what became of every value, the rejected ones included.
+		var explainedErr *service.ExplainedError
+		if apiErr, ok := toTransportError(err).(APIError); ok && errors.As(err, &explainedErr) {
+			writeJSONStatus(w, apiErr.StatusCode, explainedErrorResponse{
+				APIError:    apiErr,
+				Explanation: newSumExplanationResponse(explainedErr.Explanation),
+			})
+			return
+		}
 		writeJSONError(w, toTransportError(err))
 		return
 	}
-	writeJSON(w, sumResponse{Sum: sum})
+
+	writeJSON(w, newSumResponse(sum))
+}
+
+// sumLines sums every line of an NDJSON body as a document of its own, as /sum sums a document,
+// and writes the result of each line as an NDJSON line as soon as it is summed, so that clients
+// can stream logs through it. maxSumBytes caps each line rather than the whole body.
+// The lines that can't be summed are reported along with the results of the others,
+// and a line too long or a body that can't be read ends the response with its error.
+func (app *RESTApp) sumLines(w http.ResponseWriter, r *http.Request) {
+	opts := sumOptionsFromRequest(r)
+
+	// HTTP/1 responses otherwise consume the rest of the body before their first write.
+	rc := http.NewResponseController(w)
+	if err := rc.EnableFullDuplex(); err != nil {
+		app.logger.Debug("could not enable full duplex", zap.Error(err))
+	}
+
+	maxLine := math.MaxInt
+	if app.maxSumBytes > 0 && app.maxSumBytes < int64(maxLine) {
+		maxLine = int(app.maxSumBytes)
+	}
+
+	lines := bufio.NewScanner(r.Body)
+	lines.Buffer(nil, maxLine)
+
+	w.Header().Set("Content-Type", ndjsonContentType)
+	enc := json.NewEncoder(w)
+
+	write := func(resp sumLineResponse) bool {
+		if err := enc.Encode(resp); err != nil {
+			app.logger.Error("could not write sum", zap.Error(err))
+			return false
+		}
+
+		if err := rc.Flush(); err != nil {
+			app.logger.Error("could not flush sum", zap.Error(err))
+			return false
+		}
+		return true
+	}
+
+	n := 0
+	for lines.Scan() {
+		n++
+
+		line := bytes.TrimSpace(lines.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		sum, err := app.sumLine(r.Context(), line, opts)
+		if err != nil {
+			app.logger.Error("could not sum line", zap.Int("line", n), zap.Error(err))
+		}
+
+		if !write(sumLineResponse{Line: n, sumItemResponse: newSumItemResponse(sum, err)}) {
+			return
+		}
+	}
+
+	if err := lines.Err(); err != nil {
+		app.logger.Error("could not read line", zap.Int("line", n+1), zap.Error(err))
+
+		apiErr := ErrInvalidRequest
+		if errors.Is(err, bufio.ErrTooLong) {
+			apiErr = ErrRequestTooLarge
+		}
+		write(sumLineResponse{Line: n + 1, sumItemResponse: newSumItemResponse(nil, apiErr)})
+	}
+}
+
+// sumLine decodes a line of an NDJSON /sum request and sums it with Service.Sum.
+// Malformed lines fail with service.ErrDocumentInvalid, as malformed documents do,
+// and so do lines with anything but a single document, since the line is all there is to it.
+func (app *RESTApp) sumLine(ctx context.Context, line []byte, opts service.SumOptions) (*service.SumResult, error) {
+	dec := json.NewDecoder(bytes.NewReader(line))
+	dec.UseNumber()
+
+	var data any
+	if err := dec.Decode(&data); err != nil {
+		return nil, fmt.Errorf("could not decode line: %v: %w", err, service.ErrDocumentInvalid)
+	}
+
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("could not decode line with trailing data: %w", service.ErrDocumentInvalid)
+	}
+	return app.svc.Sum(ctx, data, opts)
+}
+
+// sumBatchHandler sums every document of a JSON array, or of an NDJSON body, in a single request.
+// The documents that can't be summed are reported along with the results of the others.
+func (app *RESTApp) sumBatchHandler(w http.ResponseWriter, r *http.Request) {
+	var body io.Reader = r.Body
+	if app.maxBatchBytes > 0 {
+		body = http.MaxBytesReader(w, r.Body, app.maxBatchBytes)
+	}
+
+	docs, err := app.readSumBatch(body, isNDJSON(r))
+	if err != nil {
+		app.logger.Error("could not read batch", zap.Error(err))
+		writeJSONError(w, err)
+		return
+	}
+
+	items, err := app.svc.SumBatch(r.Context(), docs, sumOptionsFromRequest(r))
+	if err != nil {
+		app.logger.Error("could not sum batch", zap.Error(err))
+		writeJSONError(w, toTransportError(err))
+		return
+	}
+
+	writeJSON(w, newSumBatchResponse(items))
+}
+
+// readSumBatch reads the documents of a batch, as the elements of a JSON array or the lines of an NDJSON body.
+func (app *RESTApp) readSumBatch(body io.Reader, ndjson bool) ([][]byte, error) {
+	read := readJSONBatch
+	if ndjson {
+		read = readNDJSONBatch
+	}
+
+	docs, err := read(body, app.maxBatchItems)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return nil, ErrRequestTooLarge
+		}
+		return nil, ErrInvalidRequest
+	}
+
+	if app.maxBatchItems > 0 && len(docs) > app.maxBatchItems {
+		return nil, ErrBatchTooLarge
+	}
+	return docs, nil
+}
+
+// readJSONBatch reads the elements of the JSON array read from r.
+func readJSONBatch(r io.Reader, _ int) ([][]byte, error) {
+	var items []json.RawMessage
+	if err := json.NewDecoder(r).Decode(&items); err != nil {
+		return nil, fmt.Errorf("could not decode batch: %w", err)
+	}
+
+	docs := make([][]byte, 0, len(items))
+	for _, item := range items {
+		docs = append(docs, item)
+	}
+	return docs, nil
+}
+
+// readNDJSONBatch reads the lines read from r, skipping blank lines, and stops past maxItems lines unless it is 0.
+// Malformed lines are left for the service to report as the documents they stand for.
+func readNDJSONBatch(r io.Reader, maxItems int) ([][]byte, error) {
+	var docs [][]byte
+
+	lines := bufio.NewReader(r)
+	for maxItems <= 0 || len(docs) <= maxItems {
+		line, err := lines.ReadBytes('\n')
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			docs = append(docs, line)
+		}
+
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("could not read batch: %w", err)
+		}
+	}
+	return docs, nil
+}
+
+func (app *RESTApp) aggregateHandler(w http.ResponseWriter, r *http.Request) {
+	body, opts, err := app.sumDocument(w, r)
+	if err != nil {
+		writeJSONError(w, err)
+		return
+	}
+
+	result, err := app.svc.Aggregate(r.Context(), body, aggregateOperationsFromRequest(r), opts)
+	if err != nil {
+		app.logger.Error("could not aggregate", zap.Error(err))
+
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			writeJSONError(w, ErrRequestTooLarge)
+			return
+		}
+		writeJSONError(w, toTransportError(err))
+		return
+	}
+
+	writeJSON(w, newAggregateResponse(result))
+}
+
+// sumDocument returns the document of a /sum or /aggregate request, capped to maxSumBytes,
+// and the options it is to be summed with, unwrapping the envelope it may come in.
+func (app *RESTApp) sumDocument(w http.ResponseWriter, r *http.Request) (io.Reader, service.SumOptions, error) {
+	var body io.Reader = r.Body
+	if app.maxSumBytes > 0 {
+		body = http.MaxBytesReader(w, r.Body, app.maxSumBytes)
+	}
+
+	opts := sumOptionsFromRequest(r)
+
+	if envelope, _ := strconv.ParseBool(queryOrHeader(r, "envelope", sumEnvelopeHeader)); !envelope {
+		return body, opts, nil
+	}
+
+	var envelopeReq sumEnvelopeRequest
+	if err := json.NewDecoder(body).Decode(&envelopeReq); err != nil {
+		app.logger.Error("could not decode request", zap.Error(err))
+
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return nil, service.SumOptions{}, ErrRequestTooLarge
+		}
+		return nil, service.SumOptions{}, ErrInvalidRequest
+	}
+
+	if err := envelopeReq.validate(); err != nil {
+		app.logger.Error("could not validate request", zap.Error(err))
+		return nil, service.SumOptions{}, err
+	}
+
+	opts.Include = append(opts.Include, envelopeReq.Include...)
+	opts.Exclude = append(opts.Exclude, envelopeReq.Exclude...)
+	return bytes.NewReader(envelopeReq.Document), opts, nil
+}
+
+func (app *RESTApp) jwksHandler(w http.ResponseWriter, r *http.Request) {
+	metadata, err := app.svc.ProviderMetadata(r.Context())
+	if err != nil {
+		app.logger.Error("could not get provider metadata", zap.Error(err))
+		writeJSONError(w, toTransportError(err))
+		return
+	}
+
+	w.Header().Set("Cache-Control", cacheControlMaxAge(discoveryMaxAge))
+	writeJSON(w, newJWKSResponse(metadata.Keys))
+}
+
+// openIDConfigurationHandler serves the discovery document, or 404 while only HMAC keys are configured,
+// since relying parties couldn't verify the tokens with the empty key set.
+func (app *RESTApp) openIDConfigurationHandler(w http.ResponseWriter, r *http.Request) {
+	metadata, err := app.svc.ProviderMetadata(r.Context())
+	if err != nil {
+		app.logger.Error("could not get provider metadata", zap.Error(err))
+		writeJSONError(w, toTransportError(err))
+		return
+	}
+
+	if len(metadata.SigningAlgorithms) == 0 {
+		writeJSONError(w, ErrDiscoveryUnavailable)
+		return
+	}
+
+	w.Header().Set("Cache-Control", cacheControlMaxAge(discoveryMaxAge))
+	writeJSON(w, openIDConfigurationResponse{
+		Issuer:                            metadata.Issuer,
+		JWKSURI:                           app.publicURL(r) + jwksPath,
+		TokenEndpoint:                     app.publicURL(r) + oauthTokenPath,
+		IntrospectionEndpoint:             app.publicURL(r) + oauthIntrospectPath,
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic"},
+		GrantTypesSupported:               []string{service.GrantPassword, service.GrantClientCredentials, service.GrantRefreshToken},
+		ResponseTypesSupported:            []string{"token"},
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  metadata.SigningAlgorithms,
+	})
+}
+
+// publicURL returns the configured base URL, or the one the request was sent to.
+func (app *RESTApp) publicURL(r *http.Request) string {
+	if app.baseURL != "" {
+		return app.baseURL
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+// sumOptionsFromRequest reads the digest settings, what to do with strings, booleans and nulls,
+// and whether to explain the sum, from the query string, falling back to headers for clients that can't change the URL.
+// The selectors of the values summed are read from the repeatable include and exclude parameters.
+func sumOptionsFromRequest(r *http.Request) service.SumOptions {
+	explain, _ := strconv.ParseBool(queryOrHeader(r, "explain", sumExplainHeader))
+
+	return service.SumOptions{
+		Algorithm: queryOrHeader(r, "alg", digestAlgorithmHeader),
+		Encoding:  queryOrHeader(r, "encoding", digestEncodingHeader),
+		Strings:   service.StringMode(queryOrHeader(r, "strings", sumStringsHeader)),
+		Booleans:  service.LiteralPolicy(queryOrHeader(r, "booleans", sumBooleansHeader)),
+		Nulls:     service.LiteralPolicy(queryOrHeader(r, "nulls", sumNullsHeader)),
+		Explain:   explain,
+		Include:   r.URL.Query()["include"],
+		Exclude:   r.URL.Query()["exclude"],
+	}
+}
+
+// aggregateOperationsFromRequest reads the operations of an aggregate from the repeatable op query parameter,
+// falling back to the header, where several operations can also be separated by commas, as in ?op=min,max.
+func aggregateOperationsFromRequest(r *http.Request) []service.Operation {
+	values := r.URL.Query()["op"]
+	if len(values) == 0 {
+		values = r.Header.Values(aggregateOperationsHeader)
+	}
+
+	var ops []service.Operation
+	for _, v := range values {
+		for _, op := range strings.Split(v, ",") {
+			if op = strings.TrimSpace(op); op != "" {
+				ops = append(ops, service.Operation(op))
+			}
+		}
+	}
+	return ops
 }
 
 func extractTokenFromHeader(authHeader string) string {
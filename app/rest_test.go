@@ -1,15 +1,20 @@
 package app
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/alesr/code-assignment/internal/service"
 	"github.com/go-chi/chi"
@@ -146,48 +151,1598 @@ func TestAuthHandler_serviceError(t *testing.T) {
 	assert.Equal(t, ErrInternal, respErr)
 }
 
+func TestAuthHandler_credentialsMismatch(t *testing.T) {
+	mockSvc := &service.MockService{
+		GenerateTokenFunc: func(ctx context.Context, creds service.Credentials) (*service.Token, error) {
+			return nil, fmt.Errorf("could not match password: %w", service.ErrCredentialsMismatch)
+		},
+	}
+
+	router := chi.NewRouter()
+
+	app := &RESTApp{
+		logger: zap.NewNop(),
+		svc:    mockSvc,
+	}
+
+	router.Post("/auth", app.authHandler)
+
+	body := `{"username": "test-user", "password": "wrong-pass"}`
+
+	req, err := http.NewRequest(http.MethodPost, "/auth", bytes.NewBufferString(body))
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	var respErr APIError
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&respErr))
+
+	assert.Equal(t, ErrCredentialsMismatch, respErr)
+}
+
+func TestRefreshHandler(t *testing.T) {
+	var observedRefreshToken string
+	mockSvc := &service.MockService{
+		RefreshTokenFunc: func(ctx context.Context, refreshToken string) (*service.Token, error) {
+			observedRefreshToken = refreshToken
+			return &service.Token{
+				AccessToken:  "foo-token",
+				TokenType:    "bar-token-type",
+				ExpiresIn:    3600,
+				RefreshToken: "qux-refresh-token",
+			}, nil
+		},
+	}
+
+	router := chi.NewRouter()
+
+	app := &RESTApp{
+		logger: zap.NewNop(),
+		svc:    mockSvc,
+	}
+
+	router.Post("/token/refresh", app.refreshHandler)
+
+	body := `{"refresh_token": "baz-refresh-token"}`
+
+	req, err := http.NewRequest(http.MethodPost, "/token/refresh", bytes.NewBufferString(body))
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "baz-refresh-token", observedRefreshToken)
+	assert.Equal(t,
+		`{"access_token":"foo-token","token_type":"bar-token-type","expired_in":3600,"refresh_token":"qux-refresh-token"}`,
+		strings.TrimSpace(w.Body.String()),
+	)
+}
+
+func TestRefreshHandler_reusedToken(t *testing.T) {
+	mockSvc := &service.MockService{
+		RefreshTokenFunc: func(ctx context.Context, refreshToken string) (*service.Token, error) {
+			return nil, service.ErrRefreshTokenReused
+		},
+	}
+
+	router := chi.NewRouter()
+
+	app := &RESTApp{
+		logger: zap.NewNop(),
+		svc:    mockSvc,
+	}
+
+	router.Post("/token/refresh", app.refreshHandler)
+
+	req, err := http.NewRequest(http.MethodPost, "/token/refresh", bytes.NewBufferString(`{"refresh_token": "foo"}`))
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	var respErr APIError
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&respErr))
+
+	assert.Equal(t, ErrInvalidRefreshToken, respErr)
+}
+
+func TestOAuthTokenHandler(t *testing.T) {
+	var observedRequest service.GrantRequest
+	mockSvc := &service.MockService{
+		GrantFunc: func(ctx context.Context, req service.GrantRequest) (*service.Token, error) {
+			observedRequest = req
+			return &service.Token{
+				AccessToken:  "foo-token",
+				TokenType:    "Bearer",
+				ExpiresIn:    3600,
+				RefreshToken: "bar-refresh-token",
+				Scope:        "sum",
+			}, nil
+		},
+	}
+
+	router := chi.NewRouter()
+
+	app := &RESTApp{
+		logger: zap.NewNop(),
+		svc:    mockSvc,
+	}
+
+	router.Post("/oauth/token", app.oauthTokenHandler)
+
+	form := url.Values{
+		"grant_type": {"password"},
+		"username":   {"test-user"},
+		"password":   {"test-pass"},
+		"scope":      {"sum read"},
+	}
+
+	req, err := http.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(form.Encode()))
+	require.NoError(t, err)
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	// Client credentials are form-encoded before going into the header.
+	req.SetBasicAuth(url.QueryEscape("foo:client"), url.QueryEscape("bar secret"))
+
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+	assert.Equal(t, "no-cache", w.Header().Get("Pragma"))
+	assert.JSONEq(t, `{"access_token":"foo-token","token_type":"Bearer","expires_in":3600,"refresh_token":"bar-refresh-token","scope":"sum"}`, w.Body.String())
+
+	assert.Equal(t, service.GrantRequest{
+		GrantType:   service.GrantPassword,
+		Client:      service.ClientCredentials{ID: "foo:client", Secret: "bar secret"},
+		Credentials: service.Credentials{Username: "test-user", Password: "test-pass"},
+		Scopes:      []string{"sum", "read"},
+	}, observedRequest)
+}
+
+func TestOAuthTokenHandler_errors(t *testing.T) {
+	testCases := []struct {
+		name                  string
+		givenForm             string
+		givenBasicAuth        bool
+		givenServiceError     error
+		expectedStatus        int
+		expectedError         string
+		expectedAuthenticate  bool
+		expectedServiceCalled bool
+	}{
+		{
+			name:                 "missing client authentication",
+			givenForm:            "grant_type=client_credentials",
+			expectedStatus:       http.StatusUnauthorized,
+			expectedError:        "invalid_client",
+			expectedAuthenticate: true,
+		},
+		{
+			name:           "missing grant type",
+			givenForm:      "username=test-user",
+			givenBasicAuth: true,
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "invalid_request",
+		},
+		{
+			name:           "repeated parameter",
+			givenForm:      "grant_type=password&grant_type=client_credentials",
+			givenBasicAuth: true,
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "invalid_request",
+		},
+		{
+			name:                  "wrong client secret",
+			givenForm:             "grant_type=client_credentials",
+			givenBasicAuth:        true,
+			givenServiceError:     fmt.Errorf("foo: %w", service.ErrClientCredentialsMismatch),
+			expectedStatus:        http.StatusUnauthorized,
+			expectedError:         "invalid_client",
+			expectedAuthenticate:  true,
+			expectedServiceCalled: true,
+		},
+		{
+			name:                  "wrong password",
+			givenForm:             "grant_type=password&username=test-user&password=foo",
+			givenBasicAuth:        true,
+			givenServiceError:     fmt.Errorf("foo: %w", service.ErrCredentialsMismatch),
+			expectedStatus:        http.StatusBadRequest,
+			expectedError:         "invalid_grant",
+			expectedServiceCalled: true,
+		},
+		{
+			name:                  "reused refresh token",
+			givenForm:             "grant_type=refresh_token&refresh_token=foo",
+			givenBasicAuth:        true,
+			givenServiceError:     service.ErrRefreshTokenReused,
+			expectedStatus:        http.StatusBadRequest,
+			expectedError:         "invalid_grant",
+			expectedServiceCalled: true,
+		},
+		{
+			name:                  "unsupported grant type",
+			givenForm:             "grant_type=authorization_code",
+			givenBasicAuth:        true,
+			givenServiceError:     service.ErrUnsupportedGrantType,
+			expectedStatus:        http.StatusBadRequest,
+			expectedError:         "unsupported_grant_type",
+			expectedServiceCalled: true,
+		},
+		{
+			name:                  "invalid scope",
+			givenForm:             "grant_type=client_credentials&scope=admin",
+			givenBasicAuth:        true,
+			givenServiceError:     fmt.Errorf("foo: %w", service.ErrScopeInvalid),
+			expectedStatus:        http.StatusBadRequest,
+			expectedError:         "invalid_scope",
+			expectedServiceCalled: true,
+		},
+		{
+			name:                  "unexpected error",
+			givenForm:             "grant_type=client_credentials",
+			givenBasicAuth:        true,
+			givenServiceError:     errors.New("foo"),
+			expectedStatus:        http.StatusInternalServerError,
+			expectedError:         "server_error",
+			expectedServiceCalled: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var serviceCalled bool
+			mockSvc := &service.MockService{
+				GrantFunc: func(ctx context.Context, req service.GrantRequest) (*service.Token, error) {
+					serviceCalled = true
+					return nil, tc.givenServiceError
+				},
+			}
+
+			router := chi.NewRouter()
+
+			app := &RESTApp{
+				logger: zap.NewNop(),
+				svc:    mockSvc,
+			}
+
+			router.Post("/oauth/token", app.oauthTokenHandler)
+
+			req, err := http.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(tc.givenForm))
+			require.NoError(t, err)
+
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if tc.givenBasicAuth {
+				req.SetBasicAuth("foo-client", "bar-secret")
+			}
+
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.expectedStatus, w.Code)
+			assert.Equal(t, tc.expectedServiceCalled, serviceCalled)
+			assert.Equal(t, tc.expectedAuthenticate, w.Header().Get("WWW-Authenticate") != "")
+
+			var respErr OAuthError
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&respErr))
+
+			assert.Equal(t, tc.expectedError, respErr.Code)
+			assert.NotEmpty(t, respErr.Description)
+		})
+	}
+}
+
+func TestIntrospectHandler(t *testing.T) {
+	testCases := []struct {
+		name             string
+		givenForm        string
+		givenBasicAuth   bool
+		givenClientError error
+		givenVerifyError error
+		expectedStatus   int
+		expectedBody     string
+	}{
+		{
+			name:           "active token",
+			givenForm:      "token=foo-token",
+			givenBasicAuth: true,
+			expectedStatus: http.StatusOK,
+			expectedBody: `{"active":true,"scope":"sum","sub":"foo-user","client_id":"foo-client","token_type":"Bearer",` +
+				`"exp":1700003600,"iat":1700000000,"aud":"foo-audience","iss":"foo-issuer","jti":"foo-jti"}`,
+		},
+		{
+			name:             "revoked token",
+			givenForm:        "token=foo-token",
+			givenBasicAuth:   true,
+			givenVerifyError: fmt.Errorf("foo: %w", service.ErrTokenRevoked),
+			expectedStatus:   http.StatusOK,
+			expectedBody:     `{"active":false}`,
+		},
+		{
+			name:             "token not valid yet",
+			givenForm:        "token=foo-token",
+			givenBasicAuth:   true,
+			givenVerifyError: service.ErrTokenNotYetValid,
+			expectedStatus:   http.StatusOK,
+			expectedBody:     `{"active":false}`,
+		},
+		{
+			name:             "malformed token",
+			givenForm:        "token=foo-token",
+			givenBasicAuth:   true,
+			givenVerifyError: fmt.Errorf("foo: %w", service.ErrTokenInvalid),
+			expectedStatus:   http.StatusOK,
+			expectedBody:     `{"active":false}`,
+		},
+		{
+			name:             "verification failure",
+			givenForm:        "token=foo-token",
+			givenBasicAuth:   true,
+			givenVerifyError: errors.New("foo"),
+			expectedStatus:   http.StatusInternalServerError,
+			expectedBody:     `{"error":"server_error","error_description":"internal server error"}`,
+		},
+		{
+			name:           "missing token",
+			givenForm:      "token_type_hint=access_token",
+			givenBasicAuth: true,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"invalid_request","error_description":"the request is missing a parameter or is malformed"}`,
+		},
+		{
+			name:           "missing client authentication",
+			givenForm:      "token=foo-token",
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   `{"error":"invalid_client","error_description":"the client authentication failed"}`,
+		},
+		{
+			name:             "wrong client secret",
+			givenForm:        "token=foo-token",
+			givenBasicAuth:   true,
+			givenClientError: service.ErrClientCredentialsMismatch,
+			expectedStatus:   http.StatusUnauthorized,
+			expectedBody:     `{"error":"invalid_client","error_description":"the client authentication failed"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockSvc := &service.MockService{
+				AuthenticateClientFunc: func(ctx context.Context, creds service.ClientCredentials) (*service.Client, error) {
+					if tc.givenClientError != nil {
+						return nil, tc.givenClientError
+					}
+					return &service.Client{ID: creds.ID}, nil
+				},
+				VerifyTokenFunc: func(ctx context.Context, token string) (*service.Claims, error) {
+					if tc.givenVerifyError != nil {
+						return nil, tc.givenVerifyError
+					}
+
+					claims := service.Claims{ClientID: "foo-client", Scope: "sum"}
+					claims.Subject = "foo-user"
+					claims.ExpiresAt = 1700003600
+					claims.IssuedAt = 1700000000
+					claims.Audience = "foo-audience"
+					claims.Issuer = "foo-issuer"
+					claims.Id = "foo-jti"
+					return &claims, nil
+				},
+			}
+
+			router := chi.NewRouter()
+
+			app := &RESTApp{
+				logger: zap.NewNop(),
+				svc:    mockSvc,
+			}
+
+			router.Post("/oauth/introspect", app.introspectHandler)
+
+			req, err := http.NewRequest(http.MethodPost, "/oauth/introspect", strings.NewReader(tc.givenForm))
+			require.NoError(t, err)
+
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if tc.givenBasicAuth {
+				req.SetBasicAuth("foo-client", "bar-secret")
+			}
+
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.expectedStatus, w.Code)
+			assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+			assert.JSONEq(t, tc.expectedBody, w.Body.String())
+		})
+	}
+}
+
+func TestLogoutHandler(t *testing.T) {
+	var observedAccessToken, observedRefreshToken string
+	mockSvc := &service.MockService{
+		LogoutFunc: func(ctx context.Context, accessToken, refreshToken string) error {
+			observedAccessToken, observedRefreshToken = accessToken, refreshToken
+			return nil
+		},
+	}
+
+	router := chi.NewRouter()
+
+	app := &RESTApp{
+		logger: zap.NewNop(),
+		svc:    mockSvc,
+	}
+
+	router.Post("/auth/logout", app.logoutHandler)
+
+	t.Run("with refresh token", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "/auth/logout", bytes.NewBufferString(`{"refresh_token": "bar"}`))
+		require.NoError(t, err)
+
+		req.Header.Set("Authorization", "Bearer foo")
+
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		assert.Equal(t, "foo", observedAccessToken)
+		assert.Equal(t, "bar", observedRefreshToken)
+	})
+
+	t.Run("without body", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "/auth/logout", http.NoBody)
+		require.NoError(t, err)
+
+		req.Header.Set("Authorization", "Bearer foo")
+
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		assert.Equal(t, "", observedRefreshToken)
+	})
+
+	t.Run("without token", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "/auth/logout", http.NoBody)
+		require.NoError(t, err)
+
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
+
+func TestLogoutHandler_revokedToken(t *testing.T) {
+	mockSvc := &service.MockService{
+		LogoutFunc: func(ctx context.Context, accessToken, refreshToken string) error {
+			return fmt.Errorf("could not verify token: %w", service.ErrTokenRevoked)
+		},
+	}
+
+	router := chi.NewRouter()
+
+	app := &RESTApp{
+		logger: zap.NewNop(),
+		svc:    mockSvc,
+	}
+
+	router.Post("/auth/logout", app.logoutHandler)
+
+	req, err := http.NewRequest(http.MethodPost, "/auth/logout", http.NoBody)
+	require.NoError(t, err)
+
+	req.Header.Set("Authorization", "Bearer foo")
+
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	var respErr APIError
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&respErr))
+
+	assert.Equal(t, ErrUnauthorized, respErr)
+}
+
+func TestRevokeHandler(t *testing.T) {
+	var (
+		observedTokenID   string
+		observedExpiresAt time.Time
+	)
+	mockSvc := &service.MockService{
+		RevokeTokenIDFunc: func(ctx context.Context, jti string, expiresAt time.Time) error {
+			observedTokenID, observedExpiresAt = jti, expiresAt
+			return nil
+		},
+	}
+
+	router := chi.NewRouter()
+
+	NewRESTApp(zap.NewNop(), "8080", router, mockSvc, WithAdminKey("foo-admin-key"))
+
+	t.Run("valid admin key", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "/admin/revoke", bytes.NewBufferString(`{"jti": "bar", "expires_at": 1700000000}`))
+		require.NoError(t, err)
+
+		req.Header.Set("X-Admin-Key", "foo-admin-key")
+
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		assert.Equal(t, "bar", observedTokenID)
+		assert.Equal(t, time.Unix(1700000000, 0), observedExpiresAt)
+	})
+
+	t.Run("invalid admin key", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "/admin/revoke", bytes.NewBufferString(`{"jti": "bar"}`))
+		require.NoError(t, err)
+
+		req.Header.Set("X-Admin-Key", "baz")
+
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("missing token ID", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "/admin/revoke", bytes.NewBufferString(`{}`))
+		require.NoError(t, err)
+
+		req.Header.Set("X-Admin-Key", "foo-admin-key")
+
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestRevokeHandler_disabledWithoutAdminKey(t *testing.T) {
+	router := chi.NewRouter()
+
+	NewRESTApp(zap.NewNop(), "8080", router, &service.MockService{})
+
+	req, err := http.NewRequest(http.MethodPost, "/admin/revoke", bytes.NewBufferString(`{"jti": "bar"}`))
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
 func TestSumHandler(t *testing.T) {
 	mockSvc := &service.MockService{
-		VerifyTokenFunc: func(ctx context.Context, token string) error {
-			return nil
+		VerifyTokenFunc: func(ctx context.Context, token string) (*service.Claims, error) {
+			return &service.Claims{Scope: service.ScopeSum}, nil
+		},
+		SumStreamFunc: func(ctx context.Context, r io.Reader, opts service.SumOptions) (*service.SumResult, error) {
+			return &service.SumResult{Hash: "abcd", Algorithm: "sha256", Encoding: "hex"}, nil
+		},
+	}
+
+	router := chi.NewRouter()
+
+	app := &RESTApp{
+		logger: zap.NewNop(),
+		svc:    mockSvc,
+	}
+
+	router.With(app.protect(service.ScopeSum)...).Post("/sum", app.sumHandler)
+
+	body := `{"a": 2, "b": 3}`
+
+	req, err := http.NewRequest(http.MethodPost, "/sum", bytes.NewBufferString(body))
+	require.NoError(t, err)
+
+	req.Header.Set("Authorization", "Bearer abcd") // token is required
+
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `{"sum":"abcd","algorithm":"sha256","encoding":"hex"}`, strings.TrimSpace(w.Body.String()))
+}
+
+func TestSumHandler_digestOptions(t *testing.T) {
+	var observedOpts service.SumOptions
+	mockSvc := &service.MockService{
+		VerifyTokenFunc: func(ctx context.Context, token string) (*service.Claims, error) {
+			return &service.Claims{Scope: service.ScopeSum}, nil
+		},
+		SumStreamFunc: func(ctx context.Context, r io.Reader, opts service.SumOptions) (*service.SumResult, error) {
+			observedOpts = opts
+			return &service.SumResult{Hash: "abcd", Algorithm: opts.Algorithm, Encoding: opts.Encoding}, nil
+		},
+	}
+
+	router := chi.NewRouter()
+
+	app := &RESTApp{
+		logger: zap.NewNop(),
+		svc:    mockSvc,
+	}
+
+	router.With(app.protect(service.ScopeSum)...).Post("/sum", app.sumHandler)
+
+	req, err := http.NewRequest(http.MethodPost, "/sum?alg=sha512&strings=strict", bytes.NewBufferString(`[1]`))
+	require.NoError(t, err)
+
+	req.Header.Set("Authorization", "Bearer abcd")
+	req.Header.Set("X-Digest-Algorithm", "sha3-256") // the query parameter wins
+	req.Header.Set("X-Digest-Encoding", "base64url")
+	req.Header.Set("X-Sum-Strings", "lenient")
+
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, service.SumOptions{Algorithm: "sha512", Encoding: "base64url", Strings: service.StringsStrict}, observedOpts)
+	assert.Equal(t, `{"sum":"abcd","algorithm":"sha512","encoding":"base64url"}`, strings.TrimSpace(w.Body.String()))
+}
+
+func TestSumHandler_unsupportedDigest(t *testing.T) {
+	mockSvc := &service.MockService{
+		VerifyTokenFunc: func(ctx context.Context, token string) (*service.Claims, error) {
+			return &service.Claims{Scope: service.ScopeSum}, nil
+		},
+		SumStreamFunc: func(ctx context.Context, r io.Reader, opts service.SumOptions) (*service.SumResult, error) {
+			return nil, service.ErrUnsupportedDigest
+		},
+	}
+
+	router := chi.NewRouter()
+
+	app := &RESTApp{
+		logger: zap.NewNop(),
+		svc:    mockSvc,
+	}
+
+	router.With(app.protect(service.ScopeSum)...).Post("/sum", app.sumHandler)
+
+	req, err := http.NewRequest(http.MethodPost, "/sum?alg=md5", bytes.NewBufferString(`[1]`))
+	require.NoError(t, err)
+
+	req.Header.Set("Authorization", "Bearer abcd")
+
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	var respErr APIError
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&respErr))
+
+	assert.Equal(t, ErrUnsupportedDigest, respErr)
+}
+
+func TestSumHandler_unsupportedStringMode(t *testing.T) {
+	mockSvc := &service.MockService{
+		VerifyTokenFunc: func(ctx context.Context, token string) (*service.Claims, error) {
+			return &service.Claims{Scope: service.ScopeSum}, nil
+		},
+		SumStreamFunc: func(ctx context.Context, r io.Reader, opts service.SumOptions) (*service.SumResult, error) {
+			_, err := service.ParseStringMode(string(opts.Strings))
+			return nil, err
+		},
+	}
+
+	router := chi.NewRouter()
+
+	app := &RESTApp{
+		logger: zap.NewNop(),
+		svc:    mockSvc,
+	}
+
+	router.With(app.protect(service.ScopeSum)...).Post("/sum", app.sumHandler)
+
+	req, err := http.NewRequest(http.MethodPost, "/sum", bytes.NewBufferString(`[1]`))
+	require.NoError(t, err)
+
+	req.Header.Set("Authorization", "Bearer abcd")
+	req.Header.Set("X-Sum-Strings", "loose")
+
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	var respErr APIError
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&respErr))
+
+	assert.Equal(t, ErrUnsupportedStringMode, respErr)
+}
+
+func TestSumHandler_rejectedValue(t *testing.T) {
+	var observedOpts service.SumOptions
+	mockSvc := &service.MockService{
+		VerifyTokenFunc: func(ctx context.Context, token string) (*service.Claims, error) {
+			return &service.Claims{Scope: service.ScopeSum}, nil
+		},
+		SumStreamFunc: func(ctx context.Context, r io.Reader, opts service.SumOptions) (*service.SumResult, error) {
+			observedOpts = opts
+			return nil, fmt.Errorf("could not sum numbers: %w", &service.PathError{
+				Path: "$.ok",
+				Err:  service.ErrUnsupportedValueType,
+			})
+		},
+	}
+
+	router := chi.NewRouter()
+
+	app := &RESTApp{
+		logger: zap.NewNop(),
+		svc:    mockSvc,
+	}
+
+	router.With(app.protect(service.ScopeSum)...).Post("/sum", app.sumHandler)
+
+	req, err := http.NewRequest(http.MethodPost, "/sum?booleans=reject", bytes.NewBufferString(`{"a":1,"ok":true}`))
+	require.NoError(t, err)
+
+	req.Header.Set("Authorization", "Bearer abcd")
+	req.Header.Set("X-Sum-Nulls", "numeric")
+
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, service.LiteralsReject, observedOpts.Booleans)
+	assert.Equal(t, service.LiteralsNumeric, observedOpts.Nulls)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.JSONEq(t, `{"status_code":422,"error":"the value type is unsupported","path":"$.ok"}`, w.Body.String())
+}
+
+func TestSumHandler_nonFiniteResult(t *testing.T) {
+	mockSvc := &service.MockService{
+		VerifyTokenFunc: func(ctx context.Context, token string) (*service.Claims, error) {
+			return &service.Claims{Scope: service.ScopeSum}, nil
+		},
+		SumStreamFunc: func(ctx context.Context, r io.Reader, opts service.SumOptions) (*service.SumResult, error) {
+			return nil, fmt.Errorf("could not sum numbers: %w", &service.PathError{
+				Path: "$.b[1]",
+				Err:  service.ErrNonFiniteResult,
+			})
+		},
+	}
+
+	router := chi.NewRouter()
+
+	app := &RESTApp{
+		logger: zap.NewNop(),
+		svc:    mockSvc,
+	}
+
+	router.With(app.protect(service.ScopeSum)...).Post("/sum", app.sumHandler)
+
+	req, err := http.NewRequest(http.MethodPost, "/sum?strings=lenient", bytes.NewBufferString(`{"a":1,"b":[2,"NaN"]}`))
+	require.NoError(t, err)
+
+	req.Header.Set("Authorization", "Bearer abcd")
+
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	var respErr APIError
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&respErr))
+
+	expectedErr := ErrNonFiniteResult
+	expectedErr.Path = "$.b[1]"
+	assert.Equal(t, expectedErr, respErr)
+}
+
+func TestSumHandler_explain(t *testing.T) {
+	explanation := &service.SumExplanation{
+		Sum:   "3",
+		Count: 2,
+		Values: []service.SumValue{
+			{Pointer: "/a/0", Value: "1", Status: service.SumValueContributed},
+			{Pointer: "/a/1", Value: `"dark"`, Status: service.SumValueSkipped},
+			{Pointer: "/b", Value: "2.0", Status: service.SumValueContributed},
+		},
+	}
+
+	testCases := []struct {
+		name              string
+		givenURL          string
+		givenServiceError error
+		expectedExplain   bool
+		expectedStatus    int
+		expectedBody      string
+	}{
+		{
+			name:            "explained",
+			givenURL:        "/sum?explain=true",
+			expectedExplain: true,
+			expectedStatus:  http.StatusOK,
+			expectedBody: `{"sum":"abcd","algorithm":"sha256","encoding":"hex","explanation":{"sum":"3","count":2,"values":[
+				{"pointer":"/a/0","value":1,"status":"contributed"},
+				{"pointer":"/a/1","value":"dark","status":"skipped"},
+				{"pointer":"/b","value":2.0,"status":"contributed"}]}}`,
+		},
+		{
+			name:           "not explained",
+			givenURL:       "/sum?explain=false",
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"sum":"abcd","algorithm":"sha256","encoding":"hex"}`,
+		},
+		{
+			name:     "rejected values",
+			givenURL: "/sum?explain=1",
+			givenServiceError: fmt.Errorf("could not explain sum: %w", &service.ExplainedError{
+				Explanation: &service.SumExplanation{
+					Sum: "1",
+					Values: []service.SumValue{
+						{Pointer: "/ok", Value: "true", Status: service.SumValueRejected, Reason: "could not sum true"},
+					},
+				},
+				Err: &service.PathError{Path: "$.ok", Err: service.ErrUnsupportedValueType},
+			}),
+			expectedExplain: true,
+			expectedStatus:  http.StatusUnprocessableEntity,
+			expectedBody: `{"status_code":422,"error":"the value type is unsupported","path":"$.ok","explanation":{"sum":"1","count":0,"values":[
+				{"pointer":"/ok","value":true,"status":"rejected","reason":"could not sum true"}]}}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var observedOpts service.SumOptions
+			mockSvc := &service.MockService{
+				VerifyTokenFunc: func(ctx context.Context, token string) (*service.Claims, error) {
+					return &service.Claims{Scope: service.ScopeSum}, nil
+				},
+				SumStreamFunc: func(ctx context.Context, r io.Reader, opts service.SumOptions) (*service.SumResult, error) {
+					observedOpts = opts
+					if tc.givenServiceError != nil {
+						return nil, tc.givenServiceError
+					}
+
+					result := &service.SumResult{Hash: "abcd", Algorithm: "sha256", Encoding: "hex"}
+					if opts.Explain {
+						result.Explanation = explanation
+					}
+					return result, nil
+				},
+			}
+
+			router := chi.NewRouter()
+
+			app := &RESTApp{
+				logger: zap.NewNop(),
+				svc:    mockSvc,
+			}
+
+			router.With(app.protect(service.ScopeSum)...).Post("/sum", app.sumHandler)
+
+			req, err := http.NewRequest(http.MethodPost, tc.givenURL, bytes.NewBufferString(`{"a":[1,"dark"],"b":2.0}`))
+			require.NoError(t, err)
+
+			req.Header.Set("Authorization", "Bearer abcd")
+
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.expectedExplain, observedOpts.Explain)
+			assert.Equal(t, tc.expectedStatus, w.Code)
+			assert.JSONEq(t, tc.expectedBody, w.Body.String())
+		})
+	}
+}
+
+func TestSumHandler_selectors(t *testing.T) {
+	testCases := []struct {
+		name             string
+		givenURL         string
+		givenHeaders     map[string]string
+		givenBody        string
+		expectedStatus   int
+		expectedDocument string
+		expectedInclude  []string
+		expectedExclude  []string
+		expectedError    APIError
+	}{
+		{
+			name:             "query parameters",
+			givenURL:         "/sum?include=$.items[*].price&include=/total&exclude=$..id",
+			givenBody:        `{"items":[{"id":1,"price":2}],"total":2}`,
+			expectedStatus:   http.StatusOK,
+			expectedDocument: `{"items":[{"id":1,"price":2}],"total":2}`,
+			expectedInclude:  []string{"$.items[*].price", "/total"},
+			expectedExclude:  []string{"$..id"},
+		},
+		{
+			name:             "envelope",
+			givenURL:         "/sum?envelope=true",
+			givenBody:        `{"document":{"items":[{"price":2}]},"include":["$.items[*].price"]}`,
+			expectedStatus:   http.StatusOK,
+			expectedDocument: `{"items":[{"price":2}]}`,
+			expectedInclude:  []string{"$.items[*].price"},
+		},
+		{
+			name:             "envelope and query parameters",
+			givenURL:         "/sum?exclude=/a",
+			givenHeaders:     map[string]string{"X-Sum-Envelope": "true"},
+			givenBody:        `{"document":[1],"exclude":["/b"]}`,
+			expectedStatus:   http.StatusOK,
+			expectedDocument: `[1]`,
+			expectedExclude:  []string{"/a", "/b"},
+		},
+		{
+			name:           "envelope without document",
+			givenURL:       "/sum?envelope=true",
+			givenBody:      `{"include":["/a"]}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  ErrInvalidRequest,
+		},
+		{
+			name:           "malformed envelope",
+			givenURL:       "/sum?envelope=true",
+			givenBody:      `{"document":`,
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  ErrInvalidRequest,
+		},
+		{
+			name:           "invalid selector",
+			givenURL:       "/sum?include=items",
+			givenBody:      `{"items":[]}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  ErrInvalidSelector,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var (
+				observedDocument []byte
+				observedOpts     service.SumOptions
+			)
+
+			mockSvc := &service.MockService{
+				VerifyTokenFunc: func(ctx context.Context, token string) (*service.Claims, error) {
+					return &service.Claims{Scope: service.ScopeSum}, nil
+				},
+				SumStreamFunc: func(ctx context.Context, r io.Reader, opts service.SumOptions) (*service.SumResult, error) {
+					observedOpts = opts
+
+					var err error
+					if observedDocument, err = io.ReadAll(r); err != nil {
+						return nil, err
+					}
+
+					for _, s := range append(opts.Include, opts.Exclude...) {
+						if _, err := service.ParseSelector(s); err != nil {
+							return nil, err
+						}
+					}
+					return &service.SumResult{Hash: "abcd", Algorithm: "sha256", Encoding: "hex"}, nil
+				},
+			}
+
+			router := chi.NewRouter()
+
+			app := &RESTApp{
+				logger:      zap.NewNop(),
+				svc:         mockSvc,
+				maxSumBytes: defaultMaxSumBytes,
+			}
+
+			router.With(app.protect(service.ScopeSum)...).Post("/sum", app.sumHandler)
+
+			req, err := http.NewRequest(http.MethodPost, tc.givenURL, bytes.NewBufferString(tc.givenBody))
+			require.NoError(t, err)
+
+			req.Header.Set("Authorization", "Bearer abcd")
+			for k, v := range tc.givenHeaders {
+				req.Header.Set(k, v)
+			}
+
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.expectedStatus, w.Code)
+
+			if tc.expectedStatus != http.StatusOK {
+				var respErr APIError
+				assert.NoError(t, json.NewDecoder(w.Body).Decode(&respErr))
+				assert.Equal(t, tc.expectedError, respErr)
+				return
+			}
+
+			assert.Equal(t, tc.expectedDocument, string(observedDocument))
+			assert.Equal(t, tc.expectedInclude, observedOpts.Include)
+			assert.Equal(t, tc.expectedExclude, observedOpts.Exclude)
+		})
+	}
+}
+
+func TestSumBatchHandler(t *testing.T) {
+	testCases := []struct {
+		name           string
+		givenBody      string
+		givenType      string
+		givenMaxBytes  int64
+		givenItems     []service.SumBatchItem
+		givenError     error
+		expectedDocs   []string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:      "array",
+			givenBody: `[[1, 2], {"a": true}, [1]]`,
+			givenType: "application/json",
+			givenItems: []service.SumBatchItem{
+				{Result: &service.SumResult{Hash: "abcd", Algorithm: "sha256", Encoding: "hex"}},
+				{Err: fmt.Errorf("could not sum numbers: %w", &service.PathError{Path: "$.a", Err: service.ErrUnsupportedValueType})},
+				{Err: errors.New("unexpected")},
+			},
+			expectedDocs:   []string{`[1, 2]`, `{"a": true}`, `[1]`},
+			expectedStatus: http.StatusOK,
+			expectedBody: `{"results":[
+				{"sum":"abcd","algorithm":"sha256","encoding":"hex"},
+				{"error":{"status_code":422,"error":"the value type is unsupported","path":"$.a"}},
+				{"error":{"status_code":500,"error":"internal server error"}}
+			]}`,
+		},
+		{
+			name:      "NDJSON",
+			givenBody: "[1, 2]\n\n  {\"a\": 1}\r\n[1,",
+			givenType: "application/x-ndjson; charset=utf-8",
+			givenItems: []service.SumBatchItem{
+				{Result: &service.SumResult{Hash: "abcd", Algorithm: "sha256", Encoding: "hex"}},
+				{Result: &service.SumResult{Hash: "ef01", Algorithm: "sha256", Encoding: "hex"}},
+				{Err: fmt.Errorf("could not sum numbers: %w", service.ErrDocumentInvalid)},
+			},
+			expectedDocs:   []string{`[1, 2]`, `{"a": 1}`, `[1,`},
+			expectedStatus: http.StatusOK,
+			expectedBody: `{"results":[
+				{"sum":"abcd","algorithm":"sha256","encoding":"hex"},
+				{"sum":"ef01","algorithm":"sha256","encoding":"hex"},
+				{"error":{"status_code":400,"error":"the request is invalid"}}
+			]}`,
+		},
+		{
+			name:           "empty array",
+			givenBody:      `[]`,
+			givenItems:     []service.SumBatchItem{},
+			expectedDocs:   []string{},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"results":[]}`,
+		},
+		{
+			name:           "not an array",
+			givenBody:      `{"a": 1}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status_code":400,"error":"the request is invalid"}`,
+		},
+		{
+			name:           "too many documents",
+			givenBody:      `[1, 2, 3, 4]`,
+			expectedStatus: http.StatusRequestEntityTooLarge,
+			expectedBody:   `{"status_code":413,"error":"the batch has too many documents"}`,
+		},
+		{
+			name:           "too many lines",
+			givenBody:      "1\n2\n3\n4\n",
+			givenType:      "application/x-ndjson",
+			expectedStatus: http.StatusRequestEntityTooLarge,
+			expectedBody:   `{"status_code":413,"error":"the batch has too many documents"}`,
+		},
+		{
+			name:           "too large",
+			givenBody:      "[1]\n[2]\n[3]\n",
+			givenType:      "application/x-ndjson",
+			givenMaxBytes:  6,
+			expectedStatus: http.StatusRequestEntityTooLarge,
+			expectedBody:   `{"status_code":413,"error":"the request body is too large"}`,
+		},
+		{
+			name:           "invalid options",
+			givenBody:      `[1]`,
+			givenError:     fmt.Errorf("could not sum numbers: %w", service.ErrUnsupportedStringMode),
+			expectedDocs:   []string{`1`},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status_code":400,"error":"the string mode is unsupported"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var observedDocs []string
+
+			mockSvc := &service.MockService{
+				VerifyTokenFunc: func(ctx context.Context, token string) (*service.Claims, error) {
+					return &service.Claims{Scope: service.ScopeSum}, nil
+				},
+				SumBatchFunc: func(ctx context.Context, docs [][]byte, opts service.SumOptions) ([]service.SumBatchItem, error) {
+					observedDocs = []string{}
+					for _, doc := range docs {
+						observedDocs = append(observedDocs, string(doc))
+					}
+					return tc.givenItems, tc.givenError
+				},
+			}
+
+			router := chi.NewRouter()
+
+			// Batches are held to their own size cap rather than that of a single document.
+			app := &RESTApp{
+				logger:        zap.NewNop(),
+				svc:           mockSvc,
+				maxSumBytes:   6,
+				maxBatchBytes: defaultMaxBatchBytes,
+				maxBatchItems: 3,
+			}
+
+			if tc.givenMaxBytes > 0 {
+				app.maxBatchBytes = tc.givenMaxBytes
+			}
+
+			router.With(app.protect(service.ScopeSum)...).Post("/sum/batch", app.sumBatchHandler)
+
+			req, err := http.NewRequest(http.MethodPost, "/sum/batch", bytes.NewBufferString(tc.givenBody))
+			require.NoError(t, err)
+
+			req.Header.Set("Authorization", "Bearer abcd")
+			req.Header.Set("Content-Type", tc.givenType)
+
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.expectedStatus, w.Code)
+			assert.JSONEq(t, tc.expectedBody, w.Body.String())
+
+			if tc.expectedDocs != nil {
+				assert.Equal(t, tc.expectedDocs, observedDocs)
+			}
+		})
+	}
+}
+
+func TestSumHandler_ndjson(t *testing.T) {
+	testCases := []struct {
+		name          string
+		givenBody     string
+		givenMaxBytes int64
+		expectedData  []any
+		expectedLines []string
+	}{
+		{
+			name:         "a line per document",
+			givenBody:    "[1, 2]\n\n  {\"a\": 1.5}\r\n",
+			expectedData: []any{[]any{json.Number("1"), json.Number("2")}, map[string]any{"a": json.Number("1.5")}},
+			expectedLines: []string{
+				`{"line":1,"sum":"[1 2]","algorithm":"sha256","encoding":"hex"}`,
+				`{"line":3,"sum":"map[a:1.5]","algorithm":"sha256","encoding":"hex"}`,
+			},
+		},
+		{
+			name:         "errors reported by line",
+			givenBody:    "[true]\n[1,\n[1] garbage\n[2] [3]\n[3]",
+			expectedData: []any{[]any{true}, []any{json.Number("3")}},
+			expectedLines: []string{
+				`{"line":1,"error":{"status_code":422,"error":"the value type is unsupported","path":"$[0]"}}`,
+				`{"line":2,"error":{"status_code":400,"error":"the request is invalid"}}`,
+				`{"line":3,"error":{"status_code":400,"error":"the request is invalid"}}`,
+				`{"line":4,"error":{"status_code":400,"error":"the request is invalid"}}`,
+				`{"line":5,"sum":"[3]","algorithm":"sha256","encoding":"hex"}`,
+			},
+		},
+		{
+			name:          "line too long",
+			givenBody:     "[1]\n[1, 2, 3]\n[2]\n",
+			givenMaxBytes: 8,
+			expectedData:  []any{[]any{json.Number("1")}},
+			expectedLines: []string{
+				`{"line":1,"sum":"[1]","algorithm":"sha256","encoding":"hex"}`,
+				`{"line":2,"error":{"status_code":413,"error":"the request body is too large"}}`,
+			},
+		},
+		{
+			name:          "empty body",
+			givenBody:     "",
+			expectedLines: []string{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var observedData []any
+
+			mockSvc := &service.MockService{
+				VerifyTokenFunc: func(ctx context.Context, token string) (*service.Claims, error) {
+					return &service.Claims{Scope: service.ScopeSum}, nil
+				},
+				SumFunc: func(ctx context.Context, data any, opts service.SumOptions) (*service.SumResult, error) {
+					assert.Equal(t, service.StringsStrict, opts.Strings)
+
+					observedData = append(observedData, data)
+					if values, ok := data.([]any); ok && len(values) > 0 && values[0] == true {
+						return nil, fmt.Errorf("could not sum numbers: %w", &service.PathError{Path: "$[0]", Err: service.ErrUnsupportedValueType})
+					}
+					return &service.SumResult{Hash: fmt.Sprint(data), Algorithm: "sha256", Encoding: "hex"}, nil
+				},
+			}
+
+			router := chi.NewRouter()
+
+			app := &RESTApp{
+				logger:      zap.NewNop(),
+				svc:         mockSvc,
+				maxSumBytes: defaultMaxSumBytes,
+			}
+
+			if tc.givenMaxBytes > 0 {
+				app.maxSumBytes = tc.givenMaxBytes
+			}
+
+			router.With(app.protect(service.ScopeSum)...).Post("/sum", app.sumHandler)
+
+			req, err := http.NewRequest(http.MethodPost, "/sum?strings=strict", bytes.NewBufferString(tc.givenBody))
+			require.NoError(t, err)
+
+			req.Header.Set("Authorization", "Bearer abcd")
+			req.Header.Set("Content-Type", "application/x-ndjson")
+
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+			assert.Equal(t, tc.expectedData, observedData)
+
+			observedLines := strings.Split(strings.TrimSuffix(w.Body.String(), "\n"), "\n")
+			if w.Body.Len() == 0 {
+				observedLines = []string{}
+			}
+
+			require.Len(t, observedLines, len(tc.expectedLines))
+			for i, line := range tc.expectedLines {
+				assert.JSONEq(t, line, observedLines[i])
+			}
+		})
+	}
+}
+
+func TestSumHandler_ndjsonStreaming(t *testing.T) {
+	mockSvc := &service.MockService{
+		VerifyTokenFunc: func(ctx context.Context, token string) (*service.Claims, error) {
+			return &service.Claims{Scope: service.ScopeSum}, nil
 		},
-		SumFunc: func(ctx context.Context, data any) (string, error) {
-			return "abcd", nil
+		SumFunc: func(ctx context.Context, data any, opts service.SumOptions) (*service.SumResult, error) {
+			return &service.SumResult{Hash: fmt.Sprint(data), Algorithm: "sha256", Encoding: "hex"}, nil
 		},
 	}
 
 	router := chi.NewRouter()
 
 	app := &RESTApp{
-		logger: zap.NewNop(),
-		svc:    mockSvc,
+		logger:      zap.NewNop(),
+		svc:         mockSvc,
+		maxSumBytes: defaultMaxSumBytes,
 	}
 
-	router.Post("/sum", app.sumHandler)
+	router.With(app.protect(service.ScopeSum)...).Post("/sum", app.sumHandler)
 
-	body := `{"a": 2, "b": 3}`
+	server := httptest.NewServer(router)
+	defer server.Close()
 
-	req, err := http.NewRequest(http.MethodPost, "/sum", bytes.NewBufferString(body))
+	body, lines := io.Pipe()
+
+	req, err := http.NewRequest(http.MethodPost, server.URL+"/sum", body)
 	require.NoError(t, err)
 
-	req.Header.Set("Authorization", "Bearer abcd") // token is required
+	req.Header.Set("Authorization", "Bearer abcd")
+	req.Header.Set("Content-Type", "application/x-ndjson")
 
-	w := httptest.NewRecorder()
+	// The response starts with the result of the first line, so the line is sent along with the request.
+	go lines.Write([]byte("[1]\n"))
 
-	router.ServeHTTP(w, req)
+	resp, err := server.Client().Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "application/x-ndjson", resp.Header.Get("Content-Type"))
+
+	// Each line is answered before the next one is sent.
+	results := bufio.NewReader(resp.Body)
+	for _, doc := range []string{"[1]", "[2]", "[3]"} {
+		if doc != "[1]" {
+			_, err = lines.Write([]byte(doc + "\n"))
+			require.NoError(t, err)
+		}
+
+		result, err := results.ReadString('\n')
+		require.NoError(t, err)
+		assert.Contains(t, result, `"sum":"`+doc+`"`)
+	}
 
-	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
-	assert.Equal(t, http.StatusOK, w.Code)
-	assert.Equal(t, `{"sum":"abcd"}`, strings.TrimSpace(w.Body.String()))
+	require.NoError(t, lines.Close())
+
+	_, err = results.ReadString('\n')
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestSumHandler_ndjsonMatchesSum(t *testing.T) {
+	svc := service.NewDefaultService(zap.NewNop(), []byte("foo-key"))
+
+	// The token is all the real service would fail on, so the rest goes to it.
+	mockSvc := &service.MockService{
+		VerifyTokenFunc: func(ctx context.Context, token string) (*service.Claims, error) {
+			return &service.Claims{Scope: service.ScopeSum}, nil
+		},
+		SumFunc:       svc.Sum,
+		SumStreamFunc: svc.SumStream,
+	}
+
+	router := chi.NewRouter()
+
+	app := &RESTApp{
+		logger:      zap.NewNop(),
+		svc:         mockSvc,
+		maxSumBytes: defaultMaxSumBytes,
+	}
+
+	router.With(app.protect(service.ScopeSum)...).Post("/sum", app.sumHandler)
+
+	sum := func(t *testing.T, contentType, body string) (int, sumItemResponse) {
+		t.Helper()
+
+		req, err := http.NewRequest(http.MethodPost, "/sum?booleans=reject", strings.NewReader(body))
+		require.NoError(t, err)
+
+		req.Header.Set("Authorization", "Bearer abcd")
+		req.Header.Set("Content-Type", contentType)
+
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		var resp sumItemResponse
+		if w.Code != http.StatusOK {
+			resp.Error = &APIError{}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), resp.Error))
+			return w.Code, resp
+		}
+
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return w.Code, resp
+	}
+
+	documents := []string{
+		`[1, 2.5, "3"]`,
+		`[9007199254740993, 1e-30, -1E+2]`,
+		`{"a":1,"a":2}`,
+		`{"a":{"x":1},"b":[2,3],"a":[4,{"a":5,"a":6}]}`,
+		`{"a":[1,true]}`,
+		`[1, 2] 3`,
+		`[1, 2`,
+	}
+
+	for _, doc := range documents {
+		t.Run(doc, func(t *testing.T) {
+			expectedStatus, expected := sum(t, "application/json", doc)
+
+			status, observed := sum(t, "application/x-ndjson", doc+"\n")
+			require.Equal(t, http.StatusOK, status)
+
+			if expected.Error != nil {
+				require.NotNil(t, observed.Error, observed)
+				assert.Equal(t, expectedStatus, observed.Error.StatusCode)
+				assert.Equal(t, *expected.Error, *observed.Error)
+				return
+			}
+
+			assert.Nil(t, observed.Error)
+			assert.Equal(t, expected.Sum, observed.Sum)
+		})
+	}
+}
+
+func TestAggregateHandler(t *testing.T) {
+	testCases := []struct {
+		name             string
+		givenURL         string
+		givenHeaders     map[string]string
+		givenBody        string
+		givenResult      *service.AggregateResult
+		givenError       error
+		expectedOps      []service.Operation
+		expectedInclude  []string
+		expectedDocument string
+		expectedStatus   int
+		expectedBody     string
+	}{
+		{
+			name:             "operations",
+			givenURL:         "/aggregate?op=min,%20max&op=median&alg=sha512",
+			givenBody:        `[3, 1.5, 2]`,
+			givenResult:      &service.AggregateResult{Hashes: map[service.Operation]string{"min": "a", "max": "b", "median": "c"}, Algorithm: "sha512", Encoding: "hex"},
+			expectedOps:      []service.Operation{service.OperationMin, service.OperationMax, service.OperationMedian},
+			expectedDocument: `[3, 1.5, 2]`,
+			expectedStatus:   http.StatusOK,
+			expectedBody:     `{"results":{"max":"b","median":"c","min":"a"},"algorithm":"sha512","encoding":"hex"}`,
+		},
+		{
+			name:             "operations header and envelope",
+			givenURL:         "/aggregate",
+			givenHeaders:     map[string]string{"X-Aggregate-Operations": "count,mean", "X-Sum-Envelope": "true"},
+			givenBody:        `{"document":{"a":[1]},"include":["/a"]}`,
+			givenResult:      &service.AggregateResult{Hashes: map[service.Operation]string{"count": "a", "mean": "b"}, Algorithm: "sha256", Encoding: "hex"},
+			expectedOps:      []service.Operation{service.OperationCount, service.OperationMean},
+			expectedInclude:  []string{"/a"},
+			expectedDocument: `{"a":[1]}`,
+			expectedStatus:   http.StatusOK,
+			expectedBody:     `{"results":{"count":"a","mean":"b"},"algorithm":"sha256","encoding":"hex"}`,
+		},
+		{
+			name:             "unsupported operation",
+			givenURL:         "/aggregate?op=avg",
+			givenBody:        `[1]`,
+			givenError:       fmt.Errorf("could not aggregate numbers: %w", service.ErrUnsupportedOperation),
+			expectedOps:      []service.Operation{"avg"},
+			expectedDocument: `[1]`,
+			expectedStatus:   http.StatusBadRequest,
+			expectedBody:     `{"status_code":400,"error":"the operation is unsupported"}`,
+		},
+		{
+			name:             "no numbers",
+			givenURL:         "/aggregate?op=mean",
+			givenBody:        `["dark"]`,
+			givenError:       fmt.Errorf("could not compute mean: %w", service.ErrNoValues),
+			expectedOps:      []service.Operation{service.OperationMean},
+			expectedDocument: `["dark"]`,
+			expectedStatus:   http.StatusUnprocessableEntity,
+			expectedBody:     `{"status_code":422,"error":"there are no numbers to aggregate"}`,
+		},
+		{
+			name:             "rejected value",
+			givenURL:         "/aggregate?op=max&booleans=reject",
+			givenBody:        `[1, true]`,
+			givenError:       &service.PathError{Path: "$[1]", Err: service.ErrUnsupportedValueType},
+			expectedOps:      []service.Operation{service.OperationMax},
+			expectedDocument: `[1, true]`,
+			expectedStatus:   http.StatusUnprocessableEntity,
+			expectedBody:     `{"status_code":422,"error":"the value type is unsupported","path":"$[1]"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var (
+				observedOps      []service.Operation
+				observedOpts     service.SumOptions
+				observedDocument []byte
+			)
+
+			mockSvc := &service.MockService{
+				VerifyTokenFunc: func(ctx context.Context, token string) (*service.Claims, error) {
+					return &service.Claims{Scope: service.ScopeSum}, nil
+				},
+				AggregateFunc: func(ctx context.Context, r io.Reader, ops []service.Operation, opts service.SumOptions) (*service.AggregateResult, error) {
+					observedOps, observedOpts = ops, opts
+
+					var err error
+					if observedDocument, err = io.ReadAll(r); err != nil {
+						return nil, err
+					}
+					return tc.givenResult, tc.givenError
+				},
+			}
+
+			router := chi.NewRouter()
+
+			app := &RESTApp{
+				logger:      zap.NewNop(),
+				svc:         mockSvc,
+				maxSumBytes: defaultMaxSumBytes,
+			}
+
+			router.With(app.protect(service.ScopeSum)...).Post("/aggregate", app.aggregateHandler)
+
+			req, err := http.NewRequest(http.MethodPost, tc.givenURL, bytes.NewBufferString(tc.givenBody))
+			require.NoError(t, err)
+
+			req.Header.Set("Authorization", "Bearer abcd")
+			for k, v := range tc.givenHeaders {
+				req.Header.Set(k, v)
+			}
+
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.expectedStatus, w.Code)
+			assert.JSONEq(t, tc.expectedBody, w.Body.String())
+
+			assert.Equal(t, tc.expectedOps, observedOps)
+			assert.Equal(t, tc.expectedInclude, observedOpts.Include)
+			assert.Equal(t, tc.expectedDocument, string(observedDocument))
+		})
+	}
+}
+
+func TestAggregateHandler_requestTooLarge(t *testing.T) {
+	mockSvc := &service.MockService{
+		VerifyTokenFunc: func(ctx context.Context, token string) (*service.Claims, error) {
+			return &service.Claims{Scope: service.ScopeSum}, nil
+		},
+		AggregateFunc: func(ctx context.Context, r io.Reader, ops []service.Operation, opts service.SumOptions) (*service.AggregateResult, error) {
+			_, err := io.ReadAll(r)
+			return nil, err
+		},
+	}
+
+	router := chi.NewRouter()
+
+	app := &RESTApp{
+		logger:      zap.NewNop(),
+		svc:         mockSvc,
+		maxSumBytes: 8,
+	}
+
+	router.With(app.protect(service.ScopeSum)...).Post("/aggregate", app.aggregateHandler)
+
+	for _, url := range []string{"/aggregate?op=min", "/aggregate?op=min&envelope=true"} {
+		req, err := http.NewRequest(http.MethodPost, url, bytes.NewBufferString(`{"document":[1,2,3,4,5]}`))
+		require.NoError(t, err)
+
+		req.Header.Set("Authorization", "Bearer abcd")
+
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code, url)
+	}
 }
 
 func TestSumHandler_serviceError(t *testing.T) {
 	mockSvc := &service.MockService{
-		VerifyTokenFunc: func(ctx context.Context, token string) error {
-			return nil
+		VerifyTokenFunc: func(ctx context.Context, token string) (*service.Claims, error) {
+			return &service.Claims{Scope: service.ScopeSum}, nil
 		},
-		SumFunc: func(ctx context.Context, data any) (string, error) {
-			return "", errors.New("foo-error")
+		SumStreamFunc: func(ctx context.Context, r io.Reader, opts service.SumOptions) (*service.SumResult, error) {
+			return nil, errors.New("foo-error")
 		},
 	}
 
@@ -198,7 +1753,7 @@ func TestSumHandler_serviceError(t *testing.T) {
 		svc:    mockSvc,
 	}
 
-	router.Post("/sum", app.sumHandler)
+	router.With(app.protect(service.ScopeSum)...).Post("/sum", app.sumHandler)
 
 	body := `{"a": 2, "b": 3}`
 
@@ -220,12 +1775,103 @@ func TestSumHandler_serviceError(t *testing.T) {
 	assert.Equal(t, ErrInternal, respErr)
 }
 
+func TestSumHandler_limits(t *testing.T) {
+	testCases := []struct {
+		name              string
+		givenBody         string
+		givenServiceError error
+		expectedStatus    int
+		expectedError     APIError
+	}{
+		{
+			name:           "body too large",
+			givenBody:      `[` + strings.Repeat(`1,`, 64) + `1]`,
+			expectedStatus: http.StatusRequestEntityTooLarge,
+			expectedError:  ErrRequestTooLarge,
+		},
+		{
+			name:              "too many values",
+			givenBody:         `[1, 2, 3]`,
+			givenServiceError: fmt.Errorf("could not sum numbers: %w", service.ErrDocumentTooLarge),
+			expectedStatus:    http.StatusRequestEntityTooLarge,
+			expectedError:     ErrDocumentTooLarge,
+		},
+		{
+			name:              "too deep",
+			givenBody:         `[[[1]]]`,
+			givenServiceError: fmt.Errorf("could not sum numbers: %w", service.ErrDocumentTooDeep),
+			expectedStatus:    http.StatusUnprocessableEntity,
+			expectedError:     ErrDocumentTooDeep,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockSvc := &service.MockService{
+				VerifyTokenFunc: func(ctx context.Context, token string) (*service.Claims, error) {
+					return &service.Claims{Scope: service.ScopeSum}, nil
+				},
+				SumStreamFunc: func(ctx context.Context, r io.Reader, opts service.SumOptions) (*service.SumResult, error) {
+					if _, err := io.Copy(io.Discard, r); err != nil {
+						return nil, err
+					}
+					return nil, tc.givenServiceError
+				},
+			}
+
+			router := chi.NewRouter()
+
+			app := &RESTApp{
+				logger:      zap.NewNop(),
+				svc:         mockSvc,
+				maxSumBytes: 64,
+			}
+
+			router.With(app.protect(service.ScopeSum)...).Post("/sum", app.sumHandler)
+
+			req, err := http.NewRequest(http.MethodPost, "/sum", bytes.NewBufferString(tc.givenBody))
+			require.NoError(t, err)
+
+			req.Header.Set("Authorization", "Bearer abcd")
+
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.expectedStatus, w.Code)
+
+			var respErr APIError
+			assert.NoError(t, json.NewDecoder(w.Body).Decode(&respErr))
+
+			assert.Equal(t, tc.expectedError, respErr)
+		})
+	}
+}
+
+func TestSumHandler_unauthenticatedBodyIsNotParsed(t *testing.T) {
+	router := chi.NewRouter()
+
+	app := &RESTApp{logger: zap.NewNop()}
+
+	router.With(app.protect(service.ScopeSum)...).Post("/sum", app.sumHandler)
+
+	req, err := http.NewRequest(http.MethodPost, "/sum", bytes.NewBufferString(`{"a": `))
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	// Without a token the malformed body is never decoded, so it can't turn the 401 into a 400.
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
 func TestSumHandler_invalidToken(t *testing.T) {
 	router := chi.NewRouter()
 
 	app := &RESTApp{logger: zap.NewNop()}
 
-	router.Post("/sum", app.sumHandler)
+	router.With(app.protect(service.ScopeSum)...).Post("/sum", app.sumHandler)
 
 	body := `{"a": 2, "b": 3}`
 
@@ -246,3 +1892,220 @@ func TestSumHandler_invalidToken(t *testing.T) {
 
 	assert.Equal(t, ErrUnauthorized, respErr)
 }
+
+func TestJWKSHandler(t *testing.T) {
+	mockSvc := &service.MockService{
+		ProviderMetadataFunc: func(ctx context.Context) (*service.ProviderMetadata, error) {
+			return &service.ProviderMetadata{
+				Issuer:            "foo-issuer",
+				SigningAlgorithms: []string{"EdDSA"},
+				Keys: []service.JWK{
+					{KeyID: "foo-kid", KeyType: "OKP", Algorithm: "EdDSA", Curve: "Ed25519", X: "bar-x"},
+				},
+			}, nil
+		},
+	}
+
+	router := chi.NewRouter()
+
+	app := &RESTApp{
+		logger: zap.NewNop(),
+		svc:    mockSvc,
+	}
+
+	router.Get("/.well-known/jwks.json", app.jwksHandler)
+
+	req, err := http.NewRequest(http.MethodGet, "/.well-known/jwks.json", http.NoBody)
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "public, max-age=300", w.Header().Get("Cache-Control"))
+	assert.JSONEq(t, `{"keys":[{"kty":"OKP","use":"sig","kid":"foo-kid","alg":"EdDSA","crv":"Ed25519","x":"bar-x"}]}`, w.Body.String())
+}
+
+func TestJWKSHandler_noPublicKeys(t *testing.T) {
+	mockSvc := &service.MockService{
+		ProviderMetadataFunc: func(ctx context.Context) (*service.ProviderMetadata, error) {
+			return &service.ProviderMetadata{Issuer: "foo-issuer"}, nil
+		},
+	}
+
+	router := chi.NewRouter()
+
+	app := &RESTApp{
+		logger: zap.NewNop(),
+		svc:    mockSvc,
+	}
+
+	router.Get("/.well-known/jwks.json", app.jwksHandler)
+
+	req, err := http.NewRequest(http.MethodGet, "/.well-known/jwks.json", http.NoBody)
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"keys":[]}`, w.Body.String())
+}
+
+func TestOpenIDConfigurationHandler(t *testing.T) {
+	mockSvc := &service.MockService{
+		ProviderMetadataFunc: func(ctx context.Context) (*service.ProviderMetadata, error) {
+			return &service.ProviderMetadata{
+				Issuer:            "foo-issuer",
+				SigningAlgorithms: []string{"ES256", "EdDSA"},
+			}, nil
+		},
+	}
+
+	testCases := []struct {
+		name            string
+		givenBaseURL    string
+		expectedJWKSURI string
+	}{
+		{
+			name:            "derived from the request",
+			expectedJWKSURI: "http://example.com/.well-known/jwks.json",
+		},
+		{
+			name:            "configured base URL",
+			givenBaseURL:    "https://auth.example.com/",
+			expectedJWKSURI: "https://auth.example.com/.well-known/jwks.json",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			router := chi.NewRouter()
+
+			app := &RESTApp{
+				logger: zap.NewNop(),
+				svc:    mockSvc,
+			}
+			WithBaseURL(tc.givenBaseURL)(app)
+
+			router.Get("/.well-known/openid-configuration", app.openIDConfigurationHandler)
+
+			req, err := http.NewRequest(http.MethodGet, "http://example.com/.well-known/openid-configuration", http.NoBody)
+			require.NoError(t, err)
+
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+
+			var resp openIDConfigurationResponse
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+
+			assert.Equal(t, "foo-issuer", resp.Issuer)
+			assert.Equal(t, tc.expectedJWKSURI, resp.JWKSURI)
+			assert.Equal(t, strings.TrimSuffix(tc.expectedJWKSURI, "/.well-known/jwks.json")+"/oauth/token", resp.TokenEndpoint)
+			assert.Equal(t, []string{"ES256", "EdDSA"}, resp.IDTokenSigningAlgValuesSupported)
+		})
+	}
+}
+
+func TestOpenIDConfigurationHandler_noPublicKeys(t *testing.T) {
+	mockSvc := &service.MockService{
+		ProviderMetadataFunc: func(ctx context.Context) (*service.ProviderMetadata, error) {
+			return &service.ProviderMetadata{Issuer: "foo-issuer"}, nil
+		},
+	}
+
+	router := chi.NewRouter()
+
+	app := &RESTApp{
+		logger: zap.NewNop(),
+		svc:    mockSvc,
+	}
+
+	router.Get("/.well-known/openid-configuration", app.openIDConfigurationHandler)
+
+	req, err := http.NewRequest(http.MethodGet, "/.well-known/openid-configuration", http.NoBody)
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.JSONEq(t, `{"status_code":404,"error":"no public keys are configured to verify tokens with"}`, w.Body.String())
+}
+
+func TestAuthHandler_lockout(t *testing.T) {
+	var observedCreds service.Credentials
+
+	mockSvc := &service.MockService{
+		GenerateTokenFunc: func(ctx context.Context, creds service.Credentials) (*service.Token, error) {
+			observedCreds = creds
+			return nil, &service.LockoutError{RetryAfter: 1500 * time.Millisecond}
+		},
+	}
+
+	router := chi.NewRouter()
+
+	app := &RESTApp{
+		logger: zap.NewNop(),
+		svc:    mockSvc,
+	}
+
+	router.Post("/auth", app.authHandler)
+
+	body := `{"username": "test-user", "password": "test-pass"}`
+
+	req, err := http.NewRequest(http.MethodPost, "/auth", bytes.NewBufferString(body))
+	require.NoError(t, err)
+	req.RemoteAddr = "192.0.2.1:54321"
+
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "2", w.Header().Get("Retry-After"))
+	assert.Equal(t, "192.0.2.1", observedCreds.RemoteIP)
+
+	var respErr APIError
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&respErr))
+
+	assert.Equal(t, ErrTooManyAttempts, respErr)
+}
+
+func TestOAuthTokenHandler_lockout(t *testing.T) {
+	mockSvc := &service.MockService{
+		GrantFunc: func(ctx context.Context, req service.GrantRequest) (*service.Token, error) {
+			return nil, fmt.Errorf("could not authenticate: %w", &service.LockoutError{RetryAfter: time.Minute})
+		},
+	}
+
+	router := chi.NewRouter()
+
+	app := &RESTApp{
+		logger: zap.NewNop(),
+		svc:    mockSvc,
+	}
+
+	router.Post(oauthTokenPath, app.oauthTokenHandler)
+
+	form := "grant_type=password&username=test-user&password=test-pass"
+
+	req, err := http.NewRequest(http.MethodPost, oauthTokenPath, strings.NewReader(form))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth("foo-client", "foo-secret")
+
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "60", w.Header().Get("Retry-After"))
+	assert.JSONEq(t, `{"error":"invalid_grant","error_description":"too many failed attempts, try again later"}`, w.Body.String())
+}
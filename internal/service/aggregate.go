@@ -0,0 +1,342 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+)
+
+// Operation selects what Aggregate computes over the numbers found in a document.
+type Operation string
+
+const (
+	// OperationSum adds the numbers up, as Sum does.
+	OperationSum Operation = "sum"
+
+	// OperationCount counts the numbers, and hashes the count as an integer with either arithmetic.
+	OperationCount Operation = "count"
+
+	// OperationMin keeps the least number.
+	OperationMin Operation = "min"
+
+	// OperationMax keeps the greatest number.
+	OperationMax Operation = "max"
+
+	// OperationMean divides the sum of the numbers by their count. The exact arithmetic rounds it half to even
+	// to meanDigits more fractional digits than the numbers have, so the mean of 1 and 2 is 1.5 and that of
+	// 1, 1 and 2 is 1.33333333333333333333.
+	OperationMean Operation = "mean"
+
+	// OperationProduct multiplies the numbers together, and is 1 for documents without numbers.
+	// Exact products beyond the range of the numbers accepted fail with ErrNumberOutOfRange.
+	OperationProduct Operation = "product"
+
+	// OperationMedian keeps the middle number, or the mean of the two middle numbers for even counts.
+	OperationMedian Operation = "median"
+)
+
+// meanDigits is how many fractional digits exact means keep beyond those of the numbers averaged.
+const meanDigits = 20
+
+// maxProductScale bounds the fractional digits of exact products, which otherwise grow with every factor.
+const maxProductScale = 2 * maxDecimalExponent
+
+// ParseOperation parses the name of an operation.
+func ParseOperation(s string) (Operation, error) {
+	switch op := Operation(s); op {
+	case OperationSum, OperationCount, OperationMin, OperationMax, OperationMean, OperationProduct, OperationMedian:
+		return op, nil
+	default:
+		return "", fmt.Errorf("unknown operation %q: %w", s, ErrUnsupportedOperation)
+	}
+}
+
+// reducer reduces the numbers found in a document to a single result.
+type reducer interface {
+	// add adds the number represented by the literal s.
+	add(s string) error
+
+	// result returns the representation of the result that gets hashed. Operations that have no result
+	// without numbers, such as the mean, fail with ErrNoValues.
+	result() (string, error)
+}
+
+// newReducer returns the reducer computing op with the arithmetic a.
+func newReducer(op Operation, a Arithmetic) reducer {
+	if a == ArithmeticFloat {
+		switch op {
+		case OperationCount:
+			return &countReducer{parse: func(s string) error {
+				_, err := parseFiniteFloat(s)
+				return err
+			}}
+		case OperationMin:
+			return &floatExtreme{sign: -1}
+		case OperationMax:
+			return &floatExtreme{sign: 1}
+		case OperationMean:
+			return &floatMean{}
+		case OperationProduct:
+			return &floatProduct{product: 1}
+		case OperationMedian:
+			return &floatMedian{}
+		default:
+			return &floatSum{}
+		}
+	}
+
+	switch op {
+	case OperationCount:
+		return &countReducer{parse: func(s string) error {
+			_, err := parseExactNumber(s)
+			return err
+		}}
+	case OperationMin:
+		return &exactExtreme{sign: -1}
+	case OperationMax:
+		return &exactExtreme{sign: 1}
+	case OperationMean:
+		return &exactMean{exactSum: exactSum{sum: newDecimal()}}
+	case OperationProduct:
+		return &exactProduct{product: decimal{unscaled: big.NewInt(1)}}
+	case OperationMedian:
+		return &exactMedian{}
+	default:
+		return &exactSum{sum: newDecimal()}
+	}
+}
+
+// countReducer counts the numbers that parse.
+type countReducer struct {
+	parse func(s string) error
+	n     int
+}
+
+func (r *countReducer) add(s string) error {
+	if err := r.parse(s); err != nil {
+		return err
+	}
+
+	r.n++
+	return nil
+}
+
+func (r *countReducer) result() (string, error) {
+	return strconv.Itoa(r.n), nil
+}
+
+// exactExtreme keeps the least number, or the greatest one when sign is 1.
+type exactExtreme struct {
+	sign  int
+	value decimal
+	seen  bool
+}
+
+func (r *exactExtreme) add(s string) error {
+	num, err := parseExactNumber(s)
+	if err != nil {
+		return err
+	}
+
+	if !r.seen || num.cmp(r.value) == r.sign {
+		r.value, r.seen = num, true
+	}
+	return nil
+}
+
+func (r *exactExtreme) result() (string, error) {
+	if !r.seen {
+		return "", ErrNoValues
+	}
+	return r.value.String(), nil
+}
+
+// exactMean averages numbers with the exact arithmetic.
+type exactMean struct {
+	exactSum
+	n int64
+}
+
+func (r *exactMean) add(s string) error {
+	if err := r.exactSum.add(s); err != nil {
+		return err
+	}
+
+	r.n++
+	return nil
+}
+
+func (r *exactMean) result() (string, error) {
+	if r.n == 0 {
+		return "", ErrNoValues
+	}
+
+	scale := meanDigits
+	if r.sum.scale > 0 {
+		scale += r.sum.scale
+	}
+	return r.sum.quo(r.n, scale).String(), nil
+}
+
+// exactProduct multiplies numbers with the exact arithmetic.
+type exactProduct struct {
+	product decimal
+}
+
+func (r *exactProduct) add(s string) error {
+	num, err := parseExactNumber(s)
+	if err != nil {
+		return err
+	}
+
+	product := r.product.mul(num).trim()
+	if product.exponent() > maxDecimalExponent || product.scale > maxProductScale {
+		return fmt.Errorf("could not multiply %s by %s within the range of numbers: %w", r.product, s, ErrNumberOutOfRange)
+	}
+
+	r.product = product
+	return nil
+}
+
+func (r *exactProduct) result() (string, error) {
+	return r.product.String(), nil
+}
+
+// exactMedian keeps every number to find the middle ones with the exact arithmetic.
+type exactMedian struct {
+	values []decimal
+}
+
+func (r *exactMedian) add(s string) error {
+	num, err := parseExactNumber(s)
+	if err != nil {
+		return err
+	}
+
+	r.values = append(r.values, num)
+	return nil
+}
+
+func (r *exactMedian) result() (string, error) {
+	n := len(r.values)
+	if n == 0 {
+		return "", ErrNoValues
+	}
+
+	sort.Slice(r.values, func(i, j int) bool {
+		return r.values[i].cmp(r.values[j]) < 0
+	})
+
+	if n%2 == 1 {
+		return r.values[n/2].String(), nil
+	}
+
+	// Halving a decimal takes one more fractional digit at most.
+	sum := r.values[n/2-1].add(r.values[n/2])
+	return sum.quo(2, sum.scale+1).String(), nil
+}
+
+// floatExtreme keeps the least number, or the greatest one when sign is 1, as float64.
+type floatExtreme struct {
+	sign  int
+	value float64
+	seen  bool
+}
+
+func (r *floatExtreme) add(s string) error {
+	num, err := parseFiniteFloat(s)
+	if err != nil {
+		return err
+	}
+
+	if !r.seen || r.sign < 0 && num < r.value || r.sign > 0 && num > r.value {
+		r.value, r.seen = num, true
+	}
+	return nil
+}
+
+func (r *floatExtreme) result() (string, error) {
+	if !r.seen {
+		return "", ErrNoValues
+	}
+	return formatFloat(r.value), nil
+}
+
+// floatMean averages numbers as float64.
+type floatMean struct {
+	floatSum
+	n int
+}
+
+func (r *floatMean) add(s string) error {
+	if err := r.floatSum.add(s); err != nil {
+		return err
+	}
+
+	r.n++
+	return nil
+}
+
+func (r *floatMean) result() (string, error) {
+	if r.n == 0 {
+		return "", ErrNoValues
+	}
+	return formatFloat(r.sum / float64(r.n)), nil
+}
+
+// floatProduct multiplies numbers as float64.
+type floatProduct struct {
+	product float64
+}
+
+func (r *floatProduct) add(s string) error {
+	num, err := parseFiniteFloat(s)
+	if err != nil {
+		return err
+	}
+
+	product := r.product * num
+	if math.IsInf(product, 0) {
+		return fmt.Errorf("could not multiply %g by %s without overflowing: %w", r.product, s, ErrNonFiniteResult)
+	}
+
+	r.product = product
+	return nil
+}
+
+func (r *floatProduct) result() (string, error) {
+	return formatFloat(r.product), nil
+}
+
+// floatMedian keeps every number to find the middle ones as float64.
+type floatMedian struct {
+	values []float64
+}
+
+func (r *floatMedian) add(s string) error {
+	num, err := parseFiniteFloat(s)
+	if err != nil {
+		return err
+	}
+
+	r.values = append(r.values, num)
+	return nil
+}
+
+func (r *floatMedian) result() (string, error) {
+	n := len(r.values)
+	if n == 0 {
+		return "", ErrNoValues
+	}
+
+	sort.Float64s(r.values)
+
+	if n%2 == 1 {
+		return formatFloat(r.values[n/2]), nil
+	}
+
+	// Halving first keeps the mean of two large numbers from overflowing.
+	return formatFloat(r.values[n/2-1]/2 + r.values[n/2]/2), nil
+}
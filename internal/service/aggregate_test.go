@@ -0,0 +1,154 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestParseOperation(t *testing.T) {
+	t.Parallel()
+
+	for _, op := range []Operation{OperationSum, OperationCount, OperationMin, OperationMax, OperationMean, OperationProduct, OperationMedian} {
+		observed, err := ParseOperation(string(op))
+		require.NoError(t, err)
+		assert.Equal(t, op, observed)
+	}
+
+	_, err := ParseOperation("avg")
+	assert.True(t, errors.Is(err, ErrUnsupportedOperation), err)
+}
+
+func TestReduceNumbers(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name          string
+		givenData     any
+		givenOp       Operation
+		givenArith    Arithmetic
+		expected      string
+		expectedError error
+	}{
+		{name: "sum", givenData: []any{"0.1", "0.2", 3}, givenOp: OperationSum, givenArith: ArithmeticExact, expected: "3.3"},
+		{name: "count", givenData: []any{"0.1", "0.2", 3}, givenOp: OperationCount, givenArith: ArithmeticExact, expected: "3"},
+		{name: "float count", givenData: []any{"0.1", "0.2", 3}, givenOp: OperationCount, givenArith: ArithmeticFloat, expected: "3"},
+		{name: "min", givenData: []any{"2", "-0.5", 1}, givenOp: OperationMin, givenArith: ArithmeticExact, expected: "-0.5"},
+		{name: "float min", givenData: []any{"2", "-0.5", 1}, givenOp: OperationMin, givenArith: ArithmeticFloat, expected: "-0.500000"},
+		{name: "max", givenData: []any{"9007199254740993", "9007199254740992"}, givenOp: OperationMax, givenArith: ArithmeticExact, expected: "9007199254740993"},
+		{name: "float max", givenData: []any{"2", "-0.5", 1}, givenOp: OperationMax, givenArith: ArithmeticFloat, expected: "2.000000"},
+		{name: "mean", givenData: []any{1, 2}, givenOp: OperationMean, givenArith: ArithmeticExact, expected: "1.5"},
+		{name: "mean rounded", givenData: []any{1, 1, 2}, givenOp: OperationMean, givenArith: ArithmeticExact, expected: "1.33333333333333333333"},
+		{name: "mean of fractions", givenData: []any{"0.01", "0.01", "0.02"}, givenOp: OperationMean, givenArith: ArithmeticExact, expected: "0.0133333333333333333333"},
+		{name: "float mean", givenData: []any{1, 1, 2}, givenOp: OperationMean, givenArith: ArithmeticFloat, expected: "1.333333"},
+		{name: "product", givenData: []any{"0.1", "0.2", -3}, givenOp: OperationProduct, givenArith: ArithmeticExact, expected: "-0.06"},
+		{name: "float product", givenData: []any{"0.5", 4}, givenOp: OperationProduct, givenArith: ArithmeticFloat, expected: "2.000000"},
+		{name: "empty product", givenData: []any{}, givenOp: OperationProduct, givenArith: ArithmeticExact, expected: "1"},
+		{name: "median", givenData: []any{3, 1, 2}, givenOp: OperationMedian, givenArith: ArithmeticExact, expected: "2"},
+		{name: "median of even count", givenData: []any{4, 1, "0.5", 3}, givenOp: OperationMedian, givenArith: ArithmeticExact, expected: "2"},
+		{name: "median halved", givenData: []any{1, 2}, givenOp: OperationMedian, givenArith: ArithmeticExact, expected: "1.5"},
+		{name: "float median", givenData: []any{4, 1, "0.5", 3}, givenOp: OperationMedian, givenArith: ArithmeticFloat, expected: "2.000000"},
+		{name: "empty count", givenData: []any{}, givenOp: OperationCount, givenArith: ArithmeticExact, expected: "0"},
+		{name: "empty min", givenData: []any{"dark"}, givenOp: OperationMin, givenArith: ArithmeticExact, expectedError: ErrNoValues},
+		{name: "empty mean", givenData: map[string]any{}, givenOp: OperationMean, givenArith: ArithmeticFloat, expectedError: ErrNoValues},
+		{name: "empty median", givenData: []any{}, givenOp: OperationMedian, givenArith: ArithmeticExact, expectedError: ErrNoValues},
+		{name: "product out of range", givenData: []any{"1e600", "1e600"}, givenOp: OperationProduct, givenArith: ArithmeticExact, expectedError: ErrNumberOutOfRange},
+		{name: "product too precise", givenData: []any{"1e-1000", "1e-1000", "0.3"}, givenOp: OperationProduct, givenArith: ArithmeticExact, expectedError: ErrNumberOutOfRange},
+		{name: "float product overflow", givenData: []any{"1e300", "1e300"}, givenOp: OperationProduct, givenArith: ArithmeticFloat, expectedError: ErrNonFiniteResult},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := newReducer(tc.givenOp, tc.givenArith)
+
+			err := reduceNumbers(tc.givenData, sumRules{arithmetic: tc.givenArith, strings: StringsLenient}, r)
+			if err == nil {
+				var observed string
+				observed, err = r.result()
+				assert.Equal(t, tc.expected, observed)
+			}
+			assert.True(t, errors.Is(err, tc.expectedError), err)
+		})
+	}
+
+	t.Run("products keep their scale down", func(t *testing.T) {
+		data := make([]any, 3000)
+		for i := range data {
+			data[i] = "1.0"
+		}
+
+		r := newReducer(OperationProduct, ArithmeticExact)
+		require.NoError(t, reduceNumbers(data, sumRules{arithmetic: ArithmeticExact, strings: StringsStrict}, r))
+
+		observed, err := r.result()
+		require.NoError(t, err)
+		assert.Equal(t, "1", observed)
+	})
+}
+
+func TestDefaultService_Aggregate(t *testing.T) {
+	t.Parallel()
+
+	doc := `{"id": 42, "items": [{"price": 1.5}, {"price": 2}, {"price": "4"}]}`
+
+	service := NewDefaultService(zap.NewNop(), []byte("foo-key"))
+
+	t.Run("hashes each result as Sum does", func(t *testing.T) {
+		opts := SumOptions{Include: []string{"$..price"}, Strings: StringsStrict, Encoding: EncodingBase64URL}
+
+		observed, err := service.Aggregate(context.TODO(), strings.NewReader(doc), []Operation{OperationSum, OperationMax, OperationMedian}, opts)
+		require.NoError(t, err)
+
+		sum, err := service.SumStream(context.TODO(), strings.NewReader(doc), opts)
+		require.NoError(t, err)
+
+		max, err := service.digestSum("4", opts)
+		require.NoError(t, err)
+
+		median, err := service.digestSum("2", opts)
+		require.NoError(t, err)
+
+		assert.Equal(t, &AggregateResult{
+			Hashes: map[Operation]string{
+				OperationSum:    sum.Hash,
+				OperationMax:    max.Hash,
+				OperationMedian: median.Hash,
+			},
+			Algorithm: DigestSHA256,
+			Encoding:  EncodingBase64URL,
+		}, observed)
+	})
+
+	t.Run("no operations", func(t *testing.T) {
+		_, err := service.Aggregate(context.TODO(), strings.NewReader(doc), nil, SumOptions{})
+		assert.True(t, errors.Is(err, ErrUnsupportedOperation), err)
+	})
+
+	t.Run("unsupported operation", func(t *testing.T) {
+		_, err := service.Aggregate(context.TODO(), strings.NewReader(doc), []Operation{OperationMin, "avg"}, SumOptions{})
+		assert.True(t, errors.Is(err, ErrUnsupportedOperation), err)
+	})
+
+	t.Run("no numbers", func(t *testing.T) {
+		_, err := service.Aggregate(context.TODO(), strings.NewReader(`{"a": "b"}`), []Operation{OperationCount, OperationMean}, SumOptions{})
+		assert.True(t, errors.Is(err, ErrNoValues), err)
+	})
+
+	t.Run("rejected value", func(t *testing.T) {
+		_, err := service.Aggregate(context.TODO(), strings.NewReader(`[1, true]`), []Operation{OperationMin}, SumOptions{Booleans: LiteralsReject})
+
+		var pathErr *PathError
+		require.True(t, errors.As(err, &pathErr), err)
+		assert.Equal(t, "$[1]", pathErr.Path)
+	})
+
+	t.Run("malformed document", func(t *testing.T) {
+		_, err := service.Aggregate(context.TODO(), strings.NewReader(`[1,`), []Operation{OperationMin}, SumOptions{})
+		assert.True(t, errors.Is(err, ErrDocumentInvalid), err)
+	})
+}
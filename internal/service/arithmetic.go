@@ -0,0 +1,113 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// Arithmetic selects how Sum adds up the numbers found in a document.
+type Arithmetic string
+
+const (
+	// ArithmeticExact sums numbers exactly and hashes the canonical decimal string of the result.
+	ArithmeticExact Arithmetic = "exact"
+
+	// ArithmeticFloat sums numbers as float64 and hashes the result formatted with %f.
+	// It is kept for clients that still depend on the hashes produced by earlier releases.
+	ArithmeticFloat Arithmetic = "float"
+)
+
+// ParseArithmetic parses the name of an arithmetic mode.
+func ParseArithmetic(s string) (Arithmetic, error) {
+	switch a := Arithmetic(s); a {
+	case ArithmeticExact, ArithmeticFloat:
+		return a, nil
+	default:
+		return "", fmt.Errorf("unknown arithmetic %q", s)
+	}
+}
+
+// exactSum sums numbers with the exact arithmetic.
+type exactSum struct {
+	sum decimal
+}
+
+func (a *exactSum) add(s string) error {
+	num, err := parseExactNumber(s)
+	if err != nil {
+		return err
+	}
+
+	a.sum = a.sum.add(num)
+	return nil
+}
+
+// parseExactNumber parses the number represented by the literal s for the exact arithmetic.
+func parseExactNumber(s string) (decimal, error) {
+	num, err := parseDecimal(s)
+	if errors.Is(err, errDecimalSyntax) {
+		// Strings may still hold anything strconv understands, such as hexadecimal floats.
+		f, ferr := parseFiniteFloat(s)
+		if ferr != nil {
+			return decimal{}, ferr
+		}
+		num, err = decimalFromFloat(f)
+	}
+	if err != nil {
+		return decimal{}, fmt.Errorf("could not parse number %q: %w", s, err)
+	}
+	return num, nil
+}
+
+func (a *exactSum) result() (string, error) {
+	return a.sum.String(), nil
+}
+
+// floatSum sums numbers as float64.
+type floatSum struct {
+	sum float64
+}
+
+func (a *floatSum) add(s string) error {
+	num, err := parseFiniteFloat(s)
+	if err != nil {
+		return err
+	}
+
+	sum := a.sum + num
+	if math.IsInf(sum, 0) {
+		return fmt.Errorf("could not add %s to %g without overflowing: %w", s, a.sum, ErrNonFiniteResult)
+	}
+
+	a.sum = sum
+	return nil
+}
+
+// parseFiniteFloat parses s with strconv.ParseFloat, refusing NaN, infinities,
+// and numbers beyond the range of float64 with ErrNonFiniteResult.
+func parseFiniteFloat(s string) (float64, error) {
+	f, err := strconv.ParseFloat(s, 64)
+	if errors.Is(err, strconv.ErrRange) && math.IsInf(f, 0) {
+		return 0, fmt.Errorf("could not sum %q beyond the range of float64: %w", s, ErrNonFiniteResult)
+	}
+
+	if err != nil {
+		return 0, fmt.Errorf("could not parse string to float: %s, %w", err, ErrUnsupportedValueType)
+	}
+
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return 0, fmt.Errorf("could not sum %q: %w", s, ErrNonFiniteResult)
+	}
+	return f, nil
+}
+
+func (a *floatSum) result() (string, error) {
+	return formatFloat(a.sum), nil
+}
+
+// formatFloat returns the representation of the results of the float arithmetic that gets hashed.
+func formatFloat(f float64) string {
+	return fmt.Sprintf("%f", f)
+}
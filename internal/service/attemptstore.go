@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// AttemptStore counts the failed login attempts made against a key, such as a username or an IP address.
+type AttemptStore interface {
+	// Attempts returns the failed attempts recorded under key, or zero Attempts if there are none.
+	Attempts(ctx context.Context, key string) (Attempts, error)
+
+	// RecordFailure counts a failed attempt made under key at the given time and returns the updated attempts.
+	// Attempts older than window are forgotten, so the count restarts after window without failures.
+	RecordFailure(ctx context.Context, key string, at time.Time, window time.Duration) (Attempts, error)
+
+	// Reset forgets the failed attempts recorded under key.
+	Reset(ctx context.Context, key string) error
+}
+
+// Attempts are the failed login attempts recorded under a key.
+type Attempts struct {
+	Failures    int
+	LastFailure time.Time
+}
+
+var _ AttemptStore = &MemoryAttemptStore{}
+
+type attemptsEntry struct {
+	Attempts
+	expiresAt time.Time
+}
+
+// MemoryAttemptStore keeps failed attempts in memory until their window is over.
+type MemoryAttemptStore struct {
+	mu        sync.Mutex
+	attempts  map[string]attemptsEntry
+	nextSweep time.Time
+}
+
+// NewMemoryAttemptStore creates an empty MemoryAttemptStore.
+func NewMemoryAttemptStore() *MemoryAttemptStore {
+	return &MemoryAttemptStore{
+		attempts: make(map[string]attemptsEntry),
+	}
+}
+
+// Attempts returns the failed attempts recorded under key.
+func (s *MemoryAttemptStore) Attempts(_ context.Context, key string) (Attempts, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.attempts[key]
+	if !ok || time.Now().After(e.expiresAt) {
+		return Attempts{}, nil
+	}
+	return e.Attempts, nil
+}
+
+// RecordFailure counts a failed attempt made under key.
+func (s *MemoryAttemptStore) RecordFailure(_ context.Context, key string, at time.Time, window time.Duration) (Attempts, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweep(at)
+
+	e, ok := s.attempts[key]
+	if !ok || at.After(e.expiresAt) {
+		e = attemptsEntry{}
+	}
+
+	e.Failures++
+	e.LastFailure = at
+	e.expiresAt = at.Add(window)
+
+	s.attempts[key] = e
+	return e.Attempts, nil
+}
+
+// Reset forgets the failed attempts recorded under key.
+func (s *MemoryAttemptStore) Reset(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.attempts, key)
+	return nil
+}
+
+// sweep drops the attempts whose window is over at most once per memorySweepInterval.
+func (s *MemoryAttemptStore) sweep(now time.Time) {
+	if now.Before(s.nextSweep) {
+		return
+	}
+	s.nextSweep = now.Add(memorySweepInterval)
+
+	for key, e := range s.attempts {
+		if now.After(e.expiresAt) {
+			delete(s.attempts, key)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryAttemptStore(t *testing.T) {
+	t.Parallel()
+
+	store := NewMemoryAttemptStore()
+	now := time.Now()
+
+	observed, err := store.Attempts(context.TODO(), "foo")
+	require.NoError(t, err)
+	assert.Equal(t, Attempts{}, observed)
+
+	_, err = store.RecordFailure(context.TODO(), "foo", now.Add(-time.Minute), time.Hour)
+	require.NoError(t, err)
+
+	observed, err = store.RecordFailure(context.TODO(), "foo", now, time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, Attempts{Failures: 2, LastFailure: now}, observed)
+
+	observed, err = store.Attempts(context.TODO(), "foo")
+	require.NoError(t, err)
+	assert.Equal(t, Attempts{Failures: 2, LastFailure: now}, observed)
+
+	t.Run("the count restarts after the window", func(t *testing.T) {
+		_, err := store.RecordFailure(context.TODO(), "bar", now.Add(-2*time.Hour), time.Hour)
+		require.NoError(t, err)
+
+		observed, err := store.Attempts(context.TODO(), "bar")
+		require.NoError(t, err)
+		assert.Equal(t, Attempts{}, observed)
+
+		observed, err = store.RecordFailure(context.TODO(), "bar", now, time.Hour)
+		require.NoError(t, err)
+		assert.Equal(t, 1, observed.Failures)
+	})
+
+	t.Run("reset", func(t *testing.T) {
+		_, err := store.RecordFailure(context.TODO(), "baz", now, time.Hour)
+		require.NoError(t, err)
+
+		require.NoError(t, store.Reset(context.TODO(), "baz"))
+
+		observed, err := store.Attempts(context.TODO(), "baz")
+		require.NoError(t, err)
+		assert.Equal(t, Attempts{}, observed)
+	})
+}
+
+func TestMemoryAttemptStore_sweep(t *testing.T) {
+	t.Parallel()
+
+	store := NewMemoryAttemptStore()
+	now := time.Now()
+
+	_, err := store.RecordFailure(context.TODO(), "foo", now, time.Hour)
+	require.NoError(t, err)
+
+	_, err = store.RecordFailure(context.TODO(), "bar", now, time.Second)
+	require.NoError(t, err)
+
+	store.sweep(now.Add(memorySweepInterval))
+	assert.Len(t, store.attempts, 1)
+}
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// Claims are the claims of the access tokens issued by the service.
+type Claims struct {
+	jwt.StandardClaims
+
+	// ClientID is the OAuth client the token was issued to (RFC 9068), empty for tokens issued by /auth.
+	ClientID string `json:"client_id,omitempty"`
+
+	// Scope is the space-delimited list of scopes granted to the token.
+	Scope string `json:"scope,omitempty"`
+
+	// Custom are the claims of the user the token was issued for, e.g. tenant, roles or email.
+	// They are encoded next to the registered claims, which they can't override.
+	Custom map[string]any `json:"-"`
+}
+
+// reservedClaims are the claims set by the service, which custom claims can't use.
+var reservedClaims = map[string]bool{
+	"iss":       true,
+	"sub":       true,
+	"aud":       true,
+	"exp":       true,
+	"nbf":       true,
+	"iat":       true,
+	"jti":       true,
+	"client_id": true,
+	"scope":     true,
+}
+
+var errReservedClaim = errors.New("reserved claim")
+
+// checkCustomClaims reports whether claims can be attached to tokens.
+func checkCustomClaims(claims map[string]any) error {
+	for name := range claims {
+		if reservedClaims[name] {
+			return fmt.Errorf("could not use claim %q: %w", name, errReservedClaim)
+		}
+	}
+	return nil
+}
+
+// parseCustomClaims decodes custom claims stored as a JSON object. An empty string holds no claims.
+func parseCustomClaims(data string) (map[string]any, error) {
+	if data == "" {
+		return nil, nil
+	}
+
+	var claims map[string]any
+	if err := json.Unmarshal([]byte(data), &claims); err != nil {
+		return nil, fmt.Errorf("could not decode claims: %w", err)
+	}
+
+	if err := checkCustomClaims(claims); err != nil {
+		return nil, err
+	}
+
+	if len(claims) == 0 {
+		return nil, nil
+	}
+	return claims, nil
+}
+
+// formatCustomClaims encodes custom claims as a JSON object, or an empty string when there are none.
+func formatCustomClaims(claims map[string]any) (string, error) {
+	if len(claims) == 0 {
+		return "", nil
+	}
+
+	data, err := json.Marshal(claims)
+	if err != nil {
+		return "", fmt.Errorf("could not encode claims: %w", err)
+	}
+	return string(data), nil
+}
+
+// registeredClaims is Claims without its JSON methods.
+type registeredClaims Claims
+
+// MarshalJSON encodes the registered claims and the custom claims in a single object.
+func (c Claims) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(registeredClaims(c))
+	if err != nil || len(c.Custom) == 0 {
+		return data, err
+	}
+
+	var merged map[string]json.RawMessage
+	if err := json.Unmarshal(data, &merged); err != nil {
+		return nil, err
+	}
+
+	for name, value := range c.Custom {
+		if reservedClaims[name] {
+			continue
+		}
+
+		if merged[name], err = json.Marshal(value); err != nil {
+			return nil, fmt.Errorf("could not encode claim %q: %w", name, err)
+		}
+	}
+	return json.Marshal(merged)
+}
+
+// UnmarshalJSON decodes the registered claims and collects the others in Custom.
+func (c *Claims) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, (*registeredClaims)(c)); err != nil {
+		return err
+	}
+
+	var all map[string]any
+	if err := json.Unmarshal(data, &all); err != nil {
+		return err
+	}
+
+	for name := range all {
+		if reservedClaims[name] {
+			delete(all, name)
+		}
+	}
+
+	c.Custom = nil
+	if len(all) > 0 {
+		c.Custom = all
+	}
+	return nil
+}
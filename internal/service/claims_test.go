@@ -0,0 +1,71 @@
+package service
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClaims_JSON(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name           string
+		givenClaims    Claims
+		expectedJSON   string
+		expectedClaims Claims
+	}{
+		{
+			name: "registered claims only",
+			givenClaims: Claims{
+				StandardClaims: jwt.StandardClaims{Subject: "foo", ExpiresAt: 1700000000},
+				Scope:          "sum",
+			},
+			expectedJSON: `{"exp":1700000000,"sub":"foo","scope":"sum"}`,
+			expectedClaims: Claims{
+				StandardClaims: jwt.StandardClaims{Subject: "foo", ExpiresAt: 1700000000},
+				Scope:          "sum",
+			},
+		},
+		{
+			name: "custom claims",
+			givenClaims: Claims{
+				StandardClaims: jwt.StandardClaims{Subject: "foo"},
+				Custom:         map[string]any{"tenant": "acme", "roles": []any{"admin"}},
+			},
+			expectedJSON: `{"roles":["admin"],"sub":"foo","tenant":"acme"}`,
+			expectedClaims: Claims{
+				StandardClaims: jwt.StandardClaims{Subject: "foo"},
+				Custom:         map[string]any{"tenant": "acme", "roles": []any{"admin"}},
+			},
+		},
+		{
+			name: "custom claims can't override registered claims",
+			givenClaims: Claims{
+				StandardClaims: jwt.StandardClaims{Subject: "foo"},
+				Custom:         map[string]any{"sub": "root", "scope": "admin"},
+			},
+			expectedJSON: `{"sub":"foo"}`,
+			expectedClaims: Claims{
+				StandardClaims: jwt.StandardClaims{Subject: "foo"},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			data, err := json.Marshal(tc.givenClaims)
+			require.NoError(t, err)
+
+			assert.JSONEq(t, tc.expectedJSON, string(data))
+
+			var observed Claims
+			require.NoError(t, json.Unmarshal(data, &observed))
+
+			assert.Equal(t, tc.expectedClaims, observed)
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryClientStore(t *testing.T) {
+	t.Parallel()
+
+	hash := testArgon2idHash("bar-secret")
+
+	store, err := NewMemoryClientStore(Client{ID: "foo-client", SecretHash: hash})
+	require.NoError(t, err)
+
+	t.Run("existing client", func(t *testing.T) {
+		observed, err := store.Client(context.TODO(), "foo-client")
+		require.NoError(t, err)
+
+		assert.Equal(t, &Client{ID: "foo-client", SecretHash: hash}, observed)
+	})
+
+	t.Run("unknown client", func(t *testing.T) {
+		_, err := store.Client(context.TODO(), "baz-client")
+		assert.True(t, errors.Is(err, ErrClientNotFound))
+	})
+
+	t.Run("invalid hash", func(t *testing.T) {
+		err := store.PutClient(Client{ID: "baz-client", SecretHash: "plain-text"})
+		assert.True(t, errors.Is(err, errUnsupportedPasswordHash))
+	})
+}
+
+func TestFileClientStore(t *testing.T) {
+	t.Parallel()
+
+	hash := testArgon2idHash("bar-secret")
+
+	path := filepath.Join(t.TempDir(), "clients")
+	content := "# clients allowed to request tokens\n" +
+		"foo-client:" + hash + "\n" +
+		"bar-client:" + hash + ":sum\n"
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+	store, err := NewFileClientStore(path)
+	require.NoError(t, err)
+
+	observed, err := store.Client(context.TODO(), "foo-client")
+	require.NoError(t, err)
+	assert.Equal(t, &Client{ID: "foo-client", SecretHash: hash}, observed)
+
+	observed, err = store.Client(context.TODO(), "bar-client")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"sum"}, observed.Scopes)
+
+	_, err = store.Client(context.TODO(), "baz-client")
+	assert.True(t, errors.Is(err, ErrClientNotFound))
+
+	t.Run("invalid file", func(t *testing.T) {
+		invalidPath := filepath.Join(t.TempDir(), "clients")
+		require.NoError(t, os.WriteFile(invalidPath, []byte("foo-client:plain-text\n"), 0o600))
+
+		_, err := NewFileClientStore(invalidPath)
+		assert.True(t, errors.Is(err, errUnsupportedPasswordHash))
+	})
+}
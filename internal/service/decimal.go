@@ -0,0 +1,218 @@
+package service
+
+import (
+	"errors"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// maxDecimalExponent bounds the magnitude of the base-10 exponent of any number we accept.
+// It comfortably covers the float64 range while keeping exact arithmetic cheap
+// (a literal like 1e1000000000 would otherwise expand into a billion digits).
+const maxDecimalExponent = 1000
+
+var errDecimalSyntax = errors.New("invalid decimal syntax")
+
+// decimal is an exact base-10 number whose value is unscaled * 10^-scale.
+type decimal struct {
+	unscaled *big.Int
+	scale    int
+}
+
+func newDecimal() decimal {
+	return decimal{unscaled: new(big.Int)}
+}
+
+// parseDecimal parses a number in plain or exponent notation,
+// e.g. "42", "-0.1", "+1.5e-3" or "9007199254740993", without any loss of precision.
+func parseDecimal(s string) (decimal, error) {
+	mantissa, exponent := s, ""
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		mantissa, exponent = s[:i], s[i+1:]
+		if exponent == "" {
+			return decimal{}, errDecimalSyntax
+		}
+	}
+
+	var neg bool
+	switch {
+	case strings.HasPrefix(mantissa, "-"):
+		neg, mantissa = true, mantissa[1:]
+	case strings.HasPrefix(mantissa, "+"):
+		mantissa = mantissa[1:]
+	}
+
+	intPart, fracPart, _ := strings.Cut(mantissa, ".")
+	if intPart == "" && fracPart == "" || !isDigits(intPart) || !isDigits(fracPart) {
+		return decimal{}, errDecimalSyntax
+	}
+
+	scale := len(fracPart)
+	if exponent != "" {
+		exp, err := strconv.Atoi(exponent)
+		if err != nil {
+			if errors.Is(err, strconv.ErrRange) {
+				return decimal{}, ErrNumberOutOfRange
+			}
+			return decimal{}, errDecimalSyntax
+		}
+		if exp > maxDecimalExponent || exp < -maxDecimalExponent {
+			return decimal{}, ErrNumberOutOfRange
+		}
+		scale -= exp
+	}
+
+	digits := strings.TrimLeft(intPart+fracPart, "0")
+	if digits == "" {
+		return newDecimal(), nil
+	}
+
+	if scale > maxDecimalExponent || scale-len(digits) < -maxDecimalExponent {
+		return decimal{}, ErrNumberOutOfRange
+	}
+
+	unscaled, ok := new(big.Int).SetString(digits, 10)
+	if !ok {
+		return decimal{}, errDecimalSyntax
+	}
+	if neg {
+		unscaled.Neg(unscaled)
+	}
+	return decimal{unscaled: unscaled, scale: scale}, nil
+}
+
+// decimalFromFloat converts f using its shortest round-tripping representation,
+// so that 0.1 becomes exactly 0.1 rather than the nearest binary fraction.
+func decimalFromFloat(f float64) (decimal, error) {
+	return parseDecimal(strconv.FormatFloat(f, 'g', -1, 64))
+}
+
+// add returns d + other.
+func (d decimal) add(other decimal) decimal {
+	a, b := d, other
+	if a.scale < b.scale {
+		a, b = b, a
+	}
+
+	aligned := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(a.scale-b.scale)), nil)
+	aligned.Mul(aligned, b.unscaled)
+
+	return decimal{
+		unscaled: aligned.Add(aligned, a.unscaled),
+		scale:    a.scale,
+	}
+}
+
+// mul returns d * other.
+func (d decimal) mul(other decimal) decimal {
+	return decimal{
+		unscaled: new(big.Int).Mul(d.unscaled, other.unscaled),
+		scale:    d.scale + other.scale,
+	}
+}
+
+// cmp compares d and other, and returns -1, 0 or +1 as d is less than, equal to or greater than other.
+func (d decimal) cmp(other decimal) int {
+	a, b := d.unscaled, other.unscaled
+	switch {
+	case d.scale < other.scale:
+		a = new(big.Int).Mul(a, pow10(other.scale-d.scale))
+	case d.scale > other.scale:
+		b = new(big.Int).Mul(b, pow10(d.scale-other.scale))
+	}
+	return a.Cmp(b)
+}
+
+// quo returns d / n rounded half to even to scale fractional digits, which must be at least those of d.
+func (d decimal) quo(n int64, scale int) decimal {
+	num := new(big.Int).Mul(d.unscaled, pow10(scale-d.scale))
+	den := big.NewInt(n)
+
+	q, r := new(big.Int).QuoRem(num, den, new(big.Int))
+
+	// Round away from zero past the half, and at the half when that makes q even.
+	twice := new(big.Int).Abs(r)
+	twice.Lsh(twice, 1)
+	if c := twice.Cmp(new(big.Int).Abs(den)); c > 0 || c == 0 && q.Bit(0) == 1 {
+		if num.Sign()*den.Sign() < 0 {
+			q.Sub(q, big.NewInt(1))
+		} else {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+	return decimal{unscaled: q, scale: scale}
+}
+
+// trim drops the trailing fractional zeros of d, which keeps repeated products from growing their scale.
+func (d decimal) trim() decimal {
+	if d.unscaled.Sign() == 0 {
+		return newDecimal()
+	}
+
+	ten := big.NewInt(10)
+	unscaled, scale := new(big.Int).Set(d.unscaled), d.scale
+
+	q, r := new(big.Int), new(big.Int)
+	for scale > 0 {
+		if q.QuoRem(unscaled, ten, r); r.Sign() != 0 {
+			break
+		}
+		unscaled, q = q, unscaled
+		scale--
+	}
+	return decimal{unscaled: unscaled, scale: scale}
+}
+
+// exponent returns the base-10 exponent of d in scientific notation, such as 2 for 123 and -3 for 0.00123.
+func (d decimal) exponent() int {
+	if d.unscaled.Sign() == 0 {
+		return 0
+	}
+	return len(new(big.Int).Abs(d.unscaled).String()) - 1 - d.scale
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+// String returns the canonical representation of d: plain notation,
+// no exponent, no trailing fractional zeros and no negative zero.
+func (d decimal) String() string {
+	if d.unscaled == nil || d.unscaled.Sign() == 0 {
+		return "0"
+	}
+
+	if d.scale <= 0 {
+		shift := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(-d.scale)), nil)
+		return shift.Mul(shift, d.unscaled).String()
+	}
+
+	digits := new(big.Int).Abs(d.unscaled).String()
+	if len(digits) <= d.scale {
+		digits = strings.Repeat("0", d.scale-len(digits)+1) + digits
+	}
+
+	intPart := digits[:len(digits)-d.scale]
+	fracPart := strings.TrimRight(digits[len(digits)-d.scale:], "0")
+
+	var sb strings.Builder
+	if d.unscaled.Sign() < 0 {
+		sb.WriteByte('-')
+	}
+	sb.WriteString(intPart)
+	if fracPart != "" {
+		sb.WriteByte('.')
+		sb.WriteString(fracPart)
+	}
+	return sb.String()
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
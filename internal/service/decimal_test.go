@@ -0,0 +1,177 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDecimal(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name          string
+		given         string
+		expected      string
+		expectedError error
+	}{
+		{name: "integer", given: "42", expected: "42"},
+		{name: "negative fraction", given: "-0.5", expected: "-0.5"},
+		{name: "explicit plus sign", given: "+1.25", expected: "1.25"},
+		{name: "trailing zeros", given: "1.500", expected: "1.5"},
+		{name: "leading dot", given: ".5", expected: "0.5"},
+		{name: "trailing dot", given: "5.", expected: "5"},
+		{name: "negative zero", given: "-0.0", expected: "0"},
+		{name: "positive exponent", given: "1.2e3", expected: "1200"},
+		{name: "negative exponent", given: "12E-4", expected: "0.0012"},
+		{name: "large integer", given: "123456789012345678901234567890", expected: "123456789012345678901234567890"},
+		{name: "empty", given: "", expectedError: errDecimalSyntax},
+		{name: "only sign", given: "-", expectedError: errDecimalSyntax},
+		{name: "missing exponent", given: "1e", expectedError: errDecimalSyntax},
+		{name: "not a number", given: "dark", expectedError: errDecimalSyntax},
+		{name: "hexadecimal", given: "0x10", expectedError: errDecimalSyntax},
+		{name: "exponent too large", given: "1e1001", expectedError: ErrNumberOutOfRange},
+		{name: "exponent too small", given: "1e-1001", expectedError: ErrNumberOutOfRange},
+		{name: "exponent overflows int", given: "1e99999999999999999999", expectedError: ErrNumberOutOfRange},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			observed, err := parseDecimal(tc.given)
+			if tc.expectedError != nil {
+				assert.True(t, errors.Is(err, tc.expectedError))
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, observed.String())
+		})
+	}
+}
+
+func TestDecimal_add(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		a, b     string
+		expected string
+	}{
+		{name: "same scale", a: "0.1", b: "0.2", expected: "0.3"},
+		{name: "different scales", a: "1e3", b: "0.001", expected: "1000.001"},
+		{name: "cancel out", a: "-1.5", b: "1.5", expected: "0"},
+		{name: "negative result", a: "1", b: "-2.25", expected: "-1.25"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			a, err := parseDecimal(tc.a)
+			assert.NoError(t, err)
+
+			b, err := parseDecimal(tc.b)
+			assert.NoError(t, err)
+
+			assert.Equal(t, tc.expected, a.add(b).String())
+		})
+	}
+}
+
+func TestDecimal_mul(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		a, b     string
+		expected string
+	}{
+		{name: "integers", a: "6", b: "7", expected: "42"},
+		{name: "fractions", a: "0.1", b: "0.2", expected: "0.02"},
+		{name: "exponents", a: "1e3", b: "2.5e-2", expected: "25"},
+		{name: "negative", a: "-1.5", b: "4", expected: "-6"},
+		{name: "zero", a: "0", b: "-3.7", expected: "0"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			a, err := parseDecimal(tc.a)
+			assert.NoError(t, err)
+
+			b, err := parseDecimal(tc.b)
+			assert.NoError(t, err)
+
+			product := a.mul(b)
+			assert.Equal(t, tc.expected, product.String())
+			assert.Equal(t, tc.expected, product.trim().String())
+		})
+	}
+}
+
+func TestDecimal_cmp(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		a, b     string
+		expected int
+	}{
+		{name: "less", a: "0.1", b: "0.2", expected: -1},
+		{name: "greater", a: "1e3", b: "999.999", expected: 1},
+		{name: "equal with different scales", a: "1.50", b: "1.5", expected: 0},
+		{name: "negative", a: "-2", b: "-1.5", expected: -1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			a, err := parseDecimal(tc.a)
+			assert.NoError(t, err)
+
+			b, err := parseDecimal(tc.b)
+			assert.NoError(t, err)
+
+			assert.Equal(t, tc.expected, a.cmp(b))
+			assert.Equal(t, -tc.expected, b.cmp(a))
+		})
+	}
+}
+
+func TestDecimal_quo(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		given    string
+		n        int64
+		scale    int
+		expected string
+	}{
+		{name: "exact", given: "3", n: 2, scale: 1, expected: "1.5"},
+		{name: "rounded down", given: "4", n: 3, scale: 3, expected: "1.333"},
+		{name: "rounded up", given: "5", n: 3, scale: 3, expected: "1.667"},
+		{name: "half to even down", given: "0.5", n: 2, scale: 1, expected: "0.2"},
+		{name: "half to even up", given: "0.7", n: 2, scale: 1, expected: "0.4"},
+		{name: "negative half to even", given: "-0.5", n: 2, scale: 1, expected: "-0.2"},
+		{name: "negative rounded away", given: "-5", n: 3, scale: 2, expected: "-1.67"},
+		{name: "negative exponent", given: "1e3", n: 8, scale: 0, expected: "125"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			d, err := parseDecimal(tc.given)
+			assert.NoError(t, err)
+
+			assert.Equal(t, tc.expected, d.quo(tc.n, tc.scale).String())
+		})
+	}
+}
+
+func TestDecimal_exponent(t *testing.T) {
+	t.Parallel()
+
+	for given, expected := range map[string]int{"123": 2, "0.00123": -3, "1e3": 3, "-4.5": 0, "0": 0} {
+		d, err := parseDecimal(given)
+		assert.NoError(t, err)
+
+		assert.Equal(t, expected, d.exponent(), given)
+	}
+}
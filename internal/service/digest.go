@@ -0,0 +1,144 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"sync"
+
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/sha3"
+)
+
+// Supported digest algorithms and encodings.
+const (
+	DigestSHA256     string = "sha256"
+	DigestSHA512     string = "sha512"
+	DigestSHA3_256   string = "sha3-256"
+	DigestBLAKE2b512 string = "blake2b-512"
+	DigestHMACSHA256 string = "hmac-sha256"
+
+	EncodingHex       string = "hex"
+	EncodingBase64URL string = "base64url"
+	EncodingMultihash string = "multihash"
+)
+
+// DigestAlgorithm is a hash function that can digest sums.
+type DigestAlgorithm struct {
+	Name string
+	New  func() hash.Hash
+
+	// MultihashCode is the multicodec code of the algorithm, zero when it has none.
+	MultihashCode uint64
+}
+
+// EncodeFunc turns a digest computed with alg into text.
+type EncodeFunc func(alg DigestAlgorithm, sum []byte) (string, error)
+
+// DigestRegistry holds the digest algorithms and encodings Sum can pick from.
+type DigestRegistry struct {
+	mu         sync.RWMutex
+	algorithms map[string]DigestAlgorithm
+	encodings  map[string]EncodeFunc
+}
+
+// NewDigestRegistry creates a registry with the keyless algorithms and all encodings registered.
+// Keyed algorithms such as HMACSHA256 must be registered explicitly.
+func NewDigestRegistry() *DigestRegistry {
+	r := DigestRegistry{
+		algorithms: make(map[string]DigestAlgorithm),
+		encodings:  make(map[string]EncodeFunc),
+	}
+
+	r.RegisterAlgorithm(DigestAlgorithm{Name: DigestSHA256, New: sha256.New, MultihashCode: 0x12})
+	r.RegisterAlgorithm(DigestAlgorithm{Name: DigestSHA512, New: sha512.New, MultihashCode: 0x13})
+	r.RegisterAlgorithm(DigestAlgorithm{Name: DigestSHA3_256, New: sha3.New256, MultihashCode: 0x16})
+	r.RegisterAlgorithm(DigestAlgorithm{Name: DigestBLAKE2b512, New: newBLAKE2b512, MultihashCode: 0xb240})
+
+	r.RegisterEncoding(EncodingHex, encodeHex)
+	r.RegisterEncoding(EncodingBase64URL, encodeBase64URL)
+	r.RegisterEncoding(EncodingMultihash, encodeMultihash)
+	return &r
+}
+
+// HMACSHA256 returns the HMAC-SHA256 algorithm keyed with the given server-side secret.
+func HMACSHA256(key []byte) DigestAlgorithm {
+	return DigestAlgorithm{
+		Name: DigestHMACSHA256,
+		New: func() hash.Hash {
+			return hmac.New(sha256.New, key)
+		},
+	}
+}
+
+// RegisterAlgorithm adds alg to the registry, replacing any algorithm with the same name.
+func (r *DigestRegistry) RegisterAlgorithm(alg DigestAlgorithm) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.algorithms[alg.Name] = alg
+}
+
+// RegisterEncoding adds an encoding to the registry, replacing any encoding with the same name.
+func (r *DigestRegistry) RegisterEncoding(name string, fn EncodeFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.encodings[name] = fn
+}
+
+// Check reports whether the algorithm and encoding are registered.
+func (r *DigestRegistry) Check(algorithm, encoding string) error {
+	_, err := r.Digest(algorithm, encoding, nil)
+	return err
+}
+
+// Digest hashes data with the named algorithm and encodes the result with the named encoding.
+func (r *DigestRegistry) Digest(algorithm, encoding string, data []byte) (string, error) {
+	r.mu.RLock()
+	alg, algOK := r.algorithms[algorithm]
+	encode, encOK := r.encodings[encoding]
+	r.mu.RUnlock()
+
+	if !algOK {
+		return "", fmt.Errorf("could not find digest algorithm %q: %w", algorithm, ErrUnsupportedDigest)
+	}
+
+	if !encOK {
+		return "", fmt.Errorf("could not find digest encoding %q: %w", encoding, ErrUnsupportedEncoding)
+	}
+
+	h := alg.New()
+	h.Write(data)
+	return encode(alg, h.Sum(nil))
+}
+
+func newBLAKE2b512() hash.Hash {
+	// New512 only fails for keys longer than 64 bytes.
+	h, _ := blake2b.New512(nil)
+	return h
+}
+
+func encodeHex(_ DigestAlgorithm, sum []byte) (string, error) {
+	return hex.EncodeToString(sum), nil
+}
+
+func encodeBase64URL(_ DigestAlgorithm, sum []byte) (string, error) {
+	return base64.RawURLEncoding.EncodeToString(sum), nil
+}
+
+// encodeMultihash returns the hex form of <varint code><varint length><digest>.
+func encodeMultihash(alg DigestAlgorithm, sum []byte) (string, error) {
+	if alg.MultihashCode == 0 {
+		return "", fmt.Errorf("could not encode %s as multihash: %w", alg.Name, ErrUnsupportedEncoding)
+	}
+
+	buf := binary.AppendUvarint(nil, alg.MultihashCode)
+	buf = binary.AppendUvarint(buf, uint64(len(sum)))
+	return hex.EncodeToString(append(buf, sum...)), nil
+}
@@ -0,0 +1,104 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDigestRegistry_Digest(t *testing.T) {
+	t.Parallel()
+
+	registry := NewDigestRegistry()
+	registry.RegisterAlgorithm(HMACSHA256([]byte("foo-key")))
+
+	testCases := []struct {
+		name          string
+		algorithm     string
+		encoding      string
+		expected      string
+		expectedError error
+	}{
+		{
+			name:      "sha256 hex",
+			algorithm: DigestSHA256,
+			encoding:  EncodingHex,
+			expected:  "e7f6c011776e8db7cd330b54174fd76f7d0216b612387a5ffcfb81e6f0919683",
+		},
+		{
+			name:      "sha512 base64url",
+			algorithm: DigestSHA512,
+			encoding:  EncodingBase64URL,
+			expected:  "PJrVUUenFE9gZzJ8O4LqcOfFQmrdnO6k0H3CkCI5v54Em4hiXrZdAUp3GPeTVGCMqwkheCxkPwIImD__o1guQA",
+		},
+		{
+			name:      "sha3-256 hex",
+			algorithm: DigestSHA3_256,
+			encoding:  EncodingHex,
+			expected:  "0c67354981e9068905680b57898ad4f04b993c63eb66aa3f19cdfdc71d88077e",
+		},
+		{
+			name:      "blake2b-512 hex",
+			algorithm: DigestBLAKE2b512,
+			encoding:  EncodingHex,
+			expected:  "8d322d4b02d9fcfb05bc70e486406e53c3cf9b97a252bf64752cafc5c2aaf95baef7f6e30d0a64826921ad01ec9d8c010805367078e5b5963ab4be3efd8f4a78",
+		},
+		{
+			name:      "hmac-sha256 hex",
+			algorithm: DigestHMACSHA256,
+			encoding:  EncodingHex,
+			expected:  "4844672265635ce86cc52b1dfd4ebbae3d3bb94322965fad1f00db0f329e8129",
+		},
+		{
+			name:      "sha256 multihash",
+			algorithm: DigestSHA256,
+			encoding:  EncodingMultihash,
+			expected:  "1220e7f6c011776e8db7cd330b54174fd76f7d0216b612387a5ffcfb81e6f0919683",
+		},
+		{
+			name:      "blake2b-512 multihash has a two byte code",
+			algorithm: DigestBLAKE2b512,
+			encoding:  EncodingMultihash,
+			expected:  "c0e402408d322d4b02d9fcfb05bc70e486406e53c3cf9b97a252bf64752cafc5c2aaf95baef7f6e30d0a64826921ad01ec9d8c010805367078e5b5963ab4be3efd8f4a78",
+		},
+		{
+			name:          "hmac has no multihash code",
+			algorithm:     DigestHMACSHA256,
+			encoding:      EncodingMultihash,
+			expectedError: ErrUnsupportedEncoding,
+		},
+		{
+			name:          "unknown algorithm",
+			algorithm:     "md5",
+			encoding:      EncodingHex,
+			expectedError: ErrUnsupportedDigest,
+		},
+		{
+			name:          "unknown encoding",
+			algorithm:     DigestSHA256,
+			encoding:      "base32",
+			expectedError: ErrUnsupportedEncoding,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			observed, err := registry.Digest(tc.algorithm, tc.encoding, []byte("6"))
+
+			assert.Equal(t, tc.expected, observed)
+			assert.True(t, errors.Is(err, tc.expectedError))
+		})
+	}
+}
+
+func TestDigestRegistry_Check(t *testing.T) {
+	t.Parallel()
+
+	registry := NewDigestRegistry()
+
+	assert.NoError(t, registry.Check(DigestSHA256, EncodingHex))
+
+	// HMAC is only available once a key is registered.
+	assert.True(t, errors.Is(registry.Check(DigestHMACSHA256, EncodingHex), ErrUnsupportedDigest))
+}
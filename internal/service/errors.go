@@ -5,11 +5,39 @@ import "errors"
 var (
 	// Enumerate possible service errors
 
-	ErrPasswordInvalid        error = errors.New("the password is invalid")
-	ErrTokenInvalidExpiration error = errors.New("the token is expired")
-	ErrTokenInvalid           error = errors.New("the token is invalid")
-	ErrTokenInvalidAudience   error = errors.New("the token audience is invalid")
-	ErrTokenInvalidIssuer     error = errors.New("the token issuer is invalid")
-	ErrUnsupportedValueType   error = errors.New("the value type is unsupported")
-	ErrUsernameInvalid        error = errors.New("the username is invalid")
+	ErrClientCredentialsMismatch error = errors.New("the client credentials do not match")
+	ErrClientNotFound            error = errors.New("the client was not found")
+	ErrCredentialsMismatch       error = errors.New("the credentials do not match")
+	ErrDocumentInvalid           error = errors.New("the document is not valid JSON")
+	ErrDocumentTooDeep           error = errors.New("the document is nested too deeply")
+	ErrDocumentTooLarge          error = errors.New("the document has too many values")
+	ErrNoValues                  error = errors.New("there are no numbers to aggregate")
+	ErrNonFiniteResult           error = errors.New("the result is not a finite number")
+	ErrNumberOutOfRange          error = errors.New("the number is out of range")
+	ErrPasswordInvalid           error = errors.New("the password is invalid")
+	ErrRefreshTokenExpired       error = errors.New("the refresh token is expired")
+	ErrRefreshTokenInvalid       error = errors.New("the refresh token is invalid")
+	ErrRefreshTokenReused        error = errors.New("the refresh token was already used")
+	ErrScopeInvalid              error = errors.New("the scope is invalid")
+	ErrSelectorInvalid           error = errors.New("the selector is invalid")
+	ErrTokenInvalidExpiration    error = errors.New("the token is expired")
+	ErrTokenInvalid              error = errors.New("the token is invalid")
+	ErrTokenInvalidAlgorithm     error = errors.New("the token algorithm doesn't match its key")
+	ErrTokenInvalidAudience      error = errors.New("the token audience is invalid")
+	ErrTokenInvalidIssuedAt      error = errors.New("the token was issued in the future")
+	ErrTokenInvalidIssuer        error = errors.New("the token issuer is invalid")
+	ErrTokenNotYetValid          error = errors.New("the token is not valid yet")
+	ErrTokenRevoked              error = errors.New("the token is revoked")
+	ErrTokenUnknownKey           error = errors.New("the token key is unknown")
+	ErrTooManyAttempts           error = errors.New("too many failed attempts")
+	ErrUnsupportedDigest         error = errors.New("the digest algorithm is unsupported")
+	ErrUnsupportedKey            error = errors.New("the key is unsupported")
+	ErrUnsupportedEncoding       error = errors.New("the digest encoding is unsupported")
+	ErrUnsupportedGrantType      error = errors.New("the grant type is unsupported")
+	ErrUnsupportedLiteralPolicy  error = errors.New("the literal policy is unsupported")
+	ErrUnsupportedOperation      error = errors.New("the operation is unsupported")
+	ErrUnsupportedStringMode     error = errors.New("the string mode is unsupported")
+	ErrUnsupportedValueType      error = errors.New("the value type is unsupported")
+	ErrUserNotFound              error = errors.New("the user was not found")
+	ErrUsernameInvalid           error = errors.New("the username is invalid")
 )
@@ -0,0 +1,155 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// SumValueStatus tells what became of a value of a summed document.
+type SumValueStatus string
+
+const (
+	// SumValueContributed values were added to the total.
+	SumValueContributed SumValueStatus = "contributed"
+
+	// SumValueSkipped values were left out of the total, such as strings in StringsIgnore mode.
+	SumValueSkipped SumValueStatus = "skipped"
+
+	// SumValueRejected values failed the sum, such as booleans under LiteralsReject.
+	SumValueRejected SumValueStatus = "rejected"
+)
+
+// SumExplanation tells what a sum made of the values of a document.
+type SumExplanation struct {
+	// Sum is the canonical representation of the total, which is what gets digested.
+	Sum string
+
+	// Count is how many values contributed to the total.
+	Count int
+
+	// Values are the values of the document, arrays and objects aside, in the order they were summed.
+	Values []SumValue
+}
+
+// SumValue is a value of a summed document and what became of it.
+type SumValue struct {
+	// Pointer is the JSON Pointer (RFC 6901) of the value, such as /a/2.
+	Pointer string
+
+	// Value is the value encoded as JSON.
+	Value string
+
+	Status SumValueStatus
+
+	// Reason tells why a value was rejected, or left out by the selectors of the sum.
+	Reason string
+}
+
+// ExplainedError is returned by a sum asked to explain itself when the document has values it rejects.
+// Explanation tells what became of every value, and Err is the PathError of the first value rejected.
+type ExplainedError struct {
+	Explanation *SumExplanation
+	Err         error
+}
+
+func (e *ExplainedError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap lets errors.Is and errors.As match the error of the first value rejected.
+func (e *ExplainedError) Unwrap() error {
+	return e.Err
+}
+
+// explainNumbers sums data as sumNumbers does, and explains what became of each value.
+// Rather than stopping at the first value it rejects, it explains every value of the document,
+// and then fails with an ExplainedError.
+func explainNumbers(data any, rules sumRules) (*SumExplanation, error) {
+	sum := newReducer(OperationSum, rules.arithmetic)
+
+	w := numberWalker{
+		reducers:     []reducer{sum},
+		rules:        rules,
+		valueCounter: valueCounter{limits: rules.limits},
+		trace:        &sumTrace{},
+	}
+
+	if err := w.walk(data, 0); err != nil {
+		return nil, err
+	}
+
+	total, err := sum.result()
+	if err != nil {
+		return nil, err
+	}
+
+	explanation := SumExplanation{
+		Sum:    total,
+		Count:  w.trace.count,
+		Values: w.trace.values,
+	}
+
+	if w.trace.err != nil {
+		return nil, &ExplainedError{Explanation: &explanation, Err: w.trace.err}
+	}
+	return &explanation, nil
+}
+
+// sumTrace records what becomes of the values of a document.
+type sumTrace struct {
+	values []SumValue
+	count  int
+
+	// err is the error of the first value rejected.
+	err error
+}
+
+// record records the value v at path, which was added to the total or not, or failed with err.
+func (t *sumTrace) record(path []pathStep, v any, added bool, err error) {
+	value := SumValue{
+		Pointer: jsonPointer(path),
+		Value:   jsonText(v),
+		Status:  SumValueSkipped,
+	}
+
+	switch {
+	case err != nil:
+		value.Status = SumValueRejected
+		value.Reason = err.Error()
+
+		if t.err == nil {
+			t.err = &PathError{Path: jsonPath(path), Err: err}
+		}
+
+	case added:
+		value.Status = SumValueContributed
+		t.count++
+	}
+
+	t.values = append(t.values, value)
+}
+
+// recordUnselected records the value v at path, which the selectors of the sum left out.
+func (t *sumTrace) recordUnselected(path []pathStep, v any) {
+	t.values = append(t.values, SumValue{
+		Pointer: jsonPointer(path),
+		Value:   jsonText(v),
+		Status:  SumValueSkipped,
+		Reason:  "not selected",
+	})
+}
+
+// jsonText returns v encoded as JSON, keeping the literal of numbers decoded with json.Decoder.UseNumber.
+func jsonText(v any) string {
+	if n, ok := v.(json.Number); ok {
+		return n.String()
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		// Values beyond JSON, which the sum rejects anyway.
+		return strconv.Quote(fmt.Sprint(v))
+	}
+	return string(b)
+}
@@ -0,0 +1,154 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestExplainNumbers(t *testing.T) {
+	t.Parallel()
+
+	data := map[string]any{
+		"a":       []any{json.Number("-1"), json.Number("1.50"), "dark"},
+		"b/c":     map[string]any{"~d": json.Number("2")},
+		"ok":      true,
+		"missing": nil,
+	}
+
+	testCases := []struct {
+		name                string
+		givenData           any
+		givenRules          sumRules
+		expectedExplanation *SumExplanation
+	}{
+		{
+			name:       "skipped values",
+			givenData:  data,
+			givenRules: sumRules{arithmetic: ArithmeticExact, strings: StringsIgnore},
+			expectedExplanation: &SumExplanation{
+				Sum:   "2.5",
+				Count: 3,
+				Values: []SumValue{
+					{Pointer: "/a/0", Value: "-1", Status: SumValueContributed},
+					{Pointer: "/a/1", Value: "1.50", Status: SumValueContributed},
+					{Pointer: "/a/2", Value: `"dark"`, Status: SumValueSkipped},
+					{Pointer: "/b~1c/~0d", Value: "2", Status: SumValueContributed},
+					{Pointer: "/missing", Value: "null", Status: SumValueSkipped},
+					{Pointer: "/ok", Value: "true", Status: SumValueSkipped},
+				},
+			},
+		},
+		{
+			name:       "numeric literals",
+			givenData:  data,
+			givenRules: sumRules{arithmetic: ArithmeticFloat, strings: StringsLenient, booleans: LiteralsNumeric, nulls: LiteralsNumeric},
+			expectedExplanation: &SumExplanation{
+				Sum:   "3.500000",
+				Count: 5,
+				Values: []SumValue{
+					{Pointer: "/a/0", Value: "-1", Status: SumValueContributed},
+					{Pointer: "/a/1", Value: "1.50", Status: SumValueContributed},
+					{Pointer: "/a/2", Value: `"dark"`, Status: SumValueSkipped},
+					{Pointer: "/b~1c/~0d", Value: "2", Status: SumValueContributed},
+					{Pointer: "/missing", Value: "null", Status: SumValueContributed},
+					{Pointer: "/ok", Value: "true", Status: SumValueContributed},
+				},
+			},
+		},
+		{
+			name:       "whole document",
+			givenData:  json.Number("7"),
+			givenRules: sumRules{arithmetic: ArithmeticExact},
+			expectedExplanation: &SumExplanation{
+				Sum:    "7",
+				Count:  1,
+				Values: []SumValue{{Value: "7", Status: SumValueContributed}},
+			},
+		},
+		{
+			name:       "typed slice",
+			givenData:  []int{1, 2},
+			givenRules: sumRules{arithmetic: ArithmeticExact},
+			expectedExplanation: &SumExplanation{
+				Sum:   "3",
+				Count: 2,
+				Values: []SumValue{
+					{Pointer: "/0", Value: "1", Status: SumValueContributed},
+					{Pointer: "/1", Value: "2", Status: SumValueContributed},
+				},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			observed, err := explainNumbers(tc.givenData, tc.givenRules)
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.expectedExplanation, observed)
+
+			sum, err := sumNumbers(tc.givenData, tc.givenRules)
+			require.NoError(t, err)
+			assert.Equal(t, sum, observed.Sum)
+		})
+	}
+}
+
+func TestExplainNumbers_rejected(t *testing.T) {
+	t.Parallel()
+
+	data := []any{json.Number("1"), true, "dark", map[string]any{"x": json.Number("2")}}
+
+	_, err := explainNumbers(data, sumRules{arithmetic: ArithmeticExact, strings: StringsStrict, booleans: LiteralsReject})
+
+	var explainedErr *ExplainedError
+	require.True(t, errors.As(err, &explainedErr), err)
+	assert.True(t, errors.Is(err, ErrUnsupportedValueType))
+
+	var pathErr *PathError
+	require.True(t, errors.As(err, &pathErr))
+	assert.Equal(t, "$[1]", pathErr.Path)
+
+	explanation := explainedErr.Explanation
+	assert.Equal(t, "3", explanation.Sum)
+	assert.Equal(t, 2, explanation.Count)
+
+	var statuses []SumValueStatus
+	for _, v := range explanation.Values {
+		statuses = append(statuses, v.Status)
+	}
+	assert.Equal(t, []SumValueStatus{SumValueContributed, SumValueRejected, SumValueRejected, SumValueContributed}, statuses)
+	assert.Contains(t, explanation.Values[2].Reason, ErrUnsupportedValueType.Error())
+}
+
+func TestDefaultService_SumStream_explain(t *testing.T) {
+	t.Parallel()
+
+	service := NewDefaultService(zap.NewNop(), nil, WithStringMode(StringsLenient))
+
+	doc := `{"a": [1, "2", "dark"], "b": 3}`
+
+	observed, err := service.SumStream(context.TODO(), strings.NewReader(doc), SumOptions{Explain: true})
+	require.NoError(t, err)
+
+	plain, err := service.SumStream(context.TODO(), strings.NewReader(doc), SumOptions{})
+	require.NoError(t, err)
+
+	assert.Equal(t, plain.Hash, observed.Hash)
+	assert.Nil(t, plain.Explanation)
+
+	require.NotNil(t, observed.Explanation)
+	assert.Equal(t, "6", observed.Explanation.Sum)
+	assert.Equal(t, 3, observed.Explanation.Count)
+	assert.Len(t, observed.Explanation.Values, 4)
+
+	_, err = service.SumStream(context.TODO(), strings.NewReader(`{"a":`), SumOptions{Explain: true})
+	assert.True(t, errors.Is(err, ErrDocumentInvalid), err)
+}
@@ -0,0 +1,308 @@
+package service
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// SigningKey is a key tokens are signed or verified with.
+type SigningKey struct {
+	// ID is stamped in the kid header of the tokens signed with the key.
+	// Asymmetric keys use their RFC 7638 JWK thumbprint.
+	ID     string
+	Method jwt.SigningMethod
+
+	// Private signs tokens. It is nil for keys kept only to verify tokens during a rotation.
+	Private crypto.PrivateKey
+
+	// Public verifies tokens. For HMAC keys it is the shared secret.
+	Public crypto.PublicKey
+}
+
+// HMACKeyID is the ID of the HS256 key given to NewDefaultService, stamped in the kid header of the tokens it signs.
+const HMACKeyID = "hs256"
+
+// NewHMACKey creates an HS256 key from a shared secret.
+// Its ID is given explicitly since deriving one from the secret would leak information about it.
+func NewHMACKey(id string, secret []byte) *SigningKey {
+	return &SigningKey{
+		ID:      id,
+		Method:  jwt.SigningMethodHS256,
+		Private: secret,
+		Public:  secret,
+	}
+}
+
+// LoadSigningKey reads a PEM encoded key from path. See ParseSigningKey.
+func LoadSigningKey(path string) (*SigningKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read key file: %w", err)
+	}
+
+	key, err := ParseSigningKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse key file %s: %w", path, err)
+	}
+	return key, nil
+}
+
+// ParseSigningKey parses a PEM encoded RSA, ECDSA or Ed25519 key.
+// Private keys (PKCS#1, SEC 1 or PKCS#8) can sign and verify tokens,
+// public keys (PKIX or PKCS#1) can only verify them.
+// The signing method follows from the key: RS256, ES256/ES384/ES512 depending on the curve, or EdDSA.
+func ParseSigningKey(data []byte) (*SigningKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("could not decode PEM block: %w", ErrUnsupportedKey)
+	}
+
+	var (
+		private crypto.PrivateKey
+		public  crypto.PublicKey
+		err     error
+	)
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		private, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		private, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		private, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		public, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		public, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("could not use PEM block %q: %w", block.Type, ErrUnsupportedKey)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not parse %s: %w", block.Type, err)
+	}
+
+	if private != nil {
+		signer, ok := private.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("could not use private key of type %T: %w", private, ErrUnsupportedKey)
+		}
+		public = signer.Public()
+	}
+
+	method, err := signingMethodFor(public)
+	if err != nil {
+		return nil, err
+	}
+
+	jwk, err := newJWK(public)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SigningKey{
+		ID:      jwk.thumbprint(),
+		Method:  method,
+		Private: private,
+		Public:  public,
+	}, nil
+}
+
+func signingMethodFor(public crypto.PublicKey) (jwt.SigningMethod, error) {
+	switch pub := public.(type) {
+	case *rsa.PublicKey:
+		return jwt.SigningMethodRS256, nil
+	case *ecdsa.PublicKey:
+		switch pub.Curve {
+		case elliptic.P256():
+			return jwt.SigningMethodES256, nil
+		case elliptic.P384():
+			return jwt.SigningMethodES384, nil
+		case elliptic.P521():
+			return jwt.SigningMethodES512, nil
+		}
+		return nil, fmt.Errorf("could not use curve %s: %w", pub.Curve.Params().Name, ErrUnsupportedKey)
+	case ed25519.PublicKey:
+		return jwt.SigningMethodEdDSA, nil
+	default:
+		return nil, fmt.Errorf("could not use public key of type %T: %w", public, ErrUnsupportedKey)
+	}
+}
+
+// KeySet holds the key new tokens are signed with and every key tokens may still be verified with.
+type KeySet struct {
+	signing *SigningKey
+	keys    map[string]*SigningKey
+
+	// legacy verifies tokens without a kid header, issued before keys had IDs.
+	legacy *SigningKey
+}
+
+// NewKeySet creates a KeySet signing with signing and verifying with it and the other keys.
+// Keeping the previous keys around lets tokens signed before a rotation live until they expire.
+func NewKeySet(signing *SigningKey, verification ...*SigningKey) (*KeySet, error) {
+	if signing == nil || signing.Private == nil {
+		return nil, fmt.Errorf("could not sign without a private key: %w", ErrUnsupportedKey)
+	}
+
+	ks := KeySet{
+		signing: signing,
+		keys:    make(map[string]*SigningKey, len(verification)+1),
+	}
+
+	for _, key := range append([]*SigningKey{signing}, verification...) {
+		if _, ok := ks.keys[key.ID]; ok {
+			return nil, fmt.Errorf("could not add key %q twice: %w", key.ID, ErrUnsupportedKey)
+		}
+		ks.keys[key.ID] = key
+
+		if ks.legacy == nil && key.Method == jwt.SigningMethodHS256 {
+			ks.legacy = key
+		}
+	}
+	return &ks, nil
+}
+
+// sign signs the claims with the signing key and stamps its ID in the kid header.
+func (ks *KeySet) sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(ks.signing.Method, claims)
+	token.Header["kid"] = ks.signing.ID
+
+	return token.SignedString(ks.signing.Private)
+}
+
+// keyFunc picks the key named by the kid header of token,
+// refusing tokens whose algorithm doesn't match the key's.
+// Checking the algorithm stops an attacker from, say, presenting an HS256 token
+// signed with an RSA public key as the HMAC secret.
+func (ks *KeySet) keyFunc(token *jwt.Token) (any, error) {
+	key := ks.legacy
+
+	if kid, ok := token.Header["kid"]; ok {
+		id, _ := kid.(string)
+		key = ks.keys[id]
+	}
+
+	if key == nil {
+		return nil, ErrTokenUnknownKey
+	}
+
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, ErrTokenInvalidAlgorithm
+	}
+	return key.Public, nil
+}
+
+// publicKeys returns the asymmetric keys tokens may be verified with, ordered by ID.
+// HMAC keys are left out since publishing them would let anyone forge tokens.
+func (ks *KeySet) publicKeys() []JWK {
+	var keys []JWK
+	for _, key := range ks.keys {
+		if key.Method == jwt.SigningMethodHS256 {
+			continue
+		}
+
+		// The key was parsed into a JWK when it was loaded, so this can't fail.
+		jwk, _ := newJWK(key.Public)
+		jwk.KeyID = key.ID
+		jwk.Algorithm = key.Method.Alg()
+		keys = append(keys, *jwk)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].KeyID < keys[j].KeyID
+	})
+	return keys
+}
+
+// algorithms returns the sorted, distinct algorithms of the keys publicKeys returns.
+// HS256 is left out along with the HMAC keys, since relying parties couldn't verify it.
+func (ks *KeySet) algorithms() []string {
+	seen := make(map[string]bool, len(ks.keys))
+
+	var algs []string
+	for _, key := range ks.keys {
+		if key.Method == jwt.SigningMethodHS256 {
+			continue
+		}
+
+		if alg := key.Method.Alg(); !seen[alg] {
+			seen[alg] = true
+			algs = append(algs, alg)
+		}
+	}
+
+	sort.Strings(algs)
+	return algs
+}
+
+// JWK is the public part of a key as a JSON Web Key (RFC 7517).
+// Members that don't apply to the key type are empty.
+type JWK struct {
+	KeyID     string
+	KeyType   string
+	Algorithm string
+
+	// Curve, X and Y describe EC and OKP keys.
+	Curve string
+	X, Y  string
+
+	// N and E are the modulus and exponent of RSA keys.
+	N, E string
+}
+
+func newJWK(public crypto.PublicKey) (*JWK, error) {
+	switch pub := public.(type) {
+	case *rsa.PublicKey:
+		return &JWK{
+			KeyType: "RSA",
+			N:       base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			E:       base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}, nil
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		return &JWK{
+			KeyType: "EC",
+			Curve:   pub.Curve.Params().Name,
+			X:       base64.RawURLEncoding.EncodeToString(pub.X.FillBytes(make([]byte, size))),
+			Y:       base64.RawURLEncoding.EncodeToString(pub.Y.FillBytes(make([]byte, size))),
+		}, nil
+	case ed25519.PublicKey:
+		return &JWK{
+			KeyType: "OKP",
+			Curve:   "Ed25519",
+			X:       base64.RawURLEncoding.EncodeToString(pub),
+		}, nil
+	default:
+		return nil, fmt.Errorf("could not use public key of type %T: %w", public, ErrUnsupportedKey)
+	}
+}
+
+// thumbprint returns the RFC 7638 SHA-256 thumbprint of the key:
+// the hash of its required members, in lexicographic order and without whitespace.
+func (k *JWK) thumbprint() string {
+	var members string
+	switch k.KeyType {
+	case "RSA":
+		members = fmt.Sprintf(`{"e":%q,"kty":%q,"n":%q}`, k.E, k.KeyType, k.N)
+	case "EC":
+		members = fmt.Sprintf(`{"crv":%q,"kty":%q,"x":%q,"y":%q}`, k.Curve, k.KeyType, k.X, k.Y)
+	default:
+		members = fmt.Sprintf(`{"crv":%q,"kty":%q,"x":%q}`, k.Curve, k.KeyType, k.X)
+	}
+
+	sum := sha256.Sum256([]byte(members))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
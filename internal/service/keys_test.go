@@ -0,0 +1,326 @@
+package service
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestParseSigningKey(t *testing.T) {
+	t.Parallel()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	ecP384Key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	ecDER, err := x509.MarshalECPrivateKey(ecKey)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name           string
+		givenPEM       []byte
+		expectedMethod jwt.SigningMethod
+		expectedSigner bool
+	}{
+		{
+			name:           "PKCS#1 RSA private key",
+			givenPEM:       pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)}),
+			expectedMethod: jwt.SigningMethodRS256,
+			expectedSigner: true,
+		},
+		{
+			name:           "PKCS#1 RSA public key",
+			givenPEM:       pem.EncodeToMemory(&pem.Block{Type: "RSA PUBLIC KEY", Bytes: x509.MarshalPKCS1PublicKey(&rsaKey.PublicKey)}),
+			expectedMethod: jwt.SigningMethodRS256,
+		},
+		{
+			name:           "SEC 1 EC private key",
+			givenPEM:       pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: ecDER}),
+			expectedMethod: jwt.SigningMethodES256,
+			expectedSigner: true,
+		},
+		{
+			name:           "PKCS#8 P-384 private key",
+			givenPEM:       testPKCS8PEM(t, ecP384Key),
+			expectedMethod: jwt.SigningMethodES384,
+			expectedSigner: true,
+		},
+		{
+			name:           "PKCS#8 Ed25519 private key",
+			givenPEM:       testPKCS8PEM(t, edKey),
+			expectedMethod: jwt.SigningMethodEdDSA,
+			expectedSigner: true,
+		},
+		{
+			name:           "PKIX Ed25519 public key",
+			givenPEM:       testPKIXPEM(t, edKey.Public()),
+			expectedMethod: jwt.SigningMethodEdDSA,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			observed, err := ParseSigningKey(tc.givenPEM)
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.expectedMethod, observed.Method)
+			assert.Equal(t, tc.expectedSigner, observed.Private != nil)
+			assert.NotEmpty(t, observed.ID)
+		})
+	}
+
+	t.Run("private and public halves share the key ID", func(t *testing.T) {
+		private, err := ParseSigningKey(testPKCS8PEM(t, edKey))
+		require.NoError(t, err)
+
+		public, err := ParseSigningKey(testPKIXPEM(t, edKey.Public()))
+		require.NoError(t, err)
+
+		assert.Equal(t, private.ID, public.ID)
+	})
+
+	t.Run("not PEM", func(t *testing.T) {
+		_, err := ParseSigningKey([]byte("foo"))
+		assert.True(t, errors.Is(err, ErrUnsupportedKey))
+	})
+
+	t.Run("unsupported block", func(t *testing.T) {
+		_, err := ParseSigningKey(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte("foo")}))
+		assert.True(t, errors.Is(err, ErrUnsupportedKey))
+	})
+}
+
+func TestJWK_thumbprint(t *testing.T) {
+	t.Parallel()
+
+	// Example from RFC 7638, section 3.1.
+	key := JWK{
+		KeyType: "RSA",
+		N:       "0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw",
+		E:       "AQAB",
+	}
+
+	assert.Equal(t, "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs", key.thumbprint())
+}
+
+func TestDefaultService_asymmetricKeys(t *testing.T) {
+	t.Parallel()
+
+	creds := Credentials{
+		Username: "foo-username",
+		Password: "bar-password",
+	}
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	oldKey, err := ParseSigningKey(testPKCS8PEM(t, ecKey))
+	require.NoError(t, err)
+
+	newKey, err := ParseSigningKey(testPKCS8PEM(t, edKey))
+	require.NoError(t, err)
+
+	users := newTestUserStore(t, creds)
+
+	oldKeys, err := NewKeySet(oldKey)
+	require.NoError(t, err)
+
+	oldService := NewDefaultService(zap.NewNop(), nil, WithUserStore(users), WithKeySet(oldKeys))
+
+	// After the rotation the old key only verifies tokens.
+	oldPublic, err := ParseSigningKey(testPKIXPEM(t, ecKey.Public()))
+	require.NoError(t, err)
+
+	newKeys, err := NewKeySet(newKey, oldPublic)
+	require.NoError(t, err)
+
+	newService := NewDefaultService(zap.NewNop(), nil, WithUserStore(users), WithKeySet(newKeys))
+
+	t.Run("stamps the key ID", func(t *testing.T) {
+		givenToken, err := newService.GenerateToken(context.TODO(), creds)
+		require.NoError(t, err)
+
+		parsed, _, err := new(jwt.Parser).ParseUnverified(givenToken.AccessToken, &Claims{})
+		require.NoError(t, err)
+
+		assert.Equal(t, newKey.ID, parsed.Header["kid"])
+		assert.Equal(t, "EdDSA", parsed.Header["alg"])
+		_, err = newService.VerifyToken(context.TODO(), givenToken.AccessToken)
+		assert.NoError(t, err)
+	})
+
+	t.Run("tokens signed before the rotation still verify", func(t *testing.T) {
+		givenToken, err := oldService.GenerateToken(context.TODO(), creds)
+		require.NoError(t, err)
+
+		_, err = newService.VerifyToken(context.TODO(), givenToken.AccessToken)
+		assert.NoError(t, err)
+	})
+
+	t.Run("tokens signed after the rotation are unknown to the old key set", func(t *testing.T) {
+		givenToken, err := newService.GenerateToken(context.TODO(), creds)
+		require.NoError(t, err)
+
+		_, observedErr := oldService.VerifyToken(context.TODO(), givenToken.AccessToken)
+		assert.True(t, errors.Is(observedErr, ErrTokenUnknownKey))
+	})
+
+	t.Run("algorithm mismatch", func(t *testing.T) {
+		// An HS256 token claiming the key of the EdDSA key, signed with its public key as the secret.
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
+			StandardClaims: jwt.StandardClaims{
+				Subject:   "foo-username",
+				Issuer:    "foo-issuer",
+				Audience:  "foo-audience",
+				ExpiresAt: time.Now().Add(time.Hour).Unix(),
+			},
+		})
+		token.Header["kid"] = newKey.ID
+
+		signedToken, err := token.SignedString([]byte(edKey.Public().(ed25519.PublicKey)))
+		require.NoError(t, err)
+
+		_, observedErr := newService.VerifyToken(context.TODO(), signedToken)
+		assert.True(t, errors.Is(observedErr, ErrTokenInvalidAlgorithm))
+	})
+
+	t.Run("tokens without key ID need an HMAC key", func(t *testing.T) {
+		token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, Claims{
+			StandardClaims: jwt.StandardClaims{
+				Subject:   "foo-username",
+				Issuer:    "foo-issuer",
+				Audience:  "foo-audience",
+				ExpiresAt: time.Now().Add(time.Hour).Unix(),
+			},
+		})
+
+		signedToken, err := token.SignedString(edKey)
+		require.NoError(t, err)
+
+		_, observedErr := newService.VerifyToken(context.TODO(), signedToken)
+		assert.True(t, errors.Is(observedErr, ErrTokenUnknownKey))
+	})
+}
+
+func TestDefaultService_ProviderMetadata(t *testing.T) {
+	t.Parallel()
+
+	t.Run("HMAC keys are not published", func(t *testing.T) {
+		svc := NewDefaultService(zap.NewNop(), []byte("foo-key"))
+
+		observed, err := svc.ProviderMetadata(context.TODO())
+		require.NoError(t, err)
+
+		assert.Equal(t, "foo-issuer", observed.Issuer)
+		assert.Empty(t, observed.SigningAlgorithms)
+		assert.Empty(t, observed.Keys)
+	})
+
+	t.Run("asymmetric keys", func(t *testing.T) {
+		ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+
+		_, edKey, err := ed25519.GenerateKey(rand.Reader)
+		require.NoError(t, err)
+
+		signing, err := ParseSigningKey(testPKCS8PEM(t, edKey))
+		require.NoError(t, err)
+
+		previous, err := ParseSigningKey(testPKIXPEM(t, ecKey.Public()))
+		require.NoError(t, err)
+
+		keys, err := NewKeySet(signing, previous, NewHMACKey("foo", []byte("bar")))
+		require.NoError(t, err)
+
+		svc := NewDefaultService(zap.NewNop(), nil, WithKeySet(keys))
+
+		observed, err := svc.ProviderMetadata(context.TODO())
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"ES256", "EdDSA"}, observed.SigningAlgorithms)
+		require.Len(t, observed.Keys, 2)
+
+		for _, key := range observed.Keys {
+			switch key.KeyID {
+			case signing.ID:
+				assert.Equal(t, "OKP", key.KeyType)
+				assert.Equal(t, "EdDSA", key.Algorithm)
+				assert.Equal(t, "Ed25519", key.Curve)
+				assert.NotEmpty(t, key.X)
+			case previous.ID:
+				assert.Equal(t, "EC", key.KeyType)
+				assert.Equal(t, "ES256", key.Algorithm)
+				assert.Equal(t, "P-256", key.Curve)
+				assert.NotEmpty(t, key.X)
+				assert.NotEmpty(t, key.Y)
+			default:
+				t.Errorf("unexpected key %q", key.KeyID)
+			}
+		}
+	})
+}
+
+func TestNewKeySet(t *testing.T) {
+	t.Parallel()
+
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	public, err := ParseSigningKey(testPKIXPEM(t, edKey.Public()))
+	require.NoError(t, err)
+
+	private, err := ParseSigningKey(testPKCS8PEM(t, edKey))
+	require.NoError(t, err)
+
+	_, err = NewKeySet(public)
+	assert.True(t, errors.Is(err, ErrUnsupportedKey), "public keys can't sign")
+
+	_, err = NewKeySet(private, public)
+	assert.True(t, errors.Is(err, ErrUnsupportedKey), "duplicate key IDs")
+
+	_, err = NewKeySet(NewHMACKey("foo", []byte("bar")), public)
+	assert.NoError(t, err)
+}
+
+func testPKCS8PEM(t *testing.T, key crypto.PrivateKey) []byte {
+	t.Helper()
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+func testPKIXPEM(t *testing.T, key crypto.PublicKey) []byte {
+	t.Helper()
+
+	der, err := x509.MarshalPKIXPublicKey(key)
+	require.NoError(t, err)
+
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+}
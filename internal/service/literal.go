@@ -0,0 +1,50 @@
+package service
+
+import (
+	"fmt"
+)
+
+// LiteralPolicy selects what Sum does with the booleans, or the nulls, found in a document.
+type LiteralPolicy string
+
+const (
+	// LiteralsIgnore skips the values.
+	LiteralsIgnore LiteralPolicy = "ignore"
+
+	// LiteralsNumeric sums true as 1, and false and null as 0.
+	LiteralsNumeric LiteralPolicy = "numeric"
+
+	// LiteralsReject refuses documents holding the values with a PathError wrapping ErrUnsupportedValueType.
+	LiteralsReject LiteralPolicy = "reject"
+)
+
+// ParseLiteralPolicy parses the name of a literal policy.
+func ParseLiteralPolicy(s string) (LiteralPolicy, error) {
+	switch p := LiteralPolicy(s); p {
+	case LiteralsIgnore, LiteralsNumeric, LiteralsReject:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unknown literal policy %q: %w", s, ErrUnsupportedLiteralPolicy)
+	}
+}
+
+// number returns the literal of the number v, a boolean or nil, stands for under the policy,
+// or an empty string when v is skipped.
+func (p LiteralPolicy) number(v any) (string, error) {
+	switch p {
+	case LiteralsNumeric:
+		if v == true {
+			return "1", nil
+		}
+		return "0", nil
+
+	case LiteralsReject:
+		if v == nil {
+			return "", fmt.Errorf("could not sum null: %w", ErrUnsupportedValueType)
+		}
+		return "", fmt.Errorf("could not sum %v: %w", v, ErrUnsupportedValueType)
+
+	default:
+		return "", nil
+	}
+}
@@ -0,0 +1,145 @@
+package service
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// LockoutOptions throttle password guessing against the credentials checked by GenerateToken and the password grant.
+// Once a username or an IP address reaches its threshold of failed attempts, it is locked out for Lockout,
+// and every further failure doubles the lockout, up to MaxLockout.
+type LockoutOptions struct {
+	// UserThreshold is the number of failed attempts against a username before it is locked out.
+	// Zero disables the per-username limit.
+	UserThreshold int
+
+	// IPThreshold is the number of failed attempts from an IP address before it is locked out.
+	// It is usually higher than UserThreshold, since many users may share an address.
+	// Zero disables the per-IP limit.
+	IPThreshold int
+
+	// Lockout is how long the first lockout lasts.
+	Lockout time.Duration
+
+	// MaxLockout caps the lockout.
+	MaxLockout time.Duration
+
+	// Window is how long failed attempts are remembered after the last one.
+	Window time.Duration
+}
+
+// DefaultLockoutOptions returns the lockout options used unless WithLockout is given.
+func DefaultLockoutOptions() LockoutOptions {
+	return LockoutOptions{
+		UserThreshold: 5,
+		IPThreshold:   20,
+		Lockout:       30 * time.Second,
+		MaxLockout:    15 * time.Minute,
+		Window:        15 * time.Minute,
+	}
+}
+
+// lockout returns how long a key with the given failures is locked out after its last failure.
+func (o LockoutOptions) lockout(failures, threshold int) time.Duration {
+	if threshold <= 0 || failures < threshold {
+		return 0
+	}
+
+	d := o.Lockout
+	for i := threshold; i < failures && d < o.MaxLockout; i++ {
+		d *= 2
+	}
+
+	if d > o.MaxLockout {
+		return o.MaxLockout
+	}
+	return d
+}
+
+// window returns how long failed attempts are kept, which is at least as long as the longest lockout
+// so that a lockout isn't lifted by the attempts being forgotten.
+func (o LockoutOptions) window() time.Duration {
+	if o.Window < o.MaxLockout {
+		return o.MaxLockout
+	}
+	return o.Window
+}
+
+// LockoutError is returned when credentials are presented for a username or from an IP address that is locked out.
+type LockoutError struct {
+	// RetryAfter is how long until the lockout is lifted.
+	RetryAfter time.Duration
+}
+
+func (e *LockoutError) Error() string {
+	return fmt.Sprintf("%v: retry after %s", ErrTooManyAttempts, e.RetryAfter.Round(time.Second))
+}
+
+// Unwrap lets errors.Is match ErrTooManyAttempts.
+func (e *LockoutError) Unwrap() error {
+	return ErrTooManyAttempts
+}
+
+// attemptKey is a key failed attempts are counted under.
+type attemptKey struct {
+	key       string
+	threshold int
+}
+
+// attemptKeys returns the keys the failed attempts of creds are counted under.
+func (o LockoutOptions) attemptKeys(creds Credentials) []attemptKey {
+	var keys []attemptKey
+	if o.UserThreshold > 0 {
+		keys = append(keys, attemptKey{key: userAttemptKey(creds.Username), threshold: o.UserThreshold})
+	}
+
+	if o.IPThreshold > 0 && creds.RemoteIP != "" {
+		keys = append(keys, attemptKey{key: "ip:" + creds.RemoteIP, threshold: o.IPThreshold})
+	}
+	return keys
+}
+
+func userAttemptKey(username string) string {
+	return "user:" + username
+}
+
+// keyedMutex is a mutex per attempt key, so that the attempts against a key are decided one at a time.
+// A key's mutex is dropped once nobody holds or waits for it.
+type keyedMutex struct {
+	mu    sync.Mutex
+	locks map[string]*keyedLock
+}
+
+type keyedLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+func newKeyedMutex() *keyedMutex {
+	return &keyedMutex{locks: make(map[string]*keyedLock)}
+}
+
+// lock locks key and returns the function unlocking it.
+func (m *keyedMutex) lock(key string) (unlock func()) {
+	m.mu.Lock()
+	l, ok := m.locks[key]
+	if !ok {
+		l = &keyedLock{}
+		m.locks[key] = l
+	}
+	l.refs++
+	m.mu.Unlock()
+
+	l.mu.Lock()
+	return func() {
+		l.mu.Unlock()
+
+		m.mu.Lock()
+		l.refs--
+		if l.refs == 0 {
+			delete(m.locks, key)
+		}
+		m.mu.Unlock()
+	}
+}
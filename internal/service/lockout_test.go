@@ -0,0 +1,182 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestLockoutOptions_lockout(t *testing.T) {
+	t.Parallel()
+
+	opts := LockoutOptions{
+		Lockout:    30 * time.Second,
+		MaxLockout: 5 * time.Minute,
+	}
+
+	testCases := []struct {
+		name           string
+		givenFailures  int
+		givenThreshold int
+		expected       time.Duration
+	}{
+		{
+			name:           "below threshold",
+			givenFailures:  4,
+			givenThreshold: 5,
+		},
+		{
+			name:           "at threshold",
+			givenFailures:  5,
+			givenThreshold: 5,
+			expected:       30 * time.Second,
+		},
+		{
+			name:           "doubles with each failure",
+			givenFailures:  7,
+			givenThreshold: 5,
+			expected:       2 * time.Minute,
+		},
+		{
+			name:           "capped",
+			givenFailures:  100,
+			givenThreshold: 5,
+			expected:       5 * time.Minute,
+		},
+		{
+			name:           "disabled",
+			givenFailures:  100,
+			givenThreshold: 0,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, opts.lockout(tc.givenFailures, tc.givenThreshold))
+		})
+	}
+}
+
+func TestDefaultService_lockout(t *testing.T) {
+	t.Parallel()
+
+	foo := Credentials{Username: "foo", Password: "foo-password"}
+	bar := Credentials{Username: "bar", Password: "bar-password"}
+
+	newService := func(t *testing.T, opts LockoutOptions) *DefaultService {
+		t.Helper()
+
+		return NewDefaultService(zap.NewNop(), []byte("foo-key"),
+			WithUserStore(newTestUserStore(t, foo, bar)),
+			WithLockout(opts, NewMemoryAttemptStore()),
+		)
+	}
+
+	wrong := func(creds Credentials, ip string) Credentials {
+		return Credentials{Username: creds.Username, Password: "wrong-password", RemoteIP: ip}
+	}
+
+	t.Run("username", func(t *testing.T) {
+		service := newService(t, LockoutOptions{UserThreshold: 2, Lockout: time.Hour, MaxLockout: time.Hour})
+
+		for _, ip := range []string{"192.0.2.1", "192.0.2.2"} {
+			_, err := service.GenerateToken(context.TODO(), wrong(foo, ip))
+			require.True(t, errors.Is(err, ErrCredentialsMismatch), err)
+		}
+
+		// Even the right password is refused during the lockout.
+		_, err := service.GenerateToken(context.TODO(), foo)
+		assert.True(t, errors.Is(err, ErrTooManyAttempts), err)
+
+		var lockoutErr *LockoutError
+		require.True(t, errors.As(err, &lockoutErr))
+		assert.InDelta(t, time.Hour.Seconds(), lockoutErr.RetryAfter.Seconds(), 5)
+
+		_, err = service.GenerateToken(context.TODO(), bar)
+		assert.NoError(t, err)
+	})
+
+	t.Run("IP address", func(t *testing.T) {
+		service := newService(t, LockoutOptions{IPThreshold: 2, Lockout: time.Hour, MaxLockout: time.Hour})
+
+		for _, creds := range []Credentials{foo, bar} {
+			_, err := service.GenerateToken(context.TODO(), wrong(creds, "192.0.2.1"))
+			require.True(t, errors.Is(err, ErrCredentialsMismatch), err)
+		}
+
+		_, err := service.GenerateToken(context.TODO(), Credentials{Username: foo.Username, Password: foo.Password, RemoteIP: "192.0.2.1"})
+		assert.True(t, errors.Is(err, ErrTooManyAttempts), err)
+
+		_, err = service.GenerateToken(context.TODO(), Credentials{Username: foo.Username, Password: foo.Password, RemoteIP: "192.0.2.2"})
+		assert.NoError(t, err)
+	})
+
+	t.Run("successful login resets the username", func(t *testing.T) {
+		service := newService(t, LockoutOptions{UserThreshold: 2, Lockout: time.Hour, MaxLockout: time.Hour})
+
+		_, err := service.GenerateToken(context.TODO(), wrong(foo, ""))
+		require.True(t, errors.Is(err, ErrCredentialsMismatch), err)
+
+		_, err = service.GenerateToken(context.TODO(), foo)
+		require.NoError(t, err)
+
+		_, err = service.GenerateToken(context.TODO(), wrong(foo, ""))
+		require.True(t, errors.Is(err, ErrCredentialsMismatch), err)
+
+		_, err = service.GenerateToken(context.TODO(), foo)
+		assert.NoError(t, err)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		service := newService(t, LockoutOptions{})
+
+		for i := 0; i < 10; i++ {
+			_, err := service.GenerateToken(context.TODO(), wrong(foo, "192.0.2.1"))
+			require.True(t, errors.Is(err, ErrCredentialsMismatch), err)
+		}
+
+		_, err := service.GenerateToken(context.TODO(), foo)
+		assert.NoError(t, err)
+	})
+	t.Run("concurrent guesses", func(t *testing.T) {
+		service := newService(t, LockoutOptions{UserThreshold: 5, Lockout: time.Hour, MaxLockout: time.Hour})
+
+		errs := make(chan error, 100)
+
+		var wg sync.WaitGroup
+		for i := 0; i < cap(errs); i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				_, err := service.GenerateToken(context.TODO(), wrong(foo, ""))
+				errs <- err
+			}()
+		}
+
+		wg.Wait()
+		close(errs)
+
+		// Only the guesses made before the lockout get their password checked.
+		var mismatches, lockouts int
+		for err := range errs {
+			switch {
+			case errors.Is(err, ErrCredentialsMismatch):
+				mismatches++
+			case errors.Is(err, ErrTooManyAttempts):
+				lockouts++
+			default:
+				t.Errorf("unexpected error: %v", err)
+			}
+		}
+
+		assert.Equal(t, 5, mismatches)
+		assert.Equal(t, 95, lockouts)
+	})
+}
@@ -3,6 +3,10 @@ package service
 type Credentials struct {
 	Username string
 	Password string
+
+	// RemoteIP is the address the credentials were sent from, counted against when they fail.
+	// Empty skips the per-IP lockout.
+	RemoteIP string
 }
 
 func (c Credentials) validate() error {
@@ -16,8 +20,117 @@ func (c Credentials) validate() error {
 	return nil
 }
 
+// Grant types accepted by Grant, as defined by RFC 6749.
+const (
+	GrantPassword          string = "password"
+	GrantClientCredentials string = "client_credentials"
+	GrantRefreshToken      string = "refresh_token"
+)
+
+// ClientCredentials authenticate an OAuth client.
+type ClientCredentials struct {
+	ID     string
+	Secret string
+}
+
+// GrantRequest is a request to the OAuth 2.0 token endpoint.
+type GrantRequest struct {
+	GrantType string
+	Client    ClientCredentials
+
+	// Credentials are the resource owner's, for the password grant.
+	Credentials Credentials
+
+	// RefreshToken is the token to exchange, for the refresh_token grant.
+	RefreshToken string
+
+	// Scopes narrow the scopes granted to the token. Empty requests every scope available.
+	Scopes []string
+}
+
 type Token struct {
-	AccessToken string
-	TokenType   string
-	ExpiresIn   int64
+	AccessToken  string
+	TokenType    string
+	ExpiresIn    int64
+	RefreshToken string
+
+	// Scope is the space-delimited list of scopes granted to the access token.
+	Scope string
+}
+
+// SumLimits bound the documents Sum accepts, so that a single request can't exhaust the stack or the memory.
+// Zero disables a limit.
+type SumLimits struct {
+	// MaxDepth is how many arrays and objects a value may be nested in: the 1 of [1] is at depth 1,
+	// the one of [[1]] at depth 2.
+	MaxDepth int
+
+	// MaxNodes is how many values the document may hold, counting arrays and objects along with the values in them.
+	MaxNodes int
+}
+
+// DefaultSumLimits returns the limits used unless WithSumLimits is given.
+func DefaultSumLimits() SumLimits {
+	return SumLimits{
+		MaxDepth: 64,
+		MaxNodes: 100000,
+	}
+}
+
+// SumOptions tunes a single call to Sum.
+type SumOptions struct {
+	// Algorithm names the digest algorithm. Empty selects the service default.
+	Algorithm string
+
+	// Encoding names the digest encoding. Empty selects the service default.
+	Encoding string
+
+	// Strings selects what is done with the strings of the document. Empty selects the service default.
+	Strings StringMode
+
+	// Booleans and Nulls select what is done with the booleans and nulls of the document.
+	// Empty selects the service default.
+	Booleans LiteralPolicy
+	Nulls    LiteralPolicy
+
+	// Explain asks for the SumExplanation of the result.
+	Explain bool
+
+	// Include and Exclude are selectors, as parsed by ParseSelector, picking the values summed:
+	// the values selected by Include, or all of them if it is empty, but those selected by Exclude.
+	Include []string
+	Exclude []string
+}
+
+type SumResult struct {
+	Hash      string
+	Algorithm string
+	Encoding  string
+
+	// Explanation tells what became of each value of the document, when SumOptions.Explain asks for it.
+	Explanation *SumExplanation
+}
+
+// SumBatchItem is the result of summing a document of a batch, or the error that kept it from being summed.
+type SumBatchItem struct {
+	Result *SumResult
+	Err    error
+}
+
+// AggregateResult holds the digests of the results of an aggregate, by operation.
+type AggregateResult struct {
+	Hashes    map[Operation]string
+	Algorithm string
+	Encoding  string
+}
+
+// ProviderMetadata tells relying parties how to verify the tokens issued by the service.
+type ProviderMetadata struct {
+	Issuer string
+
+	// SigningAlgorithms are the algorithms of Keys. It is empty when only HMAC keys are configured.
+	SigningAlgorithms []string
+
+	// Keys are the public keys tokens may be verified with. Shared HMAC secrets are never listed.
+	Keys []JWK
 }
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// PathError is an error about a single value of a document.
+type PathError struct {
+	// Path is the JSONPath of the value, such as $.a[2] or $["first name"].
+	Path string
+	Err  error
+}
+
+func (e *PathError) Error() string {
+	return fmt.Sprintf("%v at %s", e.Err, e.Path)
+}
+
+// Unwrap lets errors.Is match the error about the value.
+func (e *PathError) Unwrap() error {
+	return e.Err
+}
+
+func indexSegment(i int) string {
+	return "[" + strconv.Itoa(i) + "]"
+}
+
+// keySegment writes keys made of letters, digits and underscores with the dot notation,
+// and quotes any other key.
+func keySegment(key string) string {
+	for i, r := range key {
+		if !(r == '_' || 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || i > 0 && '0' <= r && r <= '9') {
+			return "[" + strconv.Quote(key) + "]"
+		}
+	}
+
+	if key == "" {
+		return `[""]`
+	}
+	return "." + key
+}
+
+// pathStep is a step on the path to a value of a document: the key of an object member or the index of an array element.
+type pathStep struct {
+	key   string
+	index int
+	isKey bool
+}
+
+func keyStep(key string) pathStep {
+	return pathStep{key: key, isKey: true}
+}
+
+func indexStep(i int) pathStep {
+	return pathStep{index: i}
+}
+
+// jsonPath returns the JSONPath of the value at the end of path.
+func jsonPath(path []pathStep) string {
+	var b strings.Builder
+	b.WriteString("$")
+	for _, step := range path {
+		if step.isKey {
+			b.WriteString(keySegment(step.key))
+		} else {
+			b.WriteString(indexSegment(step.index))
+		}
+	}
+	return b.String()
+}
+
+// jsonPointer returns the JSON Pointer (RFC 6901) of the value at the end of path.
+func jsonPointer(path []pathStep) string {
+	var b strings.Builder
+	for _, step := range path {
+		b.WriteString("/")
+		if step.isKey {
+			b.WriteString(pointerEscaper.Replace(step.key))
+		} else {
+			b.WriteString(strconv.Itoa(step.index))
+		}
+	}
+	return b.String()
+}
+
+// pointerEscaper escapes keys as JSON Pointer reference tokens.
+var pointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
@@ -0,0 +1,130 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RefreshTokenStore persists the refresh tokens handed out with access tokens.
+// Tokens are stored by ID, the SHA-256 of the opaque token, so a leaked store can't be replayed.
+type RefreshTokenStore interface {
+	// Create stores a new refresh token.
+	Create(ctx context.Context, record RefreshTokenRecord) error
+
+	// Consume marks the token as used and returns the record as it was before,
+	// or ErrRefreshTokenInvalid if there is no such token.
+	// Concurrent calls for the same ID must see the token as used at most once.
+	Consume(ctx context.Context, id string) (*RefreshTokenRecord, error)
+
+	// RevokeFamily revokes every token descending from the same login.
+	RevokeFamily(ctx context.Context, familyID string) error
+}
+
+// RefreshTokenRecord is the server-side state of a refresh token.
+type RefreshTokenRecord struct {
+	ID       string
+	FamilyID string
+	Subject  string
+
+	// ClientID is the OAuth client the token was issued to, empty for tokens issued by /auth.
+	ClientID string
+
+	// Scopes are granted to the access tokens the refresh token is exchanged for.
+	Scopes []string
+
+	ExpiresAt time.Time
+	Used      bool
+	Revoked   bool
+}
+
+var _ RefreshTokenStore = &MemoryRefreshTokenStore{}
+
+// memorySweepInterval is how often MemoryRefreshTokenStore drops expired tokens.
+const memorySweepInterval = time.Minute
+
+// MemoryRefreshTokenStore keeps refresh tokens in memory until they expire.
+type MemoryRefreshTokenStore struct {
+	mu        sync.Mutex
+	records   map[string]*RefreshTokenRecord
+	families  map[string][]string
+	nextSweep time.Time
+}
+
+// NewMemoryRefreshTokenStore creates an empty MemoryRefreshTokenStore.
+func NewMemoryRefreshTokenStore() *MemoryRefreshTokenStore {
+	return &MemoryRefreshTokenStore{
+		records:  make(map[string]*RefreshTokenRecord),
+		families: make(map[string][]string),
+	}
+}
+
+// Create stores a new refresh token.
+func (s *MemoryRefreshTokenStore) Create(_ context.Context, record RefreshTokenRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweep(time.Now())
+
+	s.records[record.ID] = &record
+	s.families[record.FamilyID] = append(s.families[record.FamilyID], record.ID)
+	return nil
+}
+
+// Consume marks the token as used and returns the record as it was before.
+func (s *MemoryRefreshTokenStore) Consume(_ context.Context, id string) (*RefreshTokenRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, ok := s.records[id]
+	if !ok {
+		return nil, ErrRefreshTokenInvalid
+	}
+
+	before := *record
+	record.Used = true
+	return &before, nil
+}
+
+// RevokeFamily revokes every token descending from the same login.
+func (s *MemoryRefreshTokenStore) RevokeFamily(_ context.Context, familyID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, id := range s.families[familyID] {
+		if record, ok := s.records[id]; ok {
+			record.Revoked = true
+		}
+	}
+	return nil
+}
+
+// sweep drops expired tokens at most once per memorySweepInterval.
+// Used tokens are kept until they expire so that their reuse can still be detected.
+func (s *MemoryRefreshTokenStore) sweep(now time.Time) {
+	if now.Before(s.nextSweep) {
+		return
+	}
+	s.nextSweep = now.Add(memorySweepInterval)
+
+	for id, record := range s.records {
+		if now.After(record.ExpiresAt) {
+			delete(s.records, id)
+		}
+	}
+
+	for familyID, ids := range s.families {
+		alive := ids[:0]
+		for _, id := range ids {
+			if _, ok := s.records[id]; ok {
+				alive = append(alive, id)
+			}
+		}
+
+		if len(alive) == 0 {
+			delete(s.families, familyID)
+			continue
+		}
+		s.families[familyID] = alive
+	}
+}
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryRefreshTokenStore(t *testing.T) {
+	t.Parallel()
+
+	store := NewMemoryRefreshTokenStore()
+
+	expiresAt := time.Now().Add(time.Hour)
+	require.NoError(t, store.Create(context.TODO(), RefreshTokenRecord{ID: "foo", FamilyID: "family", Subject: "bar", ExpiresAt: expiresAt}))
+	require.NoError(t, store.Create(context.TODO(), RefreshTokenRecord{ID: "baz", FamilyID: "family", Subject: "bar", ExpiresAt: expiresAt}))
+
+	t.Run("first consume returns the unused record", func(t *testing.T) {
+		observed, err := store.Consume(context.TODO(), "foo")
+		require.NoError(t, err)
+
+		assert.Equal(t, &RefreshTokenRecord{ID: "foo", FamilyID: "family", Subject: "bar", ExpiresAt: expiresAt}, observed)
+	})
+
+	t.Run("second consume reports the record as used", func(t *testing.T) {
+		observed, err := store.Consume(context.TODO(), "foo")
+		require.NoError(t, err)
+
+		assert.True(t, observed.Used)
+	})
+
+	t.Run("revoke family", func(t *testing.T) {
+		require.NoError(t, store.RevokeFamily(context.TODO(), "family"))
+
+		observed, err := store.Consume(context.TODO(), "baz")
+		require.NoError(t, err)
+
+		assert.True(t, observed.Revoked)
+	})
+
+	t.Run("unknown token", func(t *testing.T) {
+		_, err := store.Consume(context.TODO(), "qux")
+		assert.True(t, errors.Is(err, ErrRefreshTokenInvalid))
+	})
+}
+
+func TestMemoryRefreshTokenStore_sweep(t *testing.T) {
+	t.Parallel()
+
+	store := NewMemoryRefreshTokenStore()
+
+	require.NoError(t, store.Create(context.TODO(), RefreshTokenRecord{ID: "foo", FamilyID: "family", ExpiresAt: time.Now().Add(-time.Second)}))
+
+	store.sweep(time.Now().Add(memorySweepInterval))
+
+	_, err := store.Consume(context.TODO(), "foo")
+	assert.True(t, errors.Is(err, ErrRefreshTokenInvalid))
+	assert.Empty(t, store.families)
+}
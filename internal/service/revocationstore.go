@@ -0,0 +1,67 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RevocationStore keeps the IDs (jti) of access tokens revoked before their expiration.
+type RevocationStore interface {
+	// Revoke denies the token ID until expiresAt.
+	// Past that point the token is rejected for being expired and the entry can be forgotten.
+	Revoke(ctx context.Context, jti string, expiresAt time.Time) error
+
+	// IsRevoked reports whether the token ID is denied.
+	IsRevoked(ctx context.Context, jti string) (bool, error)
+}
+
+var _ RevocationStore = &MemoryRevocationStore{}
+
+// MemoryRevocationStore keeps revoked token IDs in memory until the tokens expire.
+type MemoryRevocationStore struct {
+	mu        sync.RWMutex
+	revoked   map[string]time.Time
+	nextSweep time.Time
+}
+
+// NewMemoryRevocationStore creates an empty MemoryRevocationStore.
+func NewMemoryRevocationStore() *MemoryRevocationStore {
+	return &MemoryRevocationStore{
+		revoked: make(map[string]time.Time),
+	}
+}
+
+// Revoke denies the token ID until expiresAt.
+func (s *MemoryRevocationStore) Revoke(_ context.Context, jti string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweep(time.Now())
+
+	s.revoked[jti] = expiresAt
+	return nil
+}
+
+// IsRevoked reports whether the token ID is denied.
+func (s *MemoryRevocationStore) IsRevoked(_ context.Context, jti string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	expiresAt, ok := s.revoked[jti]
+	return ok && time.Now().Before(expiresAt), nil
+}
+
+// sweep drops the entries of expired tokens at most once per memorySweepInterval.
+func (s *MemoryRevocationStore) sweep(now time.Time) {
+	if now.Before(s.nextSweep) {
+		return
+	}
+	s.nextSweep = now.Add(memorySweepInterval)
+
+	for jti, expiresAt := range s.revoked {
+		if now.After(expiresAt) {
+			delete(s.revoked, jti)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+var _ RevocationStore = &FileRevocationStore{}
+
+// FileRevocationStore keeps revoked token IDs in memory and persists them to a JSON file,
+// so that revocations survive restarts. The file maps each token ID to its expiration in Unix seconds.
+type FileRevocationStore struct {
+	path string
+
+	mu      sync.RWMutex
+	revoked map[string]int64
+}
+
+// NewFileRevocationStore creates a FileRevocationStore, loading the revocations already in path if it exists.
+func NewFileRevocationStore(path string) (*FileRevocationStore, error) {
+	s := FileRevocationStore{
+		path:    path,
+		revoked: make(map[string]int64),
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("could not read revocations file: %w", err)
+	}
+
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &s.revoked); err != nil {
+			return nil, fmt.Errorf("could not decode revocations file: %w", err)
+		}
+	}
+
+	s.sweep(time.Now())
+	return &s, nil
+}
+
+// Revoke denies the token ID until expiresAt and rewrites the file.
+func (s *FileRevocationStore) Revoke(_ context.Context, jti string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweep(time.Now())
+	s.revoked[jti] = expiresAt.Unix()
+
+	if err := s.persist(); err != nil {
+		return fmt.Errorf("could not persist revocation: %w", err)
+	}
+	return nil
+}
+
+// IsRevoked reports whether the token ID is denied.
+func (s *FileRevocationStore) IsRevoked(_ context.Context, jti string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	expiresAt, ok := s.revoked[jti]
+	return ok && time.Now().Unix() < expiresAt, nil
+}
+
+// sweep drops the entries of expired tokens. Cheap enough to run on every write,
+// since the file has to be rewritten anyway.
+func (s *FileRevocationStore) sweep(now time.Time) {
+	for jti, expiresAt := range s.revoked {
+		if now.Unix() >= expiresAt {
+			delete(s.revoked, jti)
+		}
+	}
+}
+
+// persist atomically replaces the file with the current revocations.
+func (s *FileRevocationStore) persist() error {
+	data, err := json.Marshal(s.revoked)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
@@ -0,0 +1,48 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileRevocationStore(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "revocations.json")
+
+	store, err := NewFileRevocationStore(path)
+	require.NoError(t, err)
+
+	require.NoError(t, store.Revoke(context.TODO(), "foo", time.Now().Add(time.Hour)))
+	require.NoError(t, store.Revoke(context.TODO(), "bar", time.Now().Add(-time.Second)))
+
+	// A new store reading the same file sees the revocations that haven't expired.
+	reloaded, err := NewFileRevocationStore(path)
+	require.NoError(t, err)
+
+	revoked, err := reloaded.IsRevoked(context.TODO(), "foo")
+	require.NoError(t, err)
+	assert.True(t, revoked)
+
+	revoked, err = reloaded.IsRevoked(context.TODO(), "bar")
+	require.NoError(t, err)
+	assert.False(t, revoked)
+
+	assert.Len(t, reloaded.revoked, 1)
+}
+
+func TestFileRevocationStore_invalidFile(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "revocations.json")
+	require.NoError(t, os.WriteFile(path, []byte("not json"), 0o600))
+
+	_, err := NewFileRevocationStore(path)
+	assert.Error(t, err)
+}
@@ -0,0 +1,35 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryRevocationStore(t *testing.T) {
+	t.Parallel()
+
+	store := NewMemoryRevocationStore()
+
+	require.NoError(t, store.Revoke(context.TODO(), "foo", time.Now().Add(time.Hour)))
+	require.NoError(t, store.Revoke(context.TODO(), "bar", time.Now().Add(-time.Second)))
+
+	revoked, err := store.IsRevoked(context.TODO(), "foo")
+	require.NoError(t, err)
+	assert.True(t, revoked)
+
+	// Expired tokens are rejected anyway, so their entries no longer count.
+	revoked, err = store.IsRevoked(context.TODO(), "bar")
+	require.NoError(t, err)
+	assert.False(t, revoked)
+
+	revoked, err = store.IsRevoked(context.TODO(), "baz")
+	require.NoError(t, err)
+	assert.False(t, revoked)
+
+	store.sweep(time.Now().Add(memorySweepInterval))
+	assert.Len(t, store.revoked, 1)
+}
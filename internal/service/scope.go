@@ -0,0 +1,76 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ScopeSum allows calling Sum. It is granted to users and clients without scopes of their own
+// unless WithDefaultScopes says otherwise.
+const ScopeSum string = "sum"
+
+// ParseScopes splits a space-delimited scope string (RFC 6749 section 3.3).
+// It returns nil when there are no scopes.
+func ParseScopes(s string) []string {
+	scopes := strings.Fields(s)
+	if len(scopes) == 0 {
+		return nil
+	}
+	return scopes
+}
+
+// FormatScopes joins scopes into a space-delimited scope string.
+func FormatScopes(scopes []string) string {
+	return strings.Join(scopes, " ")
+}
+
+// HasScopes reports whether the token grants every one of scopes.
+func (c *Claims) HasScopes(scopes ...string) bool {
+	granted := ParseScopes(c.Scope)
+	for _, scope := range scopes {
+		if !containsScope(granted, scope) {
+			return false
+		}
+	}
+	return true
+}
+
+// narrowScopes returns the requested scopes, or all of allowed when none are requested.
+// Requesting a scope that isn't allowed fails with ErrScopeInvalid.
+func narrowScopes(allowed, requested []string) ([]string, error) {
+	if len(requested) == 0 {
+		return allowed, nil
+	}
+
+	var scopes []string
+	for _, scope := range requested {
+		if !containsScope(allowed, scope) {
+			return nil, fmt.Errorf("could not grant scope %q: %w", scope, ErrScopeInvalid)
+		}
+
+		if !containsScope(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes, nil
+}
+
+// intersectScopes returns the scopes of a that are also in b, in the order of a.
+func intersectScopes(a, b []string) []string {
+	var scopes []string
+	for _, scope := range a {
+		if containsScope(b, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes
+}
+
+func containsScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseScopes(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, []string{"sum", "read"}, ParseScopes(" sum  read "))
+	assert.Nil(t, ParseScopes(""))
+	assert.Equal(t, "sum read", FormatScopes([]string{"sum", "read"}))
+}
+
+func TestClaims_HasScopes(t *testing.T) {
+	t.Parallel()
+
+	claims := Claims{Scope: "sum read"}
+
+	assert.True(t, claims.HasScopes())
+	assert.True(t, claims.HasScopes("sum"))
+	assert.True(t, claims.HasScopes("read", "sum"))
+	assert.False(t, claims.HasScopes("sum", "admin"))
+	assert.False(t, (&Claims{}).HasScopes("sum"))
+}
+
+func TestNarrowScopes(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name           string
+		givenAllowed   []string
+		givenRequested []string
+		expected       []string
+		expectedError  error
+	}{
+		{
+			name:         "nothing requested",
+			givenAllowed: []string{"sum", "read"},
+			expected:     []string{"sum", "read"},
+		},
+		{
+			name:           "subset requested",
+			givenAllowed:   []string{"sum", "read"},
+			givenRequested: []string{"read", "read"},
+			expected:       []string{"read"},
+		},
+		{
+			name:           "unknown scope requested",
+			givenAllowed:   []string{"sum"},
+			givenRequested: []string{"sum", "admin"},
+			expectedError:  ErrScopeInvalid,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			observed, err := narrowScopes(tc.givenAllowed, tc.givenRequested)
+
+			assert.Equal(t, tc.expected, observed)
+			assert.True(t, errors.Is(err, tc.expectedError))
+		})
+	}
+}
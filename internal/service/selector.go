@@ -0,0 +1,324 @@
+package service
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Selector selects values of a document by JSON Pointer (RFC 6901), such as /items/0/price,
+// or by a subset of JSONPath: the root $, members .price or ['unit price'], indexes [0],
+// wildcards .* or [*] and descendants ..price, as in $.items[*].price or $..id.
+// Selecting an array or an object selects everything it holds.
+type Selector struct {
+	raw   string
+	steps []selectorStep
+}
+
+type selectorStepKind int
+
+const (
+	// stepKey matches the member of an object with the given key.
+	stepKey selectorStepKind = iota
+
+	// stepIndex matches the element of an array at the given index.
+	stepIndex
+
+	// stepToken matches a JSON Pointer reference token, which stands for a key or, in arrays, an index.
+	stepToken
+
+	// stepWildcard matches any member or element.
+	stepWildcard
+
+	// stepDescendants matches any number of steps, none included.
+	stepDescendants
+)
+
+type selectorStep struct {
+	kind  selectorStepKind
+	key   string
+	index int
+}
+
+func (s selectorStep) matches(step pathStep) bool {
+	switch s.kind {
+	case stepKey:
+		return step.isKey && step.key == s.key
+	case stepIndex:
+		return !step.isKey && step.index == s.index
+	case stepToken:
+		if step.isKey {
+			return step.key == s.key
+		}
+		return strconv.Itoa(step.index) == s.key
+	case stepWildcard:
+		return true
+	default:
+		return false
+	}
+}
+
+// ParseSelector parses a JSON Pointer, or a JSONPath starting with $.
+func ParseSelector(s string) (Selector, error) {
+	var (
+		steps []selectorStep
+		err   error
+	)
+
+	switch {
+	case s == "" || strings.HasPrefix(s, "/"):
+		steps, err = parsePointer(s)
+	case strings.HasPrefix(s, "$"):
+		steps, err = parseJSONPath(s[1:])
+	default:
+		err = fmt.Errorf("neither a JSON Pointer nor a JSONPath")
+	}
+
+	if err != nil {
+		return Selector{}, fmt.Errorf("could not parse selector %q: %v: %w", s, err, ErrSelectorInvalid)
+	}
+	return Selector{raw: s, steps: steps}, nil
+}
+
+// String returns the selector as it was parsed.
+func (s Selector) String() string {
+	return s.raw
+}
+
+func parsePointer(s string) ([]selectorStep, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	var steps []selectorStep
+	for _, token := range strings.Split(s[1:], "/") {
+		if strings.Contains(strings.NewReplacer("~0", "", "~1", "").Replace(token), "~") {
+			return nil, fmt.Errorf("invalid escape in %q", token)
+		}
+
+		key := strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+		steps = append(steps, selectorStep{kind: stepToken, key: key})
+	}
+	return steps, nil
+}
+
+// parseJSONPath parses the JSONPath s, found after the root $.
+func parseJSONPath(s string) ([]selectorStep, error) {
+	var steps []selectorStep
+
+	for s != "" {
+		var (
+			step selectorStep
+			err  error
+		)
+
+		switch {
+		case strings.HasPrefix(s, ".."):
+			steps = append(steps, selectorStep{kind: stepDescendants})
+			s = s[2:]
+
+			if strings.HasPrefix(s, "[") {
+				step, s, err = parseBracket(s)
+			} else {
+				step, s, err = parseMember(s)
+			}
+
+		case strings.HasPrefix(s, "."):
+			step, s, err = parseMember(s[1:])
+
+		case strings.HasPrefix(s, "["):
+			step, s, err = parseBracket(s)
+
+		default:
+			err = fmt.Errorf("unexpected %q", s)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+// parseMember parses the member name or wildcard following a dot, and returns what follows it.
+func parseMember(s string) (selectorStep, string, error) {
+	end := strings.IndexAny(s, ".[")
+	if end < 0 {
+		end = len(s)
+	}
+
+	name, rest := s[:end], s[end:]
+	switch name {
+	case "":
+		return selectorStep{}, "", fmt.Errorf("missing member name")
+	case "*":
+		return selectorStep{kind: stepWildcard}, rest, nil
+	default:
+		return selectorStep{kind: stepKey, key: name}, rest, nil
+	}
+}
+
+// parseBracket parses the index, wildcard or quoted member name between brackets, and returns what follows them.
+func parseBracket(s string) (selectorStep, string, error) {
+	s = s[1:]
+
+	if strings.HasPrefix(s, "'") || strings.HasPrefix(s, `"`) {
+		key, rest, err := parseQuoted(s)
+		if err != nil {
+			return selectorStep{}, "", err
+		}
+
+		if !strings.HasPrefix(rest, "]") {
+			return selectorStep{}, "", fmt.Errorf("missing ] after %q", key)
+		}
+		return selectorStep{kind: stepKey, key: key}, rest[1:], nil
+	}
+
+	end := strings.IndexByte(s, ']')
+	if end < 0 {
+		return selectorStep{}, "", fmt.Errorf("missing ]")
+	}
+
+	inner, rest := s[:end], s[end+1:]
+	if inner == "*" {
+		return selectorStep{kind: stepWildcard}, rest, nil
+	}
+
+	i, err := strconv.Atoi(inner)
+	if err != nil || i < 0 || !isDigits(inner) {
+		return selectorStep{}, "", fmt.Errorf("unsupported selector [%s]", inner)
+	}
+	return selectorStep{kind: stepIndex, index: i}, rest, nil
+}
+
+// parseQuoted parses the single or double quoted string s starts with, and returns what follows it.
+// Backslashes escape the quote and themselves in either, and double quoted strings take Go escapes too.
+func parseQuoted(s string) (string, string, error) {
+	quote := s[0]
+
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case quote:
+			if quote == '"' {
+				key, err := strconv.Unquote(s[:i+1])
+				return key, s[i+1:], err
+			}
+			return strings.NewReplacer(`\'`, "'", `\\`, `\`).Replace(s[1:i]), s[i+1:], nil
+		}
+	}
+	return "", "", fmt.Errorf("unterminated string %s", s)
+}
+
+// selects reports whether the selector selects the value at path, or an array or object holding it.
+func (s Selector) selects(path []pathStep) bool {
+	n := len(s.steps)
+
+	// states[i] is set while the first i steps of the selector match the path read so far.
+	states, next := make([]bool, n+1), make([]bool, n+1)
+	states[0] = true
+	s.skipDescendants(states)
+
+	for _, step := range path {
+		if states[n] {
+			return true
+		}
+
+		alive := false
+		for i := range next {
+			next[i] = false
+		}
+
+		for i, on := range states[:n] {
+			switch {
+			case !on:
+			case s.steps[i].kind == stepDescendants:
+				next[i], alive = true, true
+			case s.steps[i].matches(step):
+				next[i+1], alive = true, true
+			}
+		}
+
+		if !alive {
+			return false
+		}
+
+		s.skipDescendants(next)
+		states, next = next, states
+	}
+	return states[n]
+}
+
+// skipDescendants lets descendant steps match no step at all.
+func (s Selector) skipDescendants(states []bool) {
+	for i, step := range s.steps {
+		if states[i] && step.kind == stepDescendants {
+			states[i+1] = true
+		}
+	}
+}
+
+// sumFilter picks the values of a document that are summed.
+type sumFilter struct {
+	// include selects the values summed. Empty includes the whole document.
+	include []Selector
+
+	// exclude selects the values left out, even when include selects them.
+	exclude []Selector
+}
+
+// newSumFilter parses the include and exclude selectors, and returns nil when there are none.
+func newSumFilter(include, exclude []string) (*sumFilter, error) {
+	if len(include) == 0 && len(exclude) == 0 {
+		return nil, nil
+	}
+
+	var (
+		f   sumFilter
+		err error
+	)
+
+	if f.include, err = parseSelectors(include); err != nil {
+		return nil, err
+	}
+
+	if f.exclude, err = parseSelectors(exclude); err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+func parseSelectors(raw []string) ([]Selector, error) {
+	selectors := make([]Selector, 0, len(raw))
+	for _, s := range raw {
+		selector, err := ParseSelector(s)
+		if err != nil {
+			return nil, err
+		}
+		selectors = append(selectors, selector)
+	}
+	return selectors, nil
+}
+
+// selects reports whether the value at path is summed. A nil sumFilter sums every value.
+func (f *sumFilter) selects(path []pathStep) bool {
+	if f == nil {
+		return true
+	}
+
+	if len(f.include) > 0 && !anySelects(f.include, path) {
+		return false
+	}
+	return !anySelects(f.exclude, path)
+}
+
+func anySelects(selectors []Selector, path []pathStep) bool {
+	for _, s := range selectors {
+		if s.selects(path) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,309 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestParseSelector(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name          string
+		givenSelector string
+		expectedSteps []selectorStep
+		expectedError error
+	}{
+		{
+			name:          "pointer to the document",
+			givenSelector: "",
+		},
+		{
+			name:          "pointer",
+			givenSelector: "/items/0/unit~1price~0",
+			expectedSteps: []selectorStep{
+				{kind: stepToken, key: "items"},
+				{kind: stepToken, key: "0"},
+				{kind: stepToken, key: "unit/price~"},
+			},
+		},
+		{
+			name:          "pointer with an invalid escape",
+			givenSelector: "/a~2",
+			expectedError: ErrSelectorInvalid,
+		},
+		{
+			name:          "JSONPath to the document",
+			givenSelector: "$",
+		},
+		{
+			name:          "JSONPath",
+			givenSelector: "$.items[*].price",
+			expectedSteps: []selectorStep{
+				{kind: stepKey, key: "items"},
+				{kind: stepWildcard},
+				{kind: stepKey, key: "price"},
+			},
+		},
+		{
+			name:          "JSONPath with brackets",
+			givenSelector: `$['unit price'][2]["it's \"here\""].*`,
+			expectedSteps: []selectorStep{
+				{kind: stepKey, key: "unit price"},
+				{kind: stepIndex, index: 2},
+				{kind: stepKey, key: `it's "here"`},
+				{kind: stepWildcard},
+			},
+		},
+		{
+			name:          "JSONPath with descendants",
+			givenSelector: "$..id..[0]",
+			expectedSteps: []selectorStep{
+				{kind: stepDescendants},
+				{kind: stepKey, key: "id"},
+				{kind: stepDescendants},
+				{kind: stepIndex},
+			},
+		},
+		{
+			name:          "JSONPath filter",
+			givenSelector: "$.items[?(@.price > 1)]",
+			expectedError: ErrSelectorInvalid,
+		},
+		{
+			name:          "JSONPath slice",
+			givenSelector: "$.items[0:2]",
+			expectedError: ErrSelectorInvalid,
+		},
+		{
+			name:          "JSONPath negative index",
+			givenSelector: "$.items[-1]",
+			expectedError: ErrSelectorInvalid,
+		},
+		{
+			name:          "JSONPath missing member",
+			givenSelector: "$.items.",
+			expectedError: ErrSelectorInvalid,
+		},
+		{
+			name:          "JSONPath unterminated string",
+			givenSelector: "$['items]",
+			expectedError: ErrSelectorInvalid,
+		},
+		{
+			name:          "JSONPath without root",
+			givenSelector: "items.price",
+			expectedError: ErrSelectorInvalid,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			observed, err := ParseSelector(tc.givenSelector)
+			if tc.expectedError != nil {
+				assert.True(t, errors.Is(err, tc.expectedError), err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedSteps, observed.steps)
+			assert.Equal(t, tc.givenSelector, observed.String())
+		})
+	}
+}
+
+func TestSelector_selects(t *testing.T) {
+	t.Parallel()
+
+	// $.items[1].price
+	path := []pathStep{keyStep("items"), indexStep(1), keyStep("price")}
+
+	testCases := []struct {
+		givenSelector string
+		expected      bool
+	}{
+		{givenSelector: "$", expected: true},
+		{givenSelector: "", expected: true},
+		{givenSelector: "$.items", expected: true},
+		{givenSelector: "$.items[*].price", expected: true},
+		{givenSelector: "$.items[1].price", expected: true},
+		{givenSelector: "$.items.*.price", expected: true},
+		{givenSelector: "/items/1/price", expected: true},
+		{givenSelector: "/items/1", expected: true},
+		{givenSelector: "$..price", expected: true},
+		{givenSelector: "$..[1]", expected: true},
+		{givenSelector: "$..items..price", expected: true},
+		{givenSelector: "$..*", expected: true},
+		{givenSelector: "$.items[0].price", expected: false},
+		{givenSelector: "$.items[*].quantity", expected: false},
+		{givenSelector: "$.items[1].price.currency", expected: false},
+		{givenSelector: "$[0]", expected: false},
+		{givenSelector: "$.price", expected: false},
+		{givenSelector: "$..quantity", expected: false},
+		{givenSelector: "/items/01/price", expected: false},
+		{givenSelector: "/items/price", expected: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.givenSelector, func(t *testing.T) {
+			selector, err := ParseSelector(tc.givenSelector)
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.expected, selector.selects(path))
+		})
+	}
+
+	t.Run("pointer tokens match keys of objects", func(t *testing.T) {
+		selector, err := ParseSelector("/0")
+		require.NoError(t, err)
+
+		assert.True(t, selector.selects([]pathStep{keyStep("0")}))
+		assert.True(t, selector.selects([]pathStep{indexStep(0)}))
+	})
+}
+
+func TestSumNumbers_filter(t *testing.T) {
+	t.Parallel()
+
+	doc := `{
+		"id": 1001,
+		"items": [
+			{"id": 7, "price": 10.5, "quantity": 2, "tags": [true]},
+			{"id": 8, "price": 4, "quantity": 1, "discount": {"price": -1.5}}
+		],
+		"total": 13
+	}`
+
+	testCases := []struct {
+		name          string
+		givenInclude  []string
+		givenExclude  []string
+		expectedSum   string
+		expectedError error
+	}{
+		{
+			name:         "include",
+			givenInclude: []string{"$.items[*].price"},
+			expectedSum:  "14.5",
+		},
+		{
+			name:         "include descendants",
+			givenInclude: []string{"$..price"},
+			expectedSum:  "13",
+		},
+		{
+			name:         "include several",
+			givenInclude: []string{"$.items[*].price", "/total"},
+			expectedSum:  "27.5",
+		},
+		{
+			name:         "exclude",
+			givenExclude: []string{"$..id", "/items/0/tags"},
+			expectedSum:  "29",
+		},
+		{
+			name:         "include and exclude",
+			givenInclude: []string{"/items"},
+			givenExclude: []string{"$..id", "$.items[*].quantity", "$..tags"},
+			expectedSum:  "13",
+		},
+		{
+			name:          "rejected values must be selected to fail",
+			givenInclude:  []string{"/items/0"},
+			expectedError: ErrUnsupportedValueType,
+		},
+		{
+			name:         "nothing selected",
+			givenInclude: []string{"$.missing"},
+			expectedSum:  "0",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			filter, err := newSumFilter(tc.givenInclude, tc.givenExclude)
+			require.NoError(t, err)
+
+			rules := sumRules{arithmetic: ArithmeticExact, booleans: LiteralsReject, filter: filter}
+
+			dec := json.NewDecoder(strings.NewReader(doc))
+			dec.UseNumber()
+
+			var data any
+			require.NoError(t, dec.Decode(&data))
+
+			observed, err := sumNumbers(data, rules)
+			assert.True(t, errors.Is(err, tc.expectedError), err)
+			assert.Equal(t, tc.expectedSum, observed)
+
+			streamed, err := sumStream(context.TODO(), strings.NewReader(doc), rules)
+			assert.True(t, errors.Is(err, tc.expectedError), err)
+			assert.Equal(t, tc.expectedSum, streamed)
+		})
+	}
+
+	t.Run("explained", func(t *testing.T) {
+		filter, err := newSumFilter(nil, []string{"$..id"})
+		require.NoError(t, err)
+
+		explanation, err := explainNumbers(map[string]any{"id": json.Number("1"), "n": json.Number("2")}, sumRules{
+			arithmetic: ArithmeticExact,
+			filter:     filter,
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, []SumValue{
+			{Pointer: "/id", Value: "1", Status: SumValueSkipped, Reason: "not selected"},
+			{Pointer: "/n", Value: "2", Status: SumValueContributed},
+		}, explanation.Values)
+	})
+}
+
+func TestNewSumFilter(t *testing.T) {
+	t.Parallel()
+
+	filter, err := newSumFilter(nil, nil)
+	require.NoError(t, err)
+	assert.Nil(t, filter)
+
+	_, err = newSumFilter([]string{"$.a"}, []string{"a"})
+	assert.True(t, errors.Is(err, ErrSelectorInvalid), err)
+}
+
+func TestDefaultService_Sum_selectors(t *testing.T) {
+	t.Parallel()
+
+	doc := `{"id": 42, "items": [{"price": 1.5}, {"price": 2}]}`
+	opts := SumOptions{Include: []string{"$.items[*].price"}, Explain: true}
+
+	for arithmetic, expected := range map[Arithmetic]string{ArithmeticExact: "3.5", ArithmeticFloat: "3.500000"} {
+		arithmetic, expected := arithmetic, expected
+
+		t.Run(string(arithmetic), func(t *testing.T) {
+			service := NewDefaultService(zap.NewNop(), []byte("foo-key"), WithArithmetic(arithmetic))
+
+			observed, err := service.SumStream(context.TODO(), strings.NewReader(doc), opts)
+			require.NoError(t, err)
+
+			assert.Equal(t, expected, observed.Explanation.Sum)
+			assert.Equal(t, 2, observed.Explanation.Count)
+		})
+	}
+
+	t.Run("invalid selector", func(t *testing.T) {
+		service := NewDefaultService(zap.NewNop(), []byte("foo-key"))
+
+		_, err := service.Sum(context.TODO(), []any{}, SumOptions{Exclude: []string{"$.items[?(@.price)]"}})
+		assert.True(t, errors.Is(err, ErrSelectorInvalid), err)
+
+		_, err = service.SumStream(context.TODO(), strings.NewReader(doc), SumOptions{Include: []string{"items"}})
+		assert.True(t, errors.Is(err, ErrSelectorInvalid), err)
+	})
+}
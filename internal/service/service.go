@@ -1,9 +1,22 @@
 package service
 
-import "context"
+import (
+	"context"
+	"io"
+	"time"
+)
 
 type Service interface {
 	GenerateToken(ctx context.Context, cred Credentials) (*Token, error)
-	VerifyToken(ctx context.Context, token string) error
-	Sum(ctx context.Context, data any) (string, error)
+	RefreshToken(ctx context.Context, refreshToken string) (*Token, error)
+	Grant(ctx context.Context, req GrantRequest) (*Token, error)
+	VerifyToken(ctx context.Context, token string) (*Claims, error)
+	AuthenticateClient(ctx context.Context, creds ClientCredentials) (*Client, error)
+	Logout(ctx context.Context, accessToken, refreshToken string) error
+	RevokeTokenID(ctx context.Context, jti string, expiresAt time.Time) error
+	Sum(ctx context.Context, data any, opts SumOptions) (*SumResult, error)
+	SumStream(ctx context.Context, r io.Reader, opts SumOptions) (*SumResult, error)
+	SumBatch(ctx context.Context, docs [][]byte, opts SumOptions) ([]SumBatchItem, error)
+	Aggregate(ctx context.Context, r io.Reader, ops []Operation, opts SumOptions) (*AggregateResult, error)
+	ProviderMetadata(ctx context.Context) (*ProviderMetadata, error)
 }
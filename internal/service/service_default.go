@@ -1,10 +1,20 @@
 package service
 
 import (
+	"bytes"
 	"context"
+	"crypto/rand"
 	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"runtime"
+	"sort"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/golang-jwt/jwt"
@@ -12,30 +22,207 @@ import (
 )
 
 const (
-	jtwClaimDuration time.Duration = time.Hour
-	jwtClaimIssuer   string        = "foo-issuer"
-	jwtClaimAudience string        = "foo-audience"
-	tokenType        string        = "Bearer"
-)
+	tokenType string = "Bearer"
 
-type Claims struct {
-	jwt.StandardClaims
-}
+	tokenIDBytes      int           = 16
+	refreshTokenBytes int           = 32
+	refreshTokenTTL   time.Duration = 24 * time.Hour
+)
 
 var _ Service = &DefaultService{}
 
 // DefaultService is the default implementation of the Service interface.
 type DefaultService struct {
-	logger *zap.Logger
-	jwtKey []byte
+	logger          *zap.Logger
+	keys            *KeySet
+	tokenOptions    TokenOptions
+	arithmetic      Arithmetic
+	stringMode      StringMode
+	booleans        LiteralPolicy
+	nulls           LiteralPolicy
+	digests         *DigestRegistry
+	digestAlgorithm string
+	digestEncoding  string
+	users           UserStore
+	clients         ClientStore
+	defaultScopes   []string
+	refreshTokens   RefreshTokenStore
+	refreshTokenTTL time.Duration
+	revocations     RevocationStore
+	lockout         LockoutOptions
+	attempts        AttemptStore
+	attemptLocks    *keyedMutex
+	sumLimits       SumLimits
+	batchWorkers    int
+
+	// batchSlots holds a slot per document being summed by SumBatch, shared by every batch,
+	// so that concurrent batches don't sum more than batchWorkers documents at a time together.
+	batchSlots chan struct{}
+}
+
+// Option configures optional behaviour of a DefaultService.
+type Option func(*DefaultService)
+
+// WithKeySet replaces the HS256 key given to NewDefaultService with the keys of ks.
+func WithKeySet(ks *KeySet) Option {
+	return func(s *DefaultService) {
+		s.keys = ks
+	}
+}
+
+// WithTokenOptions sets the issuer, audiences, lifetimes and leeway of access tokens.
+// Defaults to DefaultTokenOptions.
+func WithTokenOptions(opts TokenOptions) Option {
+	return func(s *DefaultService) {
+		s.tokenOptions = opts
+	}
+}
+
+// WithArithmetic selects how Sum adds up numbers. Defaults to ArithmeticExact.
+func WithArithmetic(arithmetic Arithmetic) Option {
+	return func(s *DefaultService) {
+		s.arithmetic = arithmetic
+	}
 }
 
-// NewDefaultService creates a new DefaultService.
-func NewDefaultService(logger *zap.Logger, jwtKey []byte) *DefaultService {
-	return &DefaultService{
+// WithStringMode selects what Sum does with strings when a request doesn't say. Defaults to StringsIgnore.
+func WithStringMode(mode StringMode) Option {
+	return func(s *DefaultService) {
+		s.stringMode = mode
+	}
+}
+
+// WithBooleanPolicy selects what Sum does with booleans when a request doesn't say. Defaults to LiteralsIgnore.
+func WithBooleanPolicy(policy LiteralPolicy) Option {
+	return func(s *DefaultService) {
+		s.booleans = policy
+	}
+}
+
+// WithNullPolicy selects what Sum does with nulls when a request doesn't say. Defaults to LiteralsIgnore.
+func WithNullPolicy(policy LiteralPolicy) Option {
+	return func(s *DefaultService) {
+		s.nulls = policy
+	}
+}
+
+// WithSumLimits bounds the documents Sum accepts. Defaults to DefaultSumLimits.
+func WithSumLimits(limits SumLimits) Option {
+	return func(s *DefaultService) {
+		s.sumLimits = limits
+	}
+}
+
+// WithBatchWorkers sets how many documents SumBatch sums at a time, across every batch being summed.
+// Defaults to runtime.GOMAXPROCS.
+func WithBatchWorkers(n int) Option {
+	return func(s *DefaultService) {
+		s.batchWorkers = n
+	}
+}
+
+// WithDigests sets the registry Sum picks digest algorithms and encodings from,
+// along with the algorithm and encoding used when a request doesn't name one.
+// Defaults to NewDigestRegistry with SHA-256 encoded as hex.
+func WithDigests(registry *DigestRegistry, algorithm, encoding string) Option {
+	return func(s *DefaultService) {
+		s.digests = registry
+		s.digestAlgorithm = algorithm
+		s.digestEncoding = encoding
+	}
+}
+
+// WithUserStore sets the store GenerateToken checks credentials against.
+// Defaults to an empty store, which rejects every login.
+func WithUserStore(users UserStore) Option {
+	return func(s *DefaultService) {
+		s.users = users
+	}
+}
+
+// WithClientStore sets the store Grant authenticates OAuth clients against.
+// Defaults to an empty store, which rejects every client.
+func WithClientStore(clients ClientStore) Option {
+	return func(s *DefaultService) {
+		s.clients = clients
+	}
+}
+
+// WithDefaultScopes sets the scopes granted to users and clients that have none of their own.
+// Defaults to ScopeSum.
+func WithDefaultScopes(scopes ...string) Option {
+	return func(s *DefaultService) {
+		s.defaultScopes = scopes
+	}
+}
+
+// WithRefreshTokens sets where refresh tokens are stored and how long they live.
+// Defaults to a MemoryRefreshTokenStore and 24 hours.
+func WithRefreshTokens(store RefreshTokenStore, ttl time.Duration) Option {
+	return func(s *DefaultService) {
+		s.refreshTokens = store
+		s.refreshTokenTTL = ttl
+	}
+}
+
+// WithRevocationStore sets where revoked token IDs are kept. Defaults to a MemoryRevocationStore.
+func WithRevocationStore(store RevocationStore) Option {
+	return func(s *DefaultService) {
+		s.revocations = store
+	}
+}
+
+// WithLockout sets how failed logins lock usernames and IP addresses out, and where failed attempts are counted.
+// Defaults to DefaultLockoutOptions and a MemoryAttemptStore.
+func WithLockout(opts LockoutOptions, store AttemptStore) Option {
+	return func(s *DefaultService) {
+		s.lockout = opts
+		s.attempts = store
+	}
+}
+
+// NewDefaultService creates a new DefaultService signing tokens with HS256 and jwtKey,
+// unless WithKeySet says otherwise.
+func NewDefaultService(logger *zap.Logger, jwtKey []byte, opts ...Option) *DefaultService {
+	hmacKey := NewHMACKey(HMACKeyID, jwtKey)
+
+	s := DefaultService{
 		logger: logger,
-		jwtKey: jwtKey,
+		keys: &KeySet{
+			signing: hmacKey,
+			keys:    map[string]*SigningKey{hmacKey.ID: hmacKey},
+			legacy:  hmacKey,
+		},
+		tokenOptions:    DefaultTokenOptions(),
+		arithmetic:      ArithmeticExact,
+		stringMode:      StringsIgnore,
+		booleans:        LiteralsIgnore,
+		nulls:           LiteralsIgnore,
+		digests:         NewDigestRegistry(),
+		digestAlgorithm: DigestSHA256,
+		digestEncoding:  EncodingHex,
+		users:           &MemoryUserStore{users: map[string]User{}},
+		clients:         &MemoryClientStore{clients: map[string]Client{}},
+		defaultScopes:   []string{ScopeSum},
+		refreshTokens:   NewMemoryRefreshTokenStore(),
+		refreshTokenTTL: refreshTokenTTL,
+		revocations:     NewMemoryRevocationStore(),
+		lockout:         DefaultLockoutOptions(),
+		attempts:        NewMemoryAttemptStore(),
+		attemptLocks:    newKeyedMutex(),
+		sumLimits:       DefaultSumLimits(),
+		batchWorkers:    runtime.GOMAXPROCS(0),
+	}
+
+	for _, opt := range opts {
+		opt(&s)
+	}
+
+	if s.batchWorkers < 1 {
+		s.batchWorkers = 1
 	}
+	s.batchSlots = make(chan struct{}, s.batchWorkers)
+	return &s
 }
 
 // GenerateToken generates a JWT token for the provided credentials.
@@ -45,151 +232,1032 @@ func (s *DefaultService) GenerateToken(ctx context.Context, creds Credentials) (
 		return nil, fmt.Errorf("could not invalid credentials: %w", err)
 	}
 
+	user, err := s.authenticate(ctx, creds)
+	if err != nil {
+		return nil, err
+	}
+
+	authz := authorization{
+		subject: user.Username,
+		scopes:  s.scopesOrDefault(user.Scopes),
+		claims:  user.Claims,
+	}
+	return s.issueToken(ctx, authz, "", nil)
+}
+
+// Grant issues a token for a request to the OAuth 2.0 token endpoint, after authenticating the client.
+// The client_credentials grant issues a token for the client itself, without refresh token.
+// Requested scopes narrow the scopes the user or client is granted, and fail with ErrScopeInvalid
+// when they aren't granted at all.
+func (s *DefaultService) Grant(ctx context.Context, req GrantRequest) (*Token, error) {
+	client, err := s.AuthenticateClient(ctx, req.Client)
+	if err != nil {
+		return nil, err
+	}
+
+	switch req.GrantType {
+	case GrantPassword:
+		if err := req.Credentials.validate(); err != nil {
+			return nil, fmt.Errorf("could not validate credentials: %w", err)
+		}
+
+		user, err := s.authenticate(ctx, req.Credentials)
+		if err != nil {
+			return nil, err
+		}
+
+		allowed := s.scopesOrDefault(user.Scopes)
+		if len(client.Scopes) > 0 {
+			allowed = intersectScopes(allowed, client.Scopes)
+		}
+
+		scopes, err := narrowScopes(allowed, req.Scopes)
+		if err != nil {
+			return nil, err
+		}
+
+		authz := authorization{
+			subject:  user.Username,
+			clientID: client.ID,
+			scopes:   scopes,
+			claims:   user.Claims,
+		}
+		return s.issueToken(ctx, authz, "", nil)
+
+	case GrantClientCredentials:
+		scopes, err := narrowScopes(s.scopesOrDefault(client.Scopes), req.Scopes)
+		if err != nil {
+			return nil, err
+		}
+
+		authz := authorization{
+			subject:  client.ID,
+			clientID: client.ID,
+			scopes:   scopes,
+		}
+
+		signedToken, err := s.signAccessToken(authz)
+		if err != nil {
+			return nil, err
+		}
+
+		return &Token{
+			AccessToken: signedToken,
+			TokenType:   tokenType,
+			ExpiresIn:   int64(s.tokenOptions.ttl(authz.clientID).Seconds()),
+			Scope:       FormatScopes(scopes),
+		}, nil
+
+	case GrantRefreshToken:
+		if req.RefreshToken == "" {
+			return nil, ErrRefreshTokenInvalid
+		}
+		return s.refresh(ctx, req.RefreshToken, client.ID, req.Scopes)
+
+	default:
+		return nil, fmt.Errorf("could not grant %q: %w", req.GrantType, ErrUnsupportedGrantType)
+	}
+}
+
+// RefreshToken exchanges a refresh token for a new access token and a new refresh token.
+// Each refresh token can be used once: presenting it again revokes every token of its family,
+// since either the client or an attacker is replaying a stolen token.
+// Tokens issued to OAuth clients can only be refreshed through Grant.
+func (s *DefaultService) RefreshToken(ctx context.Context, refreshToken string) (*Token, error) {
+	return s.refresh(ctx, refreshToken, "", nil)
+}
+
+// refresh rotates a refresh token issued to clientID.
+// Requested scopes narrow the new access token, while the new refresh token keeps every scope of the old one.
+func (s *DefaultService) refresh(ctx context.Context, refreshToken, clientID string, requested []string) (*Token, error) {
+	record, err := s.refreshTokens.Consume(ctx, hashRefreshToken(refreshToken))
+	if err != nil {
+		return nil, fmt.Errorf("could not consume refresh token: %w", err)
+	}
+
+	if record.Revoked {
+		return nil, ErrRefreshTokenInvalid
+	}
+
+	// The token is burnt: should the client it belongs to present it later,
+	// the reuse revokes its family, which is what a leaked token deserves.
+	if record.ClientID != clientID {
+		return nil, fmt.Errorf("could not refresh token issued to another client: %w", ErrRefreshTokenInvalid)
+	}
+
+	if record.Used {
+		s.logger.Warn("refresh token reused, revoking its family",
+			zap.String("subject", record.Subject),
+			zap.String("family_id", record.FamilyID),
+		)
+
+		if err := s.refreshTokens.RevokeFamily(ctx, record.FamilyID); err != nil {
+			return nil, fmt.Errorf("could not revoke refresh token family: %w", err)
+		}
+		return nil, ErrRefreshTokenReused
+	}
+
+	if time.Now().After(record.ExpiresAt) {
+		return nil, ErrRefreshTokenExpired
+	}
+
+	// The custom claims are read again so that the new access token reflects the user as it is now,
+	// and users removed since the login can't refresh their tokens.
+	user, err := s.users.User(ctx, record.Subject)
+	if errors.Is(err, ErrUserNotFound) {
+		return nil, fmt.Errorf("could not find user of refresh token: %w", ErrRefreshTokenInvalid)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("could not look up user: %w", err)
+	}
+
+	authz := authorization{
+		subject:  record.Subject,
+		clientID: record.ClientID,
+		scopes:   record.Scopes,
+		claims:   user.Claims,
+	}
+	return s.issueToken(ctx, authz, record.FamilyID, requested)
+}
+
+// authorization is what a token is issued for.
+type authorization struct {
+	subject  string
+	clientID string
+	scopes   []string
+
+	// claims are the custom claims of the user the token is issued for.
+	claims map[string]any
+}
+
+// scopesOrDefault returns scopes, or the default scopes if there are none.
+func (s *DefaultService) scopesOrDefault(scopes []string) []string {
+	if len(scopes) == 0 {
+		return s.defaultScopes
+	}
+	return scopes
+}
+
+// issueToken signs an access token for authz and pairs it with a new refresh token of familyID.
+// An empty familyID starts a new family.
+// Requested scopes narrow the access token only: the refresh token carries all the scopes of authz.
+func (s *DefaultService) issueToken(ctx context.Context, authz authorization, familyID string, requested []string) (*Token, error) {
+	accessScopes, err := narrowScopes(authz.scopes, requested)
+	if err != nil {
+		return nil, err
+	}
+
+	access := authz
+	access.scopes = accessScopes
+
+	signedToken, err := s.signAccessToken(access)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := randomString(refreshTokenBytes)
+	if err != nil {
+		return nil, fmt.Errorf("could not generate refresh token: %w", err)
+	}
+
+	if familyID == "" {
+		if familyID, err = randomString(refreshTokenBytes); err != nil {
+			return nil, fmt.Errorf("could not generate refresh token family: %w", err)
+		}
+	}
+
+	err = s.refreshTokens.Create(ctx, RefreshTokenRecord{
+		ID:        hashRefreshToken(refreshToken),
+		FamilyID:  familyID,
+		Subject:   authz.subject,
+		ClientID:  authz.clientID,
+		Scopes:    authz.scopes,
+		ExpiresAt: time.Now().Add(s.refreshTokenTTL),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not store refresh token: %w", err)
+	}
+
+	return &Token{
+		AccessToken:  signedToken,
+		TokenType:    tokenType,
+		ExpiresIn:    int64(s.tokenOptions.ttl(authz.clientID).Seconds()),
+		RefreshToken: refreshToken,
+		Scope:        FormatScopes(accessScopes),
+	}, nil
+}
+
+// signAccessToken signs an access token for authz.
+func (s *DefaultService) signAccessToken(authz authorization) (string, error) {
+	jti, err := randomString(tokenIDBytes)
+	if err != nil {
+		return "", fmt.Errorf("could not generate token ID: %w", err)
+	}
+
+	now := time.Now()
+
 	// Create claims with username as subject
 	claims := Claims{
 		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Add(jtwClaimDuration).Unix(),
-			Issuer:    jwtClaimIssuer,
-			Audience:  jwtClaimAudience,
-			Subject:   creds.Username,
-			Id:        strconv.FormatInt(time.Now().Unix(), 10),
+			ExpiresAt: now.Add(s.tokenOptions.ttl(authz.clientID)).Unix(),
+			IssuedAt:  now.Unix(),
+			NotBefore: now.Unix(),
+			Issuer:    s.tokenOptions.Issuer,
+			Audience:  s.tokenOptions.Audience,
+			Subject:   authz.subject,
+			Id:        jti,
 		},
+		ClientID: authz.clientID,
+		Scope:    FormatScopes(authz.scopes),
+		Custom:   authz.claims,
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	signedToken, err := s.keys.sign(claims)
+	if err != nil {
+		return "", fmt.Errorf("could not sign token: %w", err)
+	}
+	return signedToken, nil
+}
 
-	signedToken, err := token.SignedString(s.jwtKey)
+// AuthenticateClient checks the client credentials against the client store.
+func (s *DefaultService) AuthenticateClient(ctx context.Context, creds ClientCredentials) (*Client, error) {
+	if creds.ID == "" {
+		return nil, fmt.Errorf("could not authenticate client without ID: %w", ErrClientCredentialsMismatch)
+	}
+
+	client, err := s.clients.Client(ctx, creds.ID)
 	if err != nil {
-		return nil, fmt.Errorf("could not sign token: %w", err)
+		if errors.Is(err, ErrClientNotFound) {
+			burnPasswordCheck(creds.Secret)
+			return nil, fmt.Errorf("could not find client: %w", ErrClientCredentialsMismatch)
+		}
+		return nil, fmt.Errorf("could not look up client: %w", err)
 	}
-	return &Token{
-		AccessToken: signedToken,
-		TokenType:   tokenType,
-		ExpiresIn:   int64(jtwClaimDuration.Seconds()),
-	}, nil
+
+	ok, err := verifyPassword(client.SecretHash, creds.Secret)
+	if err != nil {
+		return nil, fmt.Errorf("could not verify client secret: %w", err)
+	}
+
+	if !ok {
+		return nil, fmt.Errorf("could not match client secret: %w", ErrClientCredentialsMismatch)
+	}
+	return client, nil
+}
+
+// authenticate checks the credentials against the user store.
+// Usernames and IP addresses with too many failed attempts are locked out with a LockoutError
+// before the password is even checked.
+func (s *DefaultService) authenticate(ctx context.Context, creds Credentials) (*User, error) {
+	now := time.Now()
+	keys := s.lockout.attemptKeys(creds)
+
+	// The keys are locked until the attempt is recorded, otherwise concurrent guesses would all pass the lockout check
+	// before any of their failures is counted. attemptKeys always orders them the same way, so this can't deadlock.
+	for _, k := range keys {
+		unlock := s.attemptLocks.lock(k.key)
+		defer unlock()
+	}
+
+	for _, k := range keys {
+		attempts, err := s.attempts.Attempts(ctx, k.key)
+		if err != nil {
+			return nil, fmt.Errorf("could not look up failed attempts: %w", err)
+		}
+
+		lockedUntil := attempts.LastFailure.Add(s.lockout.lockout(attempts.Failures, k.threshold))
+		if now.Before(lockedUntil) {
+			s.logger.Warn("login attempt while locked out",
+				zap.String("audit", "lockout_rejected"),
+				zap.String("key", k.key),
+				zap.String("username", creds.Username),
+				zap.String("remote_ip", creds.RemoteIP),
+				zap.Time("locked_until", lockedUntil),
+			)
+			return nil, &LockoutError{RetryAfter: lockedUntil.Sub(now)}
+		}
+	}
+
+	user, err := s.checkPassword(ctx, creds)
+	if errors.Is(err, ErrCredentialsMismatch) {
+		if err := s.recordFailure(ctx, keys, creds, now); err != nil {
+			return nil, err
+		}
+		return nil, err
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Only the username is reset: a valid login from an address doesn't vouch for the other attempts made from it.
+	if s.lockout.UserThreshold > 0 {
+		if err := s.attempts.Reset(ctx, userAttemptKey(creds.Username)); err != nil {
+			return nil, fmt.Errorf("could not reset failed attempts: %w", err)
+		}
+	}
+	return user, nil
+}
+
+// recordFailure counts a failed attempt under keys, logging the keys it locks out.
+func (s *DefaultService) recordFailure(ctx context.Context, keys []attemptKey, creds Credentials, now time.Time) error {
+	for _, k := range keys {
+		attempts, err := s.attempts.RecordFailure(ctx, k.key, now, s.lockout.window())
+		if err != nil {
+			return fmt.Errorf("could not record failed attempt: %w", err)
+		}
+
+		if d := s.lockout.lockout(attempts.Failures, k.threshold); d > 0 {
+			s.logger.Warn("locked out after failed login attempts",
+				zap.String("audit", "lockout"),
+				zap.String("key", k.key),
+				zap.String("username", creds.Username),
+				zap.String("remote_ip", creds.RemoteIP),
+				zap.Int("failures", attempts.Failures),
+				zap.Duration("lockout", d),
+			)
+		}
+	}
+	return nil
+}
+
+// checkPassword looks the user up and checks its password.
+func (s *DefaultService) checkPassword(ctx context.Context, creds Credentials) (*User, error) {
+	user, err := s.users.User(ctx, creds.Username)
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			burnPasswordCheck(creds.Password)
+			return nil, fmt.Errorf("could not find user: %w", ErrCredentialsMismatch)
+		}
+		return nil, fmt.Errorf("could not look up user: %w", err)
+	}
+
+	ok, err := verifyPassword(user.PasswordHash, creds.Password)
+	if err != nil {
+		return nil, fmt.Errorf("could not verify password: %w", err)
+	}
+
+	if !ok {
+		return nil, fmt.Errorf("could not match password: %w", ErrCredentialsMismatch)
+	}
+	return user, nil
+}
+
+// VerifyToken verifies the provided JWT token and returns its claims.
+func (s *DefaultService) VerifyToken(ctx context.Context, token string) (*Claims, error) {
+	return s.verifyToken(ctx, token)
+}
+
+// Logout revokes the access token and, when given, the family of the refresh token.
+func (s *DefaultService) Logout(ctx context.Context, accessToken, refreshToken string) error {
+	claims, err := s.verifyToken(ctx, accessToken)
+	if err != nil {
+		return fmt.Errorf("could not verify token: %w", err)
+	}
+
+	if err := s.revoke(ctx, claims.Id, time.Unix(claims.ExpiresAt, 0)); err != nil {
+		return err
+	}
+
+	if refreshToken == "" {
+		return nil
+	}
+
+	record, err := s.refreshTokens.Consume(ctx, hashRefreshToken(refreshToken))
+	if err != nil {
+		return fmt.Errorf("could not consume refresh token: %w", err)
+	}
+
+	// Don't let a user log somebody else out with a refresh token they got hold of.
+	if record.Subject != claims.Subject {
+		return ErrRefreshTokenInvalid
+	}
+
+	if err := s.refreshTokens.RevokeFamily(ctx, record.FamilyID); err != nil {
+		return fmt.Errorf("could not revoke refresh token family: %w", err)
+	}
+	return nil
+}
+
+// RevokeTokenID revokes the access token with the given ID.
+// A zero expiresAt keeps the token ID denied for as long as any token issued now could live.
+func (s *DefaultService) RevokeTokenID(ctx context.Context, jti string, expiresAt time.Time) error {
+	if jti == "" {
+		return ErrTokenInvalid
+	}
+
+	if expiresAt.IsZero() {
+		expiresAt = time.Now().Add(s.tokenOptions.maxTTL())
+	}
+	return s.revoke(ctx, jti, expiresAt)
 }
 
-// VerifyToken verifies the provided JWT token.
-func (s *DefaultService) VerifyToken(ctx context.Context, token string) error {
+// revoke denies the token ID until the token expires, leeway included, since verifyToken accepts it until then.
+func (s *DefaultService) revoke(ctx context.Context, jti string, expiresAt time.Time) error {
+	expiresAt = expiresAt.Add(s.tokenOptions.Leeway)
+
+	if err := s.revocations.Revoke(ctx, jti, expiresAt); err != nil {
+		return fmt.Errorf("could not revoke token: %w", err)
+	}
+
+	s.logger.Info("token revoked", zap.String("jti", jti), zap.Time("expires_at", expiresAt))
+	return nil
+}
+
+// verifyToken verifies the provided JWT token and returns its claims.
+func (s *DefaultService) verifyToken(ctx context.Context, token string) (*Claims, error) {
+	// The time claims are checked below, with leeway, rather than by jwt.
+	parser := jwt.Parser{SkipClaimsValidation: true}
+
 	claims := &Claims{}
-	tkn, err := jwt.ParseWithClaims(token, claims, func(token *jwt.Token) (any, error) {
-		return s.jwtKey, nil
-	})
+	tkn, err := parser.ParseWithClaims(token, claims, s.keys.keyFunc)
 	if err != nil {
-		return fmt.Errorf("could not parse token: %w", err)
+		return nil, fmt.Errorf("could not parse token: %w", tokenError(err))
 	}
 
 	if !tkn.Valid {
-		return ErrTokenInvalid
+		return nil, ErrTokenInvalid
 	}
 
-	if !claims.VerifyExpiresAt(time.Now().Unix(), true) {
-		return ErrTokenInvalidExpiration
+	now, leeway := time.Now(), s.tokenOptions.Leeway
+
+	if !claims.VerifyExpiresAt(now.Add(-leeway).Unix(), true) {
+		return nil, ErrTokenInvalidExpiration
 	}
 
-	if !claims.VerifyIssuer(jwtClaimIssuer, true) {
-		return ErrTokenInvalidIssuer
+	if !claims.VerifyNotBefore(now.Add(leeway).Unix(), false) {
+		return nil, ErrTokenNotYetValid
 	}
 
-	if !claims.VerifyAudience(jwtClaimAudience, true) {
-		return ErrTokenInvalidAudience
+	if !claims.VerifyIssuedAt(now.Add(leeway).Unix(), false) {
+		return nil, ErrTokenInvalidIssuedAt
 	}
-	return nil
+
+	if !claims.VerifyIssuer(s.tokenOptions.Issuer, true) {
+		return nil, ErrTokenInvalidIssuer
+	}
+
+	if !s.tokenOptions.acceptsAudience(claims.Audience) {
+		return nil, ErrTokenInvalidAudience
+	}
+
+	// Tokens without an ID predate revocation and can't be revoked.
+	if claims.Id != "" {
+		revoked, err := s.revocations.IsRevoked(ctx, claims.Id)
+		if err != nil {
+			return nil, fmt.Errorf("could not check token revocation: %w", err)
+		}
+
+		if revoked {
+			return nil, ErrTokenRevoked
+		}
+	}
+	return claims, nil
 }
 
-// Sum sums the provided data.
-func (s *DefaultService) Sum(ctx context.Context, data any) (string, error) {
-	result, err := sumNumbers(data)
+// ProviderMetadata returns the issuer and the public keys relying parties need to verify tokens on their own.
+func (s *DefaultService) ProviderMetadata(_ context.Context) (*ProviderMetadata, error) {
+	return &ProviderMetadata{
+		Issuer:            s.tokenOptions.Issuer,
+		SigningAlgorithms: s.keys.algorithms(),
+		Keys:              s.keys.publicKeys(),
+	}, nil
+}
+
+// tokenError turns the errors of jwt, which don't support errors.Is, into service errors.
+func tokenError(err error) error {
+	var vErr *jwt.ValidationError
+	if !errors.As(err, &vErr) {
+		return fmt.Errorf("%v: %w", err, ErrTokenInvalid)
+	}
+
+	switch {
+	case errors.Is(vErr.Inner, ErrTokenUnknownKey), errors.Is(vErr.Inner, ErrTokenInvalidAlgorithm):
+		return vErr.Inner
+	case vErr.Errors&jwt.ValidationErrorExpired != 0:
+		return fmt.Errorf("%v: %w", err, ErrTokenInvalidExpiration)
+	default:
+		return fmt.Errorf("%v: %w", err, ErrTokenInvalid)
+	}
+}
+
+// Sum sums the provided data and digests the result.
+func (s *DefaultService) Sum(ctx context.Context, data any, opts SumOptions) (*SumResult, error) {
+	rules, err := s.sumRules(opts)
 	if err != nil {
-		return "", fmt.Errorf("could not sum numbers: %w", err)
+		return nil, err
 	}
 
-	// Assuming that we don't log debug level in production.
-	s.logger.Debug("generating hash for", zap.Float64("result", result))
+	if opts.Explain {
+		return s.explainSum(data, rules, opts)
+	}
 
-	hash := sha256.Sum256([]byte(fmt.Sprintf("%f", result)))
-	return fmt.Sprintf("%x", hash), nil
+	result, err := sumNumbers(data, rules)
+	if err != nil {
+		return nil, fmt.Errorf("could not sum numbers: %w", err)
+	}
+	return s.digestSum(result, opts)
 }
 
-// sumNumbers sums the provided data.
-// We could possible cover more cases but I think this is enough for the purpose of this exercise.
-// It's also unliked that I wouldn't have clear requirements for this work.
-func sumNumbers(data any) (float64, error) {
-	switch val := data.(type) {
+// SumStream sums the JSON document read from r and digests the result, like Sum does with the decoded document.
+// With the exact arithmetic the document is summed as it is read, in memory that grows with the keys of its open objects
+// but not its size, and reading stops as soon as ctx is done. The float arithmetic needs the whole document,
+// since its result depends on the order the members of objects are summed in, and so do explained sums.
+// Malformed documents, and documents followed by anything but whitespace, fail with ErrDocumentInvalid,
+// while the errors of r are returned as they are.
+func (s *DefaultService) SumStream(ctx context.Context, r io.Reader, opts SumOptions) (*SumResult, error) {
+	rules, err := s.sumRules(opts)
+	if err != nil {
+		return nil, err
+	}
+	return s.sumReader(ctx, r, rules, opts)
+}
 
-	case nil:
-		return 0, nil
+// SumBatch sums each of docs as SumStream does with opts, as many at a time as the batch workers of the service
+// allow along with the other batches being summed, and returns their results in the order of docs.
+// The errors of a document are reported in its item and don't keep the others from being summed,
+// while invalid options fail the whole batch. Once ctx is done, the documents left are reported with its error.
+func (s *DefaultService) SumBatch(ctx context.Context, docs [][]byte, opts SumOptions) ([]SumBatchItem, error) {
+	rules, err := s.sumRules(opts)
+	if err != nil {
+		return nil, err
+	}
 
-	case float64:
-		return val, nil
+	items := make([]SumBatchItem, len(docs))
 
-	case int:
-		return float64(val), nil
+	var wg sync.WaitGroup
+	for i := range docs {
+		if err := s.acquireBatchSlot(ctx); err != nil {
+			items[i].Err = fmt.Errorf("could not sum document: %w", err)
+			continue
+		}
 
-	case string:
-		if val == "" {
-			return 0, nil
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer s.releaseBatchSlot()
+
+			items[i].Result, items[i].Err = s.sumReader(ctx, bytes.NewReader(docs[i]), rules, opts)
+		}(i)
+	}
+
+	wg.Wait()
+	return items, nil
+}
+
+// acquireBatchSlot waits for a document of a batch to be allowed to be summed, or for ctx to be done.
+func (s *DefaultService) acquireBatchSlot(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	select {
+	case s.batchSlots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *DefaultService) releaseBatchSlot() {
+	<-s.batchSlots
+}
+
+// sumReader sums the JSON document read from r with rules, and digests the result as opts say.
+func (s *DefaultService) sumReader(ctx context.Context, r io.Reader, rules sumRules, opts SumOptions) (*SumResult, error) {
+	if opts.Explain {
+		data, err := decodeDocument(r)
+		if err != nil {
+			return nil, fmt.Errorf("could not sum numbers: %w", err)
+		}
+		return s.explainSum(data, rules, opts)
+	}
+
+	var (
+		result string
+		err    error
+	)
+
+	if rules.arithmetic == ArithmeticExact {
+		result, err = sumStream(ctx, r, rules)
+	} else {
+		result, err = sumDecoded(r, rules)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not sum numbers: %w", err)
+	}
+	return s.digestSum(result, opts)
+}
+
+// Aggregate computes each of ops over the numbers of the JSON document read from r, treating its values
+// as SumStream does with opts, and digests every result as Sum digests sums. The document is decoded whole,
+// and opts.Explain is ignored. Aggregates of documents without numbers fail with ErrNoValues
+// when one of ops has no result without numbers, such as the mean.
+func (s *DefaultService) Aggregate(ctx context.Context, r io.Reader, ops []Operation, opts SumOptions) (*AggregateResult, error) {
+	if len(ops) == 0 {
+		return nil, fmt.Errorf("could not aggregate without operations: %w", ErrUnsupportedOperation)
+	}
+
+	rules, err := s.sumRules(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	reducers := make([]reducer, 0, len(ops))
+	for _, op := range ops {
+		if _, err := ParseOperation(string(op)); err != nil {
+			return nil, fmt.Errorf("could not aggregate numbers: %w", err)
+		}
+		reducers = append(reducers, newReducer(op, rules.arithmetic))
+	}
+
+	data, err := decodeDocument(r)
+	if err != nil {
+		return nil, fmt.Errorf("could not aggregate numbers: %w", err)
+	}
+
+	if err := reduceNumbers(data, rules, reducers...); err != nil {
+		return nil, fmt.Errorf("could not aggregate numbers: %w", err)
+	}
+
+	result := AggregateResult{Hashes: make(map[Operation]string, len(ops))}
+	for i, op := range ops {
+		value, err := reducers[i].result()
+		if err != nil {
+			return nil, fmt.Errorf("could not compute %s: %w", op, err)
 		}
 
-		num, err := strconv.ParseFloat(val, 64)
+		digest, err := s.digestSum(value, opts)
 		if err != nil {
-			return 0, fmt.Errorf("could not parse string to float: %s,  %w", err, ErrUnsupportedValueType)
+			return nil, err
 		}
-		return num, nil
+
+		result.Hashes[op] = digest.Hash
+		result.Algorithm, result.Encoding = digest.Algorithm, digest.Encoding
+	}
+	return &result, nil
+}
+
+// sumDecoded decodes the JSON document read from r and sums it.
+func sumDecoded(r io.Reader, rules sumRules) (string, error) {
+	data, err := decodeDocument(r)
+	if err != nil {
+		return "", err
+	}
+	return sumNumbers(data, rules)
+}
+
+// decodeDocument decodes the JSON document read from r, keeping its numbers as json.Number,
+// and fails with ErrDocumentInvalid when anything but whitespace follows it.
+func decodeDocument(r io.Reader) (any, error) {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+
+	var data any
+	if err := dec.Decode(&data); err != nil {
+		return nil, documentError(err)
+	}
+
+	if err := documentEnd(dec); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// explainSum sums data and digests the result along with the explanation of the sum.
+func (s *DefaultService) explainSum(data any, rules sumRules, opts SumOptions) (*SumResult, error) {
+	explanation, err := explainNumbers(data, rules)
+	if err != nil {
+		return nil, fmt.Errorf("could not explain sum: %w", err)
+	}
+
+	result, err := s.digestSum(explanation.Sum, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	result.Explanation = explanation
+	return result, nil
+}
+
+// sumRules returns the rules requested by opts, falling back to the service defaults.
+func (s *DefaultService) sumRules(opts SumOptions) (sumRules, error) {
+	rules := sumRules{
+		arithmetic: s.arithmetic,
+		strings:    s.stringMode,
+		booleans:   s.booleans,
+		nulls:      s.nulls,
+		limits:     s.sumLimits,
+	}
+
+	var err error
+	if opts.Strings != "" {
+		if rules.strings, err = ParseStringMode(string(opts.Strings)); err != nil {
+			return sumRules{}, fmt.Errorf("could not sum numbers: %w", err)
+		}
+	}
+
+	if opts.Booleans != "" {
+		if rules.booleans, err = ParseLiteralPolicy(string(opts.Booleans)); err != nil {
+			return sumRules{}, fmt.Errorf("could not sum numbers: %w", err)
+		}
+	}
+
+	if opts.Nulls != "" {
+		if rules.nulls, err = ParseLiteralPolicy(string(opts.Nulls)); err != nil {
+			return sumRules{}, fmt.Errorf("could not sum numbers: %w", err)
+		}
+	}
+
+	if rules.filter, err = newSumFilter(opts.Include, opts.Exclude); err != nil {
+		return sumRules{}, fmt.Errorf("could not sum numbers: %w", err)
+	}
+	return rules, nil
+}
+
+// digestSum digests the result of a sum with the digest algorithm and encoding of opts.
+func (s *DefaultService) digestSum(result string, opts SumOptions) (*SumResult, error) {
+	algorithm, encoding := opts.Algorithm, opts.Encoding
+	if algorithm == "" {
+		algorithm = s.digestAlgorithm
+	}
+
+	if encoding == "" {
+		encoding = s.digestEncoding
+	}
+
+	// Assuming that we don't log debug level in production.
+	s.logger.Debug("generating hash for", zap.String("result", result), zap.String("algorithm", algorithm))
+
+	hash, err := s.digests.Digest(algorithm, encoding, []byte(result))
+	if err != nil {
+		return nil, fmt.Errorf("could not digest sum: %w", err)
+	}
+
+	return &SumResult{
+		Hash:      hash,
+		Algorithm: algorithm,
+		Encoding:  encoding,
+	}, nil
+}
+
+// randomString returns n random bytes encoded as unpadded base64url.
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashRefreshToken returns the ID a refresh token is stored under.
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// sumRules are the settings a document is summed with.
+type sumRules struct {
+	arithmetic Arithmetic
+	strings    StringMode
+	booleans   LiteralPolicy
+	nulls      LiteralPolicy
+	limits     SumLimits
+
+	// filter picks the values summed. Nil sums every value.
+	filter *sumFilter
+}
+
+// sumNumbers sums the provided data and returns the representation of the total
+// defined by the arithmetic of rules, treating strings, booleans and nulls as the rules say
+// and refusing documents beyond their limits. The values refused are reported with a PathError.
+// Documents decoded with json.Decoder.UseNumber keep their numbers as json.Number,
+// which lets the exact arithmetic see every digit the client sent.
+func sumNumbers(data any, rules sumRules) (string, error) {
+	sum := newReducer(OperationSum, rules.arithmetic)
+	if err := reduceNumbers(data, rules, sum); err != nil {
+		return "", err
+	}
+	return sum.result()
+}
+
+// reduceNumbers feeds the numbers of data to every reducer, following rules as sumNumbers does.
+func reduceNumbers(data any, rules sumRules, reducers ...reducer) error {
+	w := numberWalker{
+		reducers:     reducers,
+		rules:        rules,
+		valueCounter: valueCounter{limits: rules.limits},
+	}
+	return w.walk(data, 0)
+}
+
+// numberWalker visits the values of a document, counting them against its limits.
+type numberWalker struct {
+	valueCounter
+	reducers []reducer
+	rules    sumRules
+
+	// trace records what becomes of every value when the sum is explained. It is nil otherwise.
+	trace *sumTrace
+
+	// path is the path to the value being walked.
+	path []pathStep
+}
+
+// valueCounter counts the values of a document against its limits.
+type valueCounter struct {
+	limits SumLimits
+	nodes  int
+}
+
+// visit counts n values found at depth.
+func (c *valueCounter) visit(n, depth int) error {
+	if c.limits.MaxDepth > 0 && depth > c.limits.MaxDepth {
+		return fmt.Errorf("could not sum beyond depth %d: %w", c.limits.MaxDepth, ErrDocumentTooDeep)
+	}
+
+	c.nodes += n
+	if c.limits.MaxNodes > 0 && c.nodes > c.limits.MaxNodes {
+		return fmt.Errorf("could not sum more than %d values: %w", c.limits.MaxNodes, ErrDocumentTooLarge)
+	}
+	return nil
+}
+
+// walk feeds every number found in data, nested depth containers deep, into the reducers.
+// We could possible cover more cases but I think this is enough for the purpose of this exercise.
+// It's also unliked that I wouldn't have clear requirements for this work.
+func (w *numberWalker) walk(data any, depth int) error {
+	if err := w.visit(1, depth); err != nil {
+		return err
+	}
+
+	switch val := data.(type) {
 
 	case []float64:
-		var sum float64
-		for _, v := range val {
-			sum += v
+		if err := w.visit(len(val), depth+1); err != nil {
+			return err
+		}
+
+		for i, v := range val {
+			if err := w.element(i, v); err != nil {
+				return err
+			}
 		}
-		return sum, nil
+		return nil
 
 	case []int:
-		var sum float64
-		for _, v := range val {
-			sum += float64(v)
+		if err := w.visit(len(val), depth+1); err != nil {
+			return err
 		}
-		return sum, nil
+
+		for i, v := range val {
+			if err := w.element(i, v); err != nil {
+				return err
+			}
+		}
+		return nil
 
 	case []string:
-		var sum float64
-		for _, v := range val {
-			if v == "" {
-				continue
+		if err := w.visit(len(val), depth+1); err != nil {
+			return err
+		}
+
+		for i, v := range val {
+			if err := w.element(i, v); err != nil {
+				return err
 			}
+		}
+		return nil
 
-			num, err := strconv.ParseFloat(v, 64)
-			if err != nil {
-				return 0, fmt.Errorf("could not parse string to float: %s,  %w", err, ErrUnsupportedValueType)
+	case []bool:
+		if err := w.visit(len(val), depth+1); err != nil {
+			return err
+		}
+
+		for i, v := range val {
+			if err := w.element(i, v); err != nil {
+				return err
 			}
-			sum += num
 		}
-		return sum, nil
+		return nil
 
 	case []any:
-		var sum float64
-		for _, v := range val {
-			result, err := sumNumbers(v)
+		for i, v := range val {
+			w.enter(indexStep(i))
+			err := w.walk(v, depth+1)
+			w.leave()
+
 			if err != nil {
-				return 0, fmt.Errorf("could not sum numbers: %s,  %w", err, ErrUnsupportedValueType)
+				return fmt.Errorf("could not sum numbers: %w", err)
 			}
-
-			sum += result
 		}
-		return sum, nil
+		return nil
 
 	case map[string]any:
-		var sum float64
-		for _, v := range val {
-			result, err := sumNumbers(v)
+		// Visit the keys in a stable order so that float sums don't depend on map iteration.
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			w.enter(keyStep(k))
+			err := w.walk(val[k], depth+1)
+			w.leave()
+
 			if err != nil {
-				return 0, fmt.Errorf("could not sum numbers: %s,  %w", err, ErrUnsupportedValueType)
+				return fmt.Errorf("could not sum numbers: %w", err)
 			}
-			sum += result
 		}
-		return sum, nil
+		return nil
+
+	default:
+		return w.scalar(data)
+	}
+}
+
+func (w *numberWalker) enter(step pathStep) {
+	w.path = append(w.path, step)
+}
+
+func (w *numberWalker) leave() {
+	w.path = w.path[:len(w.path)-1]
+}
+
+// element sums the scalar v found at index i of a typed slice.
+func (w *numberWalker) element(i int, v any) error {
+	w.enter(indexStep(i))
+	defer w.leave()
+
+	return w.scalar(v)
+}
+
+// scalar sums a value that is neither an array nor an object, unless the filter of the sum leaves it out.
+// When the sum is explained, the value is recorded instead of failing the walk.
+func (w *numberWalker) scalar(v any) error {
+	if !w.rules.filter.selects(w.path) {
+		if w.trace != nil {
+			w.trace.recordUnselected(w.path, v)
+		}
+		return nil
+	}
+
+	added, err := w.add(v)
+	if w.trace == nil {
+		if err != nil {
+			return &PathError{Path: jsonPath(w.path), Err: err}
+		}
+		return nil
+	}
+
+	w.trace.record(w.path, v, added, err)
+	return nil
+}
+
+// add adds the number the scalar v stands for, and reports whether it did.
+func (w *numberWalker) add(v any) (bool, error) {
+	switch val := v.(type) {
+
+	case nil:
+		return w.literal(w.rules.nulls, nil)
+
+	case bool:
+		return w.literal(w.rules.booleans, val)
+
+	case json.Number:
+		return true, w.reduce(val.String())
+
+	case float64:
+		return true, w.reduce(strconv.FormatFloat(val, 'g', -1, 64))
+
+	case int:
+		return true, w.reduce(strconv.Itoa(val))
+
+	case string:
+		return w.rules.strings.addString(val, w.reduce)
 
 	default:
-		return 0, ErrUnsupportedValueType
+		return false, ErrUnsupportedValueType
+	}
+}
+
+// reduce feeds the number represented by the literal s to every reducer.
+func (w *numberWalker) reduce(s string) error {
+	for _, r := range w.reducers {
+		if err := r.add(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// literal adds the number a boolean or null stands for under policy, and reports whether it did.
+func (w *numberWalker) literal(policy LiteralPolicy, v any) (bool, error) {
+	num, err := policy.number(v)
+	if err != nil || num == "" {
+		return false, err
 	}
+	return true, w.reduce(num)
 }
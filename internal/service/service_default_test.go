@@ -1,8 +1,12 @@
 package service
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"strings"
 	"testing"
 
 	"time"
@@ -11,6 +15,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
 )
 
 func TestDefaultService_GenerateToken(t *testing.T) {
@@ -24,7 +29,7 @@ func TestDefaultService_GenerateToken(t *testing.T) {
 	logger := zap.NewNop()
 	jwtKey := []byte("foo-key")
 
-	service := NewDefaultService(logger, jwtKey)
+	service := NewDefaultService(logger, jwtKey, WithUserStore(newTestUserStore(t, creds)))
 
 	observedToken, err := service.GenerateToken(context.TODO(), creds)
 	require.NoError(t, err)
@@ -50,12 +55,28 @@ func TestDefaultService_GenerateToken(t *testing.T) {
 	assert.Equal(t, "foo-issuer", claims.Issuer)
 	assert.Equal(t, "foo-audience", claims.Audience)
 	assert.True(t, time.Now().Before(time.Unix(claims.ExpiresAt, 0)))
+
+	// Token IDs are random, so two tokens issued within the same second differ.
+	otherToken, err := service.GenerateToken(context.TODO(), creds)
+	require.NoError(t, err)
+
+	otherClaims := &Claims{}
+	_, err = jwt.ParseWithClaims(otherToken.AccessToken, otherClaims, func(token *jwt.Token) (any, error) {
+		return jwtKey, nil
+	})
+	require.NoError(t, err)
+
+	assert.NotEmpty(t, claims.Id)
+	assert.NotEqual(t, claims.Id, otherClaims.Id)
 }
 
 func TestDefaultService_Authenticate_InvalidCredentials(t *testing.T) {
 	t.Parallel()
 
-	service := NewDefaultService(zap.NewNop(), nil)
+	service := NewDefaultService(zap.NewNop(), []byte("foo-key"), WithUserStore(newTestUserStore(t, Credentials{
+		Username: "foo",
+		Password: "bar",
+	})))
 
 	t.Run("invalid username", func(t *testing.T) {
 		givenCreds := Credentials{
@@ -76,6 +97,651 @@ func TestDefaultService_Authenticate_InvalidCredentials(t *testing.T) {
 		_, err := service.GenerateToken(context.TODO(), givenCreds)
 		assert.Equal(t, ErrPasswordInvalid, errors.Unwrap(err))
 	})
+
+	t.Run("wrong password", func(t *testing.T) {
+		givenCreds := Credentials{
+			Username: "foo",
+			Password: "baz",
+		}
+
+		_, err := service.GenerateToken(context.TODO(), givenCreds)
+		assert.True(t, errors.Is(err, ErrCredentialsMismatch))
+	})
+
+	t.Run("unknown user", func(t *testing.T) {
+		givenCreds := Credentials{
+			Username: "qux",
+			Password: "bar",
+		}
+
+		_, err := service.GenerateToken(context.TODO(), givenCreds)
+		assert.True(t, errors.Is(err, ErrCredentialsMismatch))
+	})
+}
+
+func TestDefaultService_RefreshToken(t *testing.T) {
+	t.Parallel()
+
+	creds := Credentials{
+		Username: "foo-username",
+		Password: "bar-password",
+	}
+
+	service := NewDefaultService(zap.NewNop(), []byte("foo-key"), WithUserStore(newTestUserStore(t, creds)))
+
+	t.Run("rotates the refresh token", func(t *testing.T) {
+		givenToken, err := service.GenerateToken(context.TODO(), creds)
+		require.NoError(t, err)
+		require.NotEmpty(t, givenToken.RefreshToken)
+
+		observedToken, err := service.RefreshToken(context.TODO(), givenToken.RefreshToken)
+		require.NoError(t, err)
+
+		assert.NotEmpty(t, observedToken.AccessToken)
+		assert.NotEmpty(t, observedToken.RefreshToken)
+		assert.NotEqual(t, givenToken.RefreshToken, observedToken.RefreshToken)
+		_, err = service.VerifyToken(context.TODO(), observedToken.AccessToken)
+		assert.NoError(t, err)
+	})
+
+	t.Run("reuse revokes the family", func(t *testing.T) {
+		givenToken, err := service.GenerateToken(context.TODO(), creds)
+		require.NoError(t, err)
+
+		rotatedToken, err := service.RefreshToken(context.TODO(), givenToken.RefreshToken)
+		require.NoError(t, err)
+
+		_, err = service.RefreshToken(context.TODO(), givenToken.RefreshToken)
+		assert.True(t, errors.Is(err, ErrRefreshTokenReused))
+
+		// The token issued by the legitimate rotation is revoked as well.
+		_, err = service.RefreshToken(context.TODO(), rotatedToken.RefreshToken)
+		assert.True(t, errors.Is(err, ErrRefreshTokenInvalid))
+	})
+
+	t.Run("unknown token", func(t *testing.T) {
+		_, err := service.RefreshToken(context.TODO(), "foo-refresh-token")
+		assert.True(t, errors.Is(err, ErrRefreshTokenInvalid))
+	})
+
+	t.Run("expired token", func(t *testing.T) {
+		service := NewDefaultService(zap.NewNop(), []byte("foo-key"),
+			WithUserStore(newTestUserStore(t, creds)),
+			WithRefreshTokens(NewMemoryRefreshTokenStore(), -time.Second),
+		)
+
+		givenToken, err := service.GenerateToken(context.TODO(), creds)
+		require.NoError(t, err)
+
+		_, err = service.RefreshToken(context.TODO(), givenToken.RefreshToken)
+		assert.True(t, errors.Is(err, ErrRefreshTokenExpired))
+	})
+}
+
+func TestDefaultService_Grant(t *testing.T) {
+	t.Parallel()
+
+	creds := Credentials{
+		Username: "foo-username",
+		Password: "bar-password",
+	}
+
+	client := ClientCredentials{
+		ID:     "foo-client",
+		Secret: "bar-secret",
+	}
+
+	otherClient := ClientCredentials{
+		ID:     "baz-client",
+		Secret: "qux-secret",
+	}
+
+	service := NewDefaultService(zap.NewNop(), []byte("foo-key"),
+		WithUserStore(newTestUserStore(t, creds)),
+		WithClientStore(newTestClientStore(t, client, otherClient)),
+	)
+
+	t.Run("password", func(t *testing.T) {
+		observedToken, err := service.Grant(context.TODO(), GrantRequest{
+			GrantType:   GrantPassword,
+			Client:      client,
+			Credentials: creds,
+		})
+		require.NoError(t, err)
+
+		assert.NotEmpty(t, observedToken.RefreshToken)
+
+		claims, err := service.verifyToken(context.TODO(), observedToken.AccessToken)
+		require.NoError(t, err)
+
+		assert.Equal(t, "foo-username", claims.Subject)
+		assert.Equal(t, "foo-client", claims.ClientID)
+	})
+
+	t.Run("client_credentials", func(t *testing.T) {
+		observedToken, err := service.Grant(context.TODO(), GrantRequest{
+			GrantType: GrantClientCredentials,
+			Client:    client,
+		})
+		require.NoError(t, err)
+
+		assert.Empty(t, observedToken.RefreshToken)
+
+		claims, err := service.verifyToken(context.TODO(), observedToken.AccessToken)
+		require.NoError(t, err)
+
+		assert.Equal(t, "foo-client", claims.Subject)
+		assert.Equal(t, "foo-client", claims.ClientID)
+	})
+
+	t.Run("refresh_token", func(t *testing.T) {
+		givenToken, err := service.Grant(context.TODO(), GrantRequest{
+			GrantType:   GrantPassword,
+			Client:      client,
+			Credentials: creds,
+		})
+		require.NoError(t, err)
+
+		observedToken, err := service.Grant(context.TODO(), GrantRequest{
+			GrantType:    GrantRefreshToken,
+			Client:       client,
+			RefreshToken: givenToken.RefreshToken,
+		})
+		require.NoError(t, err)
+
+		assert.NotEqual(t, givenToken.RefreshToken, observedToken.RefreshToken)
+	})
+
+	t.Run("refresh token of another client", func(t *testing.T) {
+		givenToken, err := service.Grant(context.TODO(), GrantRequest{
+			GrantType:   GrantPassword,
+			Client:      client,
+			Credentials: creds,
+		})
+		require.NoError(t, err)
+
+		_, err = service.Grant(context.TODO(), GrantRequest{
+			GrantType:    GrantRefreshToken,
+			Client:       otherClient,
+			RefreshToken: givenToken.RefreshToken,
+		})
+		assert.True(t, errors.Is(err, ErrRefreshTokenInvalid))
+	})
+
+	t.Run("client refresh tokens can't be refreshed without client", func(t *testing.T) {
+		givenToken, err := service.Grant(context.TODO(), GrantRequest{
+			GrantType:   GrantPassword,
+			Client:      client,
+			Credentials: creds,
+		})
+		require.NoError(t, err)
+
+		_, err = service.RefreshToken(context.TODO(), givenToken.RefreshToken)
+		assert.True(t, errors.Is(err, ErrRefreshTokenInvalid))
+	})
+
+	testCases := []struct {
+		name          string
+		givenRequest  GrantRequest
+		expectedError error
+	}{
+		{
+			name:          "unknown client",
+			givenRequest:  GrantRequest{GrantType: GrantClientCredentials, Client: ClientCredentials{ID: "foo", Secret: "bar"}},
+			expectedError: ErrClientCredentialsMismatch,
+		},
+		{
+			name:          "wrong client secret",
+			givenRequest:  GrantRequest{GrantType: GrantClientCredentials, Client: ClientCredentials{ID: "foo-client", Secret: "qux-secret"}},
+			expectedError: ErrClientCredentialsMismatch,
+		},
+		{
+			name:          "missing client",
+			givenRequest:  GrantRequest{GrantType: GrantClientCredentials},
+			expectedError: ErrClientCredentialsMismatch,
+		},
+		{
+			name:          "wrong password",
+			givenRequest:  GrantRequest{GrantType: GrantPassword, Client: client, Credentials: Credentials{Username: "foo-username", Password: "foo"}},
+			expectedError: ErrCredentialsMismatch,
+		},
+		{
+			name:          "missing password",
+			givenRequest:  GrantRequest{GrantType: GrantPassword, Client: client, Credentials: Credentials{Username: "foo-username"}},
+			expectedError: ErrPasswordInvalid,
+		},
+		{
+			name:          "missing refresh token",
+			givenRequest:  GrantRequest{GrantType: GrantRefreshToken, Client: client},
+			expectedError: ErrRefreshTokenInvalid,
+		},
+		{
+			name:          "unsupported grant type",
+			givenRequest:  GrantRequest{GrantType: "authorization_code", Client: client},
+			expectedError: ErrUnsupportedGrantType,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			observedToken, err := service.Grant(context.TODO(), tc.givenRequest)
+
+			assert.Nil(t, observedToken)
+			assert.True(t, errors.Is(err, tc.expectedError), err)
+		})
+	}
+}
+
+func TestDefaultService_scopes(t *testing.T) {
+	t.Parallel()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("bar-password"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	users, err := NewMemoryUserStore(
+		User{Username: "foo-username", PasswordHash: string(hash)},
+		User{Username: "reader", PasswordHash: string(hash), Scopes: []string{"read", "sum"}},
+	)
+	require.NoError(t, err)
+
+	clients, err := NewMemoryClientStore(
+		Client{ID: "foo-client", SecretHash: string(hash)},
+		Client{ID: "sum-client", SecretHash: string(hash), Scopes: []string{"sum"}},
+	)
+	require.NoError(t, err)
+
+	service := NewDefaultService(zap.NewNop(), []byte("foo-key"),
+		WithUserStore(users),
+		WithClientStore(clients),
+		WithDefaultScopes("sum", "default"),
+	)
+
+	scopeOf := func(t *testing.T, token *Token) string {
+		t.Helper()
+
+		claims, err := service.verifyToken(context.TODO(), token.AccessToken)
+		require.NoError(t, err)
+
+		assert.Equal(t, claims.Scope, token.Scope)
+		return claims.Scope
+	}
+
+	t.Run("users without scopes get the default scopes", func(t *testing.T) {
+		token, err := service.GenerateToken(context.TODO(), Credentials{Username: "foo-username", Password: "bar-password"})
+		require.NoError(t, err)
+
+		assert.Equal(t, "sum default", scopeOf(t, token))
+	})
+
+	t.Run("users with scopes get theirs", func(t *testing.T) {
+		token, err := service.GenerateToken(context.TODO(), Credentials{Username: "reader", Password: "bar-password"})
+		require.NoError(t, err)
+
+		assert.Equal(t, "read sum", scopeOf(t, token))
+	})
+
+	t.Run("clients with scopes limit the tokens of their users", func(t *testing.T) {
+		token, err := service.Grant(context.TODO(), GrantRequest{
+			GrantType:   GrantPassword,
+			Client:      ClientCredentials{ID: "sum-client", Secret: "bar-password"},
+			Credentials: Credentials{Username: "reader", Password: "bar-password"},
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, "sum", scopeOf(t, token))
+	})
+
+	t.Run("requested scopes narrow the token", func(t *testing.T) {
+		token, err := service.Grant(context.TODO(), GrantRequest{
+			GrantType:   GrantPassword,
+			Client:      ClientCredentials{ID: "foo-client", Secret: "bar-password"},
+			Credentials: Credentials{Username: "reader", Password: "bar-password"},
+			Scopes:      []string{"read"},
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, "read", scopeOf(t, token))
+	})
+
+	t.Run("requesting a scope that isn't granted", func(t *testing.T) {
+		_, err := service.Grant(context.TODO(), GrantRequest{
+			GrantType: GrantClientCredentials,
+			Client:    ClientCredentials{ID: "sum-client", Secret: "bar-password"},
+			Scopes:    []string{"read"},
+		})
+		assert.True(t, errors.Is(err, ErrScopeInvalid))
+	})
+
+	t.Run("client_credentials", func(t *testing.T) {
+		token, err := service.Grant(context.TODO(), GrantRequest{
+			GrantType: GrantClientCredentials,
+			Client:    ClientCredentials{ID: "foo-client", Secret: "bar-password"},
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, "sum default", scopeOf(t, token))
+	})
+
+	t.Run("refreshing narrows the access token but not the refresh token", func(t *testing.T) {
+		client := ClientCredentials{ID: "foo-client", Secret: "bar-password"}
+
+		givenToken, err := service.Grant(context.TODO(), GrantRequest{
+			GrantType:   GrantPassword,
+			Client:      client,
+			Credentials: Credentials{Username: "reader", Password: "bar-password"},
+		})
+		require.NoError(t, err)
+
+		narrowToken, err := service.Grant(context.TODO(), GrantRequest{
+			GrantType:    GrantRefreshToken,
+			Client:       client,
+			RefreshToken: givenToken.RefreshToken,
+			Scopes:       []string{"sum"},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "sum", scopeOf(t, narrowToken))
+
+		observedToken, err := service.Grant(context.TODO(), GrantRequest{
+			GrantType:    GrantRefreshToken,
+			Client:       client,
+			RefreshToken: narrowToken.RefreshToken,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "read sum", scopeOf(t, observedToken))
+	})
+}
+
+func TestDefaultService_tokenOptions(t *testing.T) {
+	t.Parallel()
+
+	creds := Credentials{
+		Username: "foo-username",
+		Password: "bar-password",
+	}
+
+	client := ClientCredentials{
+		ID:     "foo-client",
+		Secret: "bar-secret",
+	}
+
+	jwtKey := []byte("foo-key")
+
+	opts := TokenOptions{
+		Issuer:            "https://auth.example.com",
+		Audience:          "sum-api",
+		AcceptedAudiences: []string{"legacy-api"},
+		TTL:               10 * time.Minute,
+		ClientTTLs:        map[string]time.Duration{"foo-client": 2 * time.Hour},
+		Leeway:            time.Minute,
+	}
+
+	service := NewDefaultService(zap.NewNop(), jwtKey,
+		WithUserStore(newTestUserStore(t, creds)),
+		WithClientStore(newTestClientStore(t, client)),
+		WithTokenOptions(opts),
+	)
+
+	signed := func(t *testing.T, claims jwt.StandardClaims) string {
+		t.Helper()
+
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{StandardClaims: claims})
+		signedToken, err := token.SignedString(jwtKey)
+		require.NoError(t, err)
+		return signedToken
+	}
+
+	t.Run("issued claims", func(t *testing.T) {
+		token, err := service.GenerateToken(context.TODO(), creds)
+		require.NoError(t, err)
+
+		assert.Equal(t, int64(600), token.ExpiresIn)
+
+		claims, err := service.VerifyToken(context.TODO(), token.AccessToken)
+		require.NoError(t, err)
+
+		assert.Equal(t, "https://auth.example.com", claims.Issuer)
+		assert.Equal(t, "sum-api", claims.Audience)
+		assert.Equal(t, claims.IssuedAt+600, claims.ExpiresAt)
+		assert.Equal(t, claims.IssuedAt, claims.NotBefore)
+	})
+
+	t.Run("per-client TTL", func(t *testing.T) {
+		token, err := service.Grant(context.TODO(), GrantRequest{GrantType: GrantClientCredentials, Client: client})
+		require.NoError(t, err)
+
+		assert.Equal(t, int64(7200), token.ExpiresIn)
+
+		claims, err := service.VerifyToken(context.TODO(), token.AccessToken)
+		require.NoError(t, err)
+
+		assert.Equal(t, claims.IssuedAt+7200, claims.ExpiresAt)
+	})
+
+	t.Run("accepted audience", func(t *testing.T) {
+		_, err := service.VerifyToken(context.TODO(), signed(t, jwt.StandardClaims{
+			Issuer:    "https://auth.example.com",
+			Audience:  "legacy-api",
+			ExpiresAt: time.Now().Add(time.Minute).Unix(),
+		}))
+		assert.NoError(t, err)
+
+		_, err = service.VerifyToken(context.TODO(), signed(t, jwt.StandardClaims{
+			Issuer:    "https://auth.example.com",
+			Audience:  "foo-audience",
+			ExpiresAt: time.Now().Add(time.Minute).Unix(),
+		}))
+		assert.True(t, errors.Is(err, ErrTokenInvalidAudience))
+	})
+
+	t.Run("default issuer is rejected", func(t *testing.T) {
+		_, err := service.VerifyToken(context.TODO(), signed(t, jwt.StandardClaims{
+			Issuer:    "foo-issuer",
+			Audience:  "sum-api",
+			ExpiresAt: time.Now().Add(time.Minute).Unix(),
+		}))
+		assert.True(t, errors.Is(err, ErrTokenInvalidIssuer))
+	})
+
+	t.Run("leeway", func(t *testing.T) {
+		testCases := []struct {
+			name          string
+			givenClaims   jwt.StandardClaims
+			expectedError error
+		}{
+			{
+				name:        "expired within leeway",
+				givenClaims: jwt.StandardClaims{ExpiresAt: time.Now().Add(-30 * time.Second).Unix()},
+			},
+			{
+				name:          "expired beyond leeway",
+				givenClaims:   jwt.StandardClaims{ExpiresAt: time.Now().Add(-2 * time.Minute).Unix()},
+				expectedError: ErrTokenInvalidExpiration,
+			},
+			{
+				name: "issued slightly in the future",
+				givenClaims: jwt.StandardClaims{
+					ExpiresAt: time.Now().Add(time.Hour).Unix(),
+					IssuedAt:  time.Now().Add(30 * time.Second).Unix(),
+					NotBefore: time.Now().Add(30 * time.Second).Unix(),
+				},
+			},
+			{
+				name: "not valid yet",
+				givenClaims: jwt.StandardClaims{
+					ExpiresAt: time.Now().Add(time.Hour).Unix(),
+					NotBefore: time.Now().Add(2 * time.Minute).Unix(),
+				},
+				expectedError: ErrTokenNotYetValid,
+			},
+			{
+				name: "issued in the future",
+				givenClaims: jwt.StandardClaims{
+					ExpiresAt: time.Now().Add(time.Hour).Unix(),
+					IssuedAt:  time.Now().Add(2 * time.Minute).Unix(),
+				},
+				expectedError: ErrTokenInvalidIssuedAt,
+			},
+		}
+
+		for _, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				tc.givenClaims.Issuer = "https://auth.example.com"
+				tc.givenClaims.Audience = "sum-api"
+
+				_, err := service.VerifyToken(context.TODO(), signed(t, tc.givenClaims))
+				if tc.expectedError == nil {
+					assert.NoError(t, err)
+					return
+				}
+				assert.True(t, errors.Is(err, tc.expectedError), err)
+			})
+		}
+	})
+
+	t.Run("revoked within leeway", func(t *testing.T) {
+		claims := jwt.StandardClaims{
+			Id:        "foo-jti",
+			Issuer:    "https://auth.example.com",
+			Audience:  "sum-api",
+			ExpiresAt: time.Now().Add(-30 * time.Second).Unix(),
+		}
+
+		require.NoError(t, service.Logout(context.TODO(), signed(t, claims), ""))
+
+		_, err := service.VerifyToken(context.TODO(), signed(t, claims))
+		assert.True(t, errors.Is(err, ErrTokenRevoked), err)
+
+		claims.Id = "bar-jti"
+		require.NoError(t, service.RevokeTokenID(context.TODO(), claims.Id, time.Unix(claims.ExpiresAt, 0)))
+
+		_, err = service.VerifyToken(context.TODO(), signed(t, claims))
+		assert.True(t, errors.Is(err, ErrTokenRevoked), err)
+	})
+}
+
+func TestDefaultService_customClaims(t *testing.T) {
+	t.Parallel()
+
+	creds := Credentials{
+		Username: "foo-username",
+		Password: "bar-password",
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(creds.Password), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	users, err := NewMemoryUserStore(User{
+		Username:     creds.Username,
+		PasswordHash: string(hash),
+		Claims:       map[string]any{"tenant": "acme", "roles": []any{"admin"}},
+	})
+	require.NoError(t, err)
+
+	service := NewDefaultService(zap.NewNop(), []byte("foo-key"), WithUserStore(users))
+
+	token, err := service.GenerateToken(context.TODO(), creds)
+	require.NoError(t, err)
+
+	claims, err := service.VerifyToken(context.TODO(), token.AccessToken)
+	require.NoError(t, err)
+
+	assert.Equal(t, creds.Username, claims.Subject)
+	assert.Equal(t, map[string]any{"tenant": "acme", "roles": []any{"admin"}}, claims.Custom)
+
+	t.Run("refreshing reads the claims again", func(t *testing.T) {
+		require.NoError(t, users.PutUser(User{
+			Username:     creds.Username,
+			PasswordHash: string(hash),
+			Claims:       map[string]any{"tenant": "acme"},
+		}))
+
+		refreshed, err := service.RefreshToken(context.TODO(), token.RefreshToken)
+		require.NoError(t, err)
+
+		claims, err := service.VerifyToken(context.TODO(), refreshed.AccessToken)
+		require.NoError(t, err)
+
+		assert.Equal(t, map[string]any{"tenant": "acme"}, claims.Custom)
+	})
+}
+
+func TestDefaultService_Logout(t *testing.T) {
+	t.Parallel()
+
+	creds := Credentials{
+		Username: "foo-username",
+		Password: "bar-password",
+	}
+
+	service := NewDefaultService(zap.NewNop(), []byte("foo-key"), WithUserStore(newTestUserStore(t, creds)))
+
+	t.Run("revokes the access token", func(t *testing.T) {
+		givenToken, err := service.GenerateToken(context.TODO(), creds)
+		require.NoError(t, err)
+
+		otherToken, err := service.GenerateToken(context.TODO(), creds)
+		require.NoError(t, err)
+
+		require.NoError(t, service.Logout(context.TODO(), givenToken.AccessToken, ""))
+
+		_, observedErr := service.VerifyToken(context.TODO(), givenToken.AccessToken)
+		assert.True(t, errors.Is(observedErr, ErrTokenRevoked))
+
+		// Other sessions of the same user are unaffected.
+		_, err = service.VerifyToken(context.TODO(), otherToken.AccessToken)
+		assert.NoError(t, err)
+
+		// The refresh token wasn't given, so it still works.
+		_, err = service.RefreshToken(context.TODO(), givenToken.RefreshToken)
+		assert.NoError(t, err)
+	})
+
+	t.Run("revokes the refresh token family", func(t *testing.T) {
+		givenToken, err := service.GenerateToken(context.TODO(), creds)
+		require.NoError(t, err)
+
+		require.NoError(t, service.Logout(context.TODO(), givenToken.AccessToken, givenToken.RefreshToken))
+
+		_, err = service.RefreshToken(context.TODO(), givenToken.RefreshToken)
+		assert.Error(t, err)
+	})
+
+	t.Run("revoked token can't log out again", func(t *testing.T) {
+		givenToken, err := service.GenerateToken(context.TODO(), creds)
+		require.NoError(t, err)
+
+		require.NoError(t, service.Logout(context.TODO(), givenToken.AccessToken, ""))
+
+		observedErr := service.Logout(context.TODO(), givenToken.AccessToken, "")
+		assert.True(t, errors.Is(observedErr, ErrTokenRevoked))
+	})
+}
+
+func TestDefaultService_RevokeTokenID(t *testing.T) {
+	t.Parallel()
+
+	creds := Credentials{
+		Username: "foo-username",
+		Password: "bar-password",
+	}
+
+	service := NewDefaultService(zap.NewNop(), []byte("foo-key"), WithUserStore(newTestUserStore(t, creds)))
+
+	givenToken, err := service.GenerateToken(context.TODO(), creds)
+	require.NoError(t, err)
+
+	claims := &Claims{}
+	_, err = jwt.ParseWithClaims(givenToken.AccessToken, claims, func(token *jwt.Token) (any, error) {
+		return []byte("foo-key"), nil
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, service.RevokeTokenID(context.TODO(), claims.Id, time.Time{}))
+
+	_, observedErr := service.VerifyToken(context.TODO(), givenToken.AccessToken)
+	assert.True(t, errors.Is(observedErr, ErrTokenRevoked))
+
+	assert.True(t, errors.Is(service.RevokeTokenID(context.TODO(), "", time.Time{}), ErrTokenInvalid))
 }
 
 func TestDefaultService_VerifyToken(t *testing.T) {
@@ -85,7 +751,10 @@ func TestDefaultService_VerifyToken(t *testing.T) {
 	jwtKey := []byte("test-key")
 	username := "foo-username"
 
-	service := NewDefaultService(logger, jwtKey)
+	service := NewDefaultService(logger, jwtKey, WithUserStore(newTestUserStore(t, Credentials{
+		Username: username,
+		Password: "bar",
+	})))
 
 	t.Run("valid token", func(t *testing.T) {
 		givenCreds := Credentials{
@@ -96,8 +765,14 @@ func TestDefaultService_VerifyToken(t *testing.T) {
 		givenToken, err := service.GenerateToken(context.TODO(), givenCreds)
 		require.NoError(t, err)
 
-		observedErr := service.VerifyToken(context.TODO(), givenToken.AccessToken)
-		assert.NoError(t, observedErr)
+		observedClaims, observedErr := service.VerifyToken(context.TODO(), givenToken.AccessToken)
+		require.NoError(t, observedErr)
+
+		assert.Equal(t, username, observedClaims.Subject)
+		assert.Equal(t, "foo-issuer", observedClaims.Issuer)
+		assert.NotEmpty(t, observedClaims.Id)
+		assert.NotZero(t, observedClaims.IssuedAt)
+		assert.Greater(t, observedClaims.ExpiresAt, observedClaims.IssuedAt)
 	})
 
 	t.Run("expired token", func(t *testing.T) {
@@ -114,9 +789,9 @@ func TestDefaultService_VerifyToken(t *testing.T) {
 		signedToken, err := token.SignedString(jwtKey)
 		require.NoError(t, err)
 
-		observedErr := service.VerifyToken(context.TODO(), signedToken)
+		_, observedErr := service.VerifyToken(context.TODO(), signedToken)
 
-		assert.Error(t, observedErr)
+		assert.True(t, errors.Is(observedErr, ErrTokenInvalidExpiration))
 	})
 
 	t.Run("invalid issuer", func(t *testing.T) {
@@ -133,7 +808,7 @@ func TestDefaultService_VerifyToken(t *testing.T) {
 		signedToken, err := token.SignedString(jwtKey)
 		require.NoError(t, err)
 
-		observedErr := service.VerifyToken(context.TODO(), signedToken)
+		_, observedErr := service.VerifyToken(context.TODO(), signedToken)
 
 		assert.Error(t, observedErr)
 	})
@@ -151,7 +826,7 @@ func TestDefaultService_VerifyToken(t *testing.T) {
 		signedToken, err := token.SignedString(jwtKey)
 		require.NoError(t, err)
 
-		observedErr := service.VerifyToken(context.TODO(), signedToken)
+		_, observedErr := service.VerifyToken(context.TODO(), signedToken)
 
 		assert.True(t, errors.Is(observedErr, ErrTokenInvalidIssuer))
 	})
@@ -170,68 +845,210 @@ func TestDefaultService_VerifyToken(t *testing.T) {
 		signedToken, err := token.SignedString(jwtKey)
 		require.NoError(t, err)
 
-		observedErr := service.VerifyToken(context.TODO(), signedToken)
+		_, observedErr := service.VerifyToken(context.TODO(), signedToken)
 
 		assert.Error(t, observedErr)
 	})
 
-	t.Run("audience is not set", func(t *testing.T) {
-		claims := Claims{
-			StandardClaims: jwt.StandardClaims{
-				Subject:   username,
-				Issuer:    "foo-issuer",
-				ExpiresAt: time.Now().Add(time.Hour).Unix(),
-			},
-		}
+	t.Run("audience is not set", func(t *testing.T) {
+		claims := Claims{
+			StandardClaims: jwt.StandardClaims{
+				Subject:   username,
+				Issuer:    "foo-issuer",
+				ExpiresAt: time.Now().Add(time.Hour).Unix(),
+			},
+		}
+
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+		signedToken, err := token.SignedString(jwtKey)
+		require.NoError(t, err)
+
+		_, observedErr := service.VerifyToken(context.TODO(), signedToken)
+
+		assert.True(t, errors.Is(observedErr, ErrTokenInvalidAudience))
+	})
+
+	t.Run("expiration is not set", func(t *testing.T) {
+		claims := Claims{
+			StandardClaims: jwt.StandardClaims{
+				Subject:  username,
+				Issuer:   "foo-issuer",
+				Audience: "foo-audience",
+			},
+		}
+
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+		signedToken, err := token.SignedString(jwtKey)
+		require.NoError(t, err)
+
+		_, observedErr := service.VerifyToken(context.TODO(), signedToken)
+
+		assert.True(t, errors.Is(observedErr, ErrTokenInvalidExpiration))
+	})
+
+	t.Run("invalid token string", func(t *testing.T) {
+		_, observedErr := service.VerifyToken(context.TODO(), "invalid-token-string")
+		assert.True(t, errors.Is(observedErr, ErrTokenInvalid))
+	})
+}
+
+func TestDefaultService_Sum(t *testing.T) {
+	service := NewDefaultService(zap.NewNop(), nil)
+
+	data := []float64{1, 2, 3}
+	expectedHash := "e7f6c011776e8db7cd330b54174fd76f7d0216b612387a5ffcfb81e6f0919683" // sha256("6")
+
+	actualResult, err := service.Sum(context.TODO(), data, SumOptions{})
+
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	if actualResult.Hash != expectedHash {
+		t.Errorf("Unexpected hash value: got %v, want %v", actualResult.Hash, expectedHash)
+	}
+
+	if actualResult.Algorithm != DigestSHA256 || actualResult.Encoding != EncodingHex {
+		t.Errorf("Unexpected digest: got %v/%v", actualResult.Algorithm, actualResult.Encoding)
+	}
+}
+
+func TestDefaultService_Sum_floatArithmetic(t *testing.T) {
+	service := NewDefaultService(zap.NewNop(), nil, WithArithmetic(ArithmeticFloat))
+
+	data := []float64{1, 2, 3}
+	expectedHash := "2270ab850480a8ade7647dc3066dde96209bc0314b4847a619e1231e334c00ad" // sha256("6.000000")
+
+	actualResult, err := service.Sum(context.TODO(), data, SumOptions{})
+	require.NoError(t, err)
+
+	assert.Equal(t, expectedHash, actualResult.Hash)
+}
+
+func TestDefaultService_Sum_digestOptions(t *testing.T) {
+	t.Parallel()
+
+	digests := NewDigestRegistry()
+	digests.RegisterAlgorithm(HMACSHA256([]byte("foo-key")))
+
+	service := NewDefaultService(zap.NewNop(), nil, WithDigests(digests, DigestSHA512, EncodingBase64URL))
+
+	t.Run("server default", func(t *testing.T) {
+		observed, err := service.Sum(context.TODO(), []any{json.Number("6")}, SumOptions{})
+		require.NoError(t, err)
+
+		assert.Equal(t, DigestSHA512, observed.Algorithm)
+		assert.Equal(t, EncodingBase64URL, observed.Encoding)
+	})
+
+	t.Run("requested algorithm", func(t *testing.T) {
+		observed, err := service.Sum(context.TODO(), []any{json.Number("6")}, SumOptions{
+			Algorithm: DigestHMACSHA256,
+			Encoding:  EncodingHex,
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, DigestHMACSHA256, observed.Algorithm)
+		assert.Equal(t, "4844672265635ce86cc52b1dfd4ebbae3d3bb94322965fad1f00db0f329e8129", observed.Hash)
+	})
+
+	t.Run("unknown algorithm", func(t *testing.T) {
+		_, err := service.Sum(context.TODO(), []any{json.Number("6")}, SumOptions{Algorithm: "md5"})
+		assert.True(t, errors.Is(err, ErrUnsupportedDigest))
+	})
+}
+
+func TestDefaultService_Sum_stringModes(t *testing.T) {
+	t.Parallel()
+
+	data := []any{json.Number("4"), "2", "dark"}
+	doc := `[4, "2", "dark"]`
+
+	testCases := []struct {
+		name          string
+		givenOptions  []Option
+		givenMode     StringMode
+		expectedHash  string
+		expectedError error
+	}{
+		{
+			name:         "server default ignores strings",
+			expectedHash: "4b227777d4dd1fc61c6f884f48641d02b4d121d3fd328cb08b5531fcacdabf8a", // sha256("4")
+		},
+		{
+			name:         "configured default",
+			givenOptions: []Option{WithStringMode(StringsLenient)},
+			expectedHash: "e7f6c011776e8db7cd330b54174fd76f7d0216b612387a5ffcfb81e6f0919683", // sha256("6")
+		},
+		{
+			name:         "requested mode",
+			givenMode:    StringsLenient,
+			expectedHash: "e7f6c011776e8db7cd330b54174fd76f7d0216b612387a5ffcfb81e6f0919683",
+		},
+		{
+			name:          "requested strict mode",
+			givenOptions:  []Option{WithStringMode(StringsLenient)},
+			givenMode:     StringsStrict,
+			expectedError: ErrUnsupportedValueType,
+		},
+		{
+			name:          "unknown mode",
+			givenMode:     "loose",
+			expectedError: ErrUnsupportedStringMode,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			service := NewDefaultService(zap.NewNop(), nil, tc.givenOptions...)
+			opts := SumOptions{Strings: tc.givenMode}
 
-		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-		signedToken, err := token.SignedString(jwtKey)
-		require.NoError(t, err)
+			observed, err := service.Sum(context.TODO(), data, opts)
+			streamed, streamErr := service.SumStream(context.TODO(), strings.NewReader(doc), opts)
 
-		observedErr := service.VerifyToken(context.TODO(), signedToken)
+			if tc.expectedError != nil {
+				assert.True(t, errors.Is(err, tc.expectedError), err)
+				assert.True(t, errors.Is(streamErr, tc.expectedError), streamErr)
+				return
+			}
 
-		assert.True(t, errors.Is(observedErr, ErrTokenInvalidAudience))
-	})
+			require.NoError(t, err)
+			require.NoError(t, streamErr)
 
-	t.Run("expiration is not set", func(t *testing.T) {
-		claims := Claims{
-			StandardClaims: jwt.StandardClaims{
-				Subject:  username,
-				Issuer:   "foo-issuer",
-				Audience: "foo-audience",
-			},
-		}
+			assert.Equal(t, tc.expectedHash, observed.Hash)
+			assert.Equal(t, tc.expectedHash, streamed.Hash)
+		})
+	}
+}
 
-		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-		signedToken, err := token.SignedString(jwtKey)
-		require.NoError(t, err)
+func TestDefaultService_Sum_literalPolicies(t *testing.T) {
+	t.Parallel()
 
-		observedErr := service.VerifyToken(context.TODO(), signedToken)
+	service := NewDefaultService(zap.NewNop(), nil, WithBooleanPolicy(LiteralsReject))
 
-		assert.True(t, errors.Is(observedErr, ErrTokenInvalidExpiration))
-	})
+	t.Run("server default", func(t *testing.T) {
+		_, err := service.SumStream(context.TODO(), strings.NewReader(`{"a":1,"ok":true}`), SumOptions{})
 
-	t.Run("invalid token string", func(t *testing.T) {
-		observedErr := service.VerifyToken(context.TODO(), "invalid-token-string")
-		assert.Error(t, observedErr)
+		var pathErr *PathError
+		require.True(t, errors.As(err, &pathErr), err)
+		assert.Equal(t, "$.ok", pathErr.Path)
+		assert.True(t, errors.Is(err, ErrUnsupportedValueType))
 	})
-}
-
-func TestDefaultService_Sum(t *testing.T) {
-	service := NewDefaultService(zap.NewNop(), nil)
-
-	data := []float64{1, 2, 3}
-	expectedHash := "2270ab850480a8ade7647dc3066dde96209bc0314b4847a619e1231e334c00ad"
 
-	actualHash, err := service.Sum(context.TODO(), data)
+	t.Run("requested policy", func(t *testing.T) {
+		observed, err := service.SumStream(context.TODO(), strings.NewReader(`{"a":5,"ok":true,"no":null}`), SumOptions{
+			Booleans: LiteralsNumeric,
+			Nulls:    LiteralsNumeric,
+		})
+		require.NoError(t, err)
 
-	if err != nil {
-		t.Errorf("Unexpected error: %v", err)
-	}
+		assert.Equal(t, "e7f6c011776e8db7cd330b54174fd76f7d0216b612387a5ffcfb81e6f0919683", observed.Hash) // sha256("6")
+	})
 
-	if actualHash != expectedHash {
-		t.Errorf("Unexpected hash value: got %v, want %v", actualHash, expectedHash)
-	}
+	t.Run("unknown policy", func(t *testing.T) {
+		_, err := service.Sum(context.TODO(), []any{true}, SumOptions{Nulls: "zero"})
+		assert.True(t, errors.Is(err, ErrUnsupportedLiteralPolicy), err)
+	})
 }
 
 func TestSumNumbers(t *testing.T) {
@@ -240,167 +1057,628 @@ func TestSumNumbers(t *testing.T) {
 	testCases := []struct {
 		name          string
 		data          any
-		expectedSum   float64
+		expectedSum   string
 		expectedError error
 	}{
 		{
 			name:          "float64",
 			data:          1.0,
-			expectedSum:   1.0,
+			expectedSum:   "1",
 			expectedError: nil,
 		},
 		{
 			name:        "int",
 			data:        1,
-			expectedSum: 1.0,
+			expectedSum: "1",
 		},
 		{
 			name:          "string",
 			data:          "1",
-			expectedSum:   1.0,
+			expectedSum:   "1",
 			expectedError: nil,
 		},
 		{
 			name:        "string is empty",
 			data:        "",
-			expectedSum: 0,
+			expectedSum: "0",
 		},
 		{
 			name:          "string is not a number",
 			data:          "a",
-			expectedSum:   0,
+			expectedSum:   "",
 			expectedError: ErrUnsupportedValueType,
 		},
 		{
 			name:          "bool",
 			data:          true,
-			expectedSum:   0,
+			expectedSum:   "",
 			expectedError: ErrUnsupportedValueType,
 		},
 		{
 			name:          "slice of float64",
 			data:          []float64{1.0, 3.2},
-			expectedSum:   4.2,
+			expectedSum:   "4.2",
 			expectedError: nil,
 		},
 		{
 			name:          "slice of int",
 			data:          []int{1, 2, 3, 4},
-			expectedSum:   10,
+			expectedSum:   "10",
 			expectedError: nil,
 		},
 		{
 			name:          "slice of string",
 			data:          []string{"1", "3"},
-			expectedSum:   4.0,
+			expectedSum:   "4",
 			expectedError: nil,
 		},
 		{
 			name:          "slice of bool",
 			data:          []bool{true, false},
-			expectedSum:   0,
+			expectedSum:   "",
 			expectedError: ErrUnsupportedValueType,
 		},
 		{
 			name:          "slice of mixed types",
 			data:          []any{1.0, "3"},
-			expectedSum:   4.0,
+			expectedSum:   "4",
 			expectedError: nil,
 		},
 		{
 			name:          "slice of mixed types with an error",
 			data:          []any{1.0, "a"},
-			expectedSum:   0,
+			expectedSum:   "",
 			expectedError: ErrUnsupportedValueType,
 		},
 		{
 			name:        "slice is empty",
 			data:        []any{},
-			expectedSum: 0,
+			expectedSum: "0",
 		},
 		{
 			name:        "slice contains an empty string",
 			data:        []any{""},
-			expectedSum: 0,
+			expectedSum: "0",
 		},
 		{
 			name:        "slice contains an empty slice",
 			data:        []any{[]any{}},
-			expectedSum: 0,
+			expectedSum: "0",
 		},
 		{
 			name:        "slice contains a slice with another slice",
 			data:        []any{[]any{[]any{1.0, 2.0}}},
-			expectedSum: 3.0,
+			expectedSum: "3",
 		},
 		{
 			name:          "map",
 			data:          map[string]any{"a": 6, "b": 4},
-			expectedSum:   10,
+			expectedSum:   "10",
 			expectedError: nil,
 		},
 		{
 			name:          "map is empty",
 			data:          map[string]any{},
-			expectedSum:   0,
+			expectedSum:   "0",
 			expectedError: nil,
 		},
 		{
 			name:          "nil",
 			data:          nil,
-			expectedSum:   0,
+			expectedSum:   "0",
 			expectedError: nil,
 		},
 		{
 			name:          "pointer",
 			data:          new(int),
-			expectedSum:   0,
+			expectedSum:   "",
 			expectedError: ErrUnsupportedValueType,
 		},
 		{
 			name:          "function",
 			data:          func() {},
-			expectedSum:   0,
+			expectedSum:   "",
 			expectedError: ErrUnsupportedValueType,
 		},
 		{
 			name:          "channel",
 			data:          make(chan int),
-			expectedSum:   0,
+			expectedSum:   "",
 			expectedError: ErrUnsupportedValueType,
 		},
 		{
 			name:          "interface",
 			data:          new(any),
-			expectedSum:   0,
+			expectedSum:   "",
 			expectedError: ErrUnsupportedValueType,
 		},
 		{
 			name:          "unsupported type",
 			data:          struct{}{},
-			expectedSum:   0,
+			expectedSum:   "",
 			expectedError: ErrUnsupportedValueType,
 		},
 		{
 			name:          "slice contains unsupported type",
 			data:          []any{1.0, true},
-			expectedSum:   0,
+			expectedSum:   "",
 			expectedError: ErrUnsupportedValueType,
 		},
+		{
+			name:        "json numbers are summed exactly",
+			data:        []any{json.Number("0.1"), json.Number("0.2")},
+			expectedSum: "0.3",
+		},
+		{
+			name:        "json integers beyond float64 precision",
+			data:        []any{json.Number("9007199254740993"), json.Number("0")},
+			expectedSum: "9007199254740993",
+		},
+		{
+			name:        "json numbers in exponent notation",
+			data:        map[string]any{"a": json.Number("1.5e3"), "b": json.Number("-25E-1")},
+			expectedSum: "1497.5",
+		},
+		{
+			name:          "json number out of range",
+			data:          []any{json.Number("1e100000")},
+			expectedSum:   "",
+			expectedError: ErrNumberOutOfRange,
+		},
 		{
 			name:          "map contains unsupported type",
 			data:          map[string]any{"a": 1.0, "b": true},
-			expectedSum:   0,
+			expectedSum:   "",
 			expectedError: ErrUnsupportedValueType,
 		},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			observedSum, observedErr := sumNumbers(tc.data)
+			observedSum, observedErr := sumNumbers(tc.data, sumRules{arithmetic: ArithmeticExact, strings: StringsStrict, booleans: LiteralsReject})
 
 			assert.Equal(t, tc.expectedSum, observedSum)
 			assert.True(t, errors.Is(observedErr, tc.expectedError))
 		})
 	}
 }
+
+func TestSumNumbers_floatArithmetic(t *testing.T) {
+	t.Parallel()
+
+	observedSum, err := sumNumbers([]any{json.Number("0.1"), json.Number("0.2"), "1"}, sumRules{arithmetic: ArithmeticFloat, strings: StringsStrict})
+	require.NoError(t, err)
+
+	assert.Equal(t, "1.300000", observedSum)
+}
+
+func TestSumNumbers_stringModes(t *testing.T) {
+	t.Parallel()
+
+	data := map[string]any{
+		"a": []any{json.Number("-1"), json.Number("1"), "dark"},
+		"b": []any{"2", "1e3", "0x1p-2", ""},
+		"c": []string{"3", "light"},
+	}
+
+	testCases := []struct {
+		name          string
+		givenData     any
+		givenMode     StringMode
+		expectedSum   string
+		expectedError error
+	}{
+		{
+			name:        "ignore skips every string",
+			givenData:   data,
+			givenMode:   StringsIgnore,
+			expectedSum: "0",
+		},
+		{
+			name:        "lenient sums numeric strings",
+			givenData:   data,
+			givenMode:   StringsLenient,
+			expectedSum: "1005.25",
+		},
+		{
+			name:          "lenient refuses strings beyond finite numbers",
+			givenData:     []any{json.Number("1"), "-infinity"},
+			givenMode:     StringsLenient,
+			expectedError: ErrNonFiniteResult,
+		},
+		{
+			name:          "strict refuses non-numeric strings",
+			givenData:     data,
+			givenMode:     StringsStrict,
+			expectedError: ErrUnsupportedValueType,
+		},
+		{
+			name:          "strict refuses non-numeric strings in typed slices",
+			givenData:     []string{"3", "light"},
+			givenMode:     StringsStrict,
+			expectedError: ErrUnsupportedValueType,
+		},
+		{
+			name:          "strict refuses NaN",
+			givenData:     []any{"NaN"},
+			givenMode:     StringsStrict,
+			expectedError: ErrNonFiniteResult,
+		},
+		{
+			name:        "strict sums numeric strings",
+			givenData:   []any{"2", "", json.Number("3")},
+			givenMode:   StringsStrict,
+			expectedSum: "5",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			observedSum, observedErr := sumNumbers(tc.givenData, sumRules{arithmetic: ArithmeticExact, strings: tc.givenMode})
+
+			assert.Equal(t, tc.expectedSum, observedSum)
+			assert.True(t, errors.Is(observedErr, tc.expectedError), observedErr)
+		})
+	}
+}
+
+func TestSumNumbers_literalPolicies(t *testing.T) {
+	t.Parallel()
+
+	data := map[string]any{
+		"a":  json.Number("1"),
+		"ok": true,
+		"b":  []any{false, nil, json.Number("2"), true},
+	}
+
+	testCases := []struct {
+		name          string
+		givenData     any
+		givenBooleans LiteralPolicy
+		givenNulls    LiteralPolicy
+		expectedSum   string
+		expectedError error
+		expectedPath  string
+	}{
+		{
+			name:          "ignore",
+			givenData:     data,
+			givenBooleans: LiteralsIgnore,
+			givenNulls:    LiteralsIgnore,
+			expectedSum:   "3",
+		},
+		{
+			name:          "numeric",
+			givenData:     data,
+			givenBooleans: LiteralsNumeric,
+			givenNulls:    LiteralsNumeric,
+			expectedSum:   "5",
+		},
+		{
+			name:          "numeric typed slice",
+			givenData:     []bool{true, false, true},
+			givenBooleans: LiteralsNumeric,
+			expectedSum:   "2",
+		},
+		{
+			name:          "reject booleans",
+			givenData:     data,
+			givenBooleans: LiteralsReject,
+			givenNulls:    LiteralsIgnore,
+			expectedError: ErrUnsupportedValueType,
+			expectedPath:  "$.b[0]",
+		},
+		{
+			name:          "reject nulls",
+			givenData:     data,
+			givenBooleans: LiteralsNumeric,
+			givenNulls:    LiteralsReject,
+			expectedError: ErrUnsupportedValueType,
+			expectedPath:  "$.b[1]",
+		},
+		{
+			name:          "reject the document",
+			givenData:     nil,
+			givenNulls:    LiteralsReject,
+			expectedError: ErrUnsupportedValueType,
+			expectedPath:  "$",
+		},
+		{
+			name:          "reject in a typed slice",
+			givenData:     map[string]any{"flags": []bool{true}},
+			givenBooleans: LiteralsReject,
+			expectedError: ErrUnsupportedValueType,
+			expectedPath:  "$.flags[0]",
+		},
+		{
+			name:          "reject under a quoted key",
+			givenData:     []any{map[string]any{"first name": []any{json.Number("1"), true}}},
+			givenBooleans: LiteralsReject,
+			expectedError: ErrUnsupportedValueType,
+			expectedPath:  `$[0]["first name"][1]`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			observedSum, observedErr := sumNumbers(tc.givenData, sumRules{
+				arithmetic: ArithmeticExact,
+				booleans:   tc.givenBooleans,
+				nulls:      tc.givenNulls,
+			})
+
+			assert.Equal(t, tc.expectedSum, observedSum)
+			assert.True(t, errors.Is(observedErr, tc.expectedError), observedErr)
+
+			if tc.expectedPath != "" {
+				var pathErr *PathError
+				require.True(t, errors.As(observedErr, &pathErr), observedErr)
+				assert.Equal(t, tc.expectedPath, pathErr.Path)
+				assert.True(t, strings.HasSuffix(observedErr.Error(), " at "+tc.expectedPath), observedErr)
+			}
+		})
+	}
+}
+
+func TestSumNumbers_nonFinite(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name          string
+		givenData     any
+		givenRules    sumRules
+		expectedSum   string
+		expectedError error
+		expectedPath  string
+	}{
+		{
+			name:          "NaN string",
+			givenData:     map[string]any{"a": []any{json.Number("1"), "NaN"}},
+			givenRules:    sumRules{arithmetic: ArithmeticExact, strings: StringsLenient},
+			expectedError: ErrNonFiniteResult,
+			expectedPath:  "$.a[1]",
+		},
+		{
+			name:          "infinite string with float arithmetic",
+			givenData:     []any{"+Inf"},
+			givenRules:    sumRules{arithmetic: ArithmeticFloat, strings: StringsStrict},
+			expectedError: ErrNonFiniteResult,
+			expectedPath:  "$[0]",
+		},
+		{
+			name:          "hexadecimal float beyond float64",
+			givenData:     []any{"0x1p2000"},
+			givenRules:    sumRules{arithmetic: ArithmeticExact, strings: StringsLenient},
+			expectedError: ErrNonFiniteResult,
+			expectedPath:  "$[0]",
+		},
+		{
+			name:          "number beyond float64 with float arithmetic",
+			givenData:     map[string]any{"big": json.Number("1e400")},
+			givenRules:    sumRules{arithmetic: ArithmeticFloat},
+			expectedError: ErrNonFiniteResult,
+			expectedPath:  "$.big",
+		},
+		{
+			name:        "number beyond float64 with exact arithmetic",
+			givenData:   []any{json.Number("1e400"), json.Number("-1e400"), json.Number("1")},
+			givenRules:  sumRules{arithmetic: ArithmeticExact},
+			expectedSum: "1",
+		},
+		{
+			name:          "overflowing float sum",
+			givenData:     []any{json.Number("1e308"), json.Number("1e308"), json.Number("-1e308")},
+			givenRules:    sumRules{arithmetic: ArithmeticFloat},
+			expectedError: ErrNonFiniteResult,
+			expectedPath:  "$[1]",
+		},
+		{
+			name:          "overflowing float sum in a typed slice",
+			givenData:     []float64{-1.7e308, -1.7e308},
+			givenRules:    sumRules{arithmetic: ArithmeticFloat},
+			expectedError: ErrNonFiniteResult,
+			expectedPath:  "$[1]",
+		},
+		{
+			name:        "sum beyond float64 with exact arithmetic",
+			givenData:   []any{json.Number("1e308"), json.Number("1e308")},
+			givenRules:  sumRules{arithmetic: ArithmeticExact},
+			expectedSum: "2" + strings.Repeat("0", 308),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			observedSum, observedErr := sumNumbers(tc.givenData, tc.givenRules)
+
+			assert.Equal(t, tc.expectedSum, observedSum)
+			assert.True(t, errors.Is(observedErr, tc.expectedError), observedErr)
+
+			if tc.expectedPath != "" {
+				var pathErr *PathError
+				require.True(t, errors.As(observedErr, &pathErr), observedErr)
+				assert.Equal(t, tc.expectedPath, pathErr.Path)
+			}
+		})
+	}
+}
+
+func TestSumNumbers_limits(t *testing.T) {
+	t.Parallel()
+
+	limits := SumLimits{MaxDepth: 2, MaxNodes: 5}
+
+	testCases := []struct {
+		name          string
+		data          any
+		expectedSum   string
+		expectedError error
+	}{
+		{
+			name:        "within limits",
+			data:        []any{json.Number("1"), []any{json.Number("2"), json.Number("3")}},
+			expectedSum: "6",
+		},
+		{
+			name:          "too deep",
+			data:          []any{[]any{[]any{json.Number("1")}}},
+			expectedError: ErrDocumentTooDeep,
+		},
+		{
+			name:          "too deep in an object",
+			data:          map[string]any{"a": map[string]any{"b": map[string]any{"c": json.Number("1")}}},
+			expectedError: ErrDocumentTooDeep,
+		},
+		{
+			name:          "too many values",
+			data:          []any{json.Number("1"), json.Number("2"), json.Number("3"), json.Number("4"), json.Number("5")},
+			expectedError: ErrDocumentTooLarge,
+		},
+		{
+			name:          "too many values in a typed slice",
+			data:          []int{1, 2, 3, 4, 5},
+			expectedError: ErrDocumentTooLarge,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			observedSum, observedErr := sumNumbers(tc.data, sumRules{arithmetic: ArithmeticExact, limits: limits})
+
+			assert.Equal(t, tc.expectedSum, observedSum)
+			assert.True(t, errors.Is(observedErr, tc.expectedError), observedErr)
+		})
+	}
+
+	t.Run("without limits", func(t *testing.T) {
+		var data any = json.Number("1")
+		for i := 0; i < 1000; i++ {
+			data = []any{data}
+		}
+
+		observedSum, err := sumNumbers(data, sumRules{arithmetic: ArithmeticExact})
+		require.NoError(t, err)
+		assert.Equal(t, "1", observedSum)
+	})
+}
+
+// newTestUserStore creates a user store holding the given credentials, hashed with bcrypt's minimum cost.
+func newTestUserStore(t *testing.T, creds ...Credentials) *MemoryUserStore {
+	t.Helper()
+
+	store, err := NewMemoryUserStore()
+	require.NoError(t, err)
+
+	for _, c := range creds {
+		hash, err := bcrypt.GenerateFromPassword([]byte(c.Password), bcrypt.MinCost)
+		require.NoError(t, err)
+
+		require.NoError(t, store.PutUser(User{Username: c.Username, PasswordHash: string(hash)}))
+	}
+	return store
+}
+
+// newTestClientStore creates a client store holding the given clients, hashed with bcrypt's minimum cost.
+func newTestClientStore(t *testing.T, creds ...ClientCredentials) *MemoryClientStore {
+	t.Helper()
+
+	store, err := NewMemoryClientStore()
+	require.NoError(t, err)
+
+	for _, c := range creds {
+		hash, err := bcrypt.GenerateFromPassword([]byte(c.Secret), bcrypt.MinCost)
+		require.NoError(t, err)
+
+		require.NoError(t, store.PutClient(Client{ID: c.ID, SecretHash: string(hash)}))
+	}
+	return store
+}
+
+func TestDefaultService_SumBatch(t *testing.T) {
+	t.Parallel()
+
+	docs := [][]byte{
+		[]byte(`[1, 2, 3]`),
+		[]byte(`{"a": true}`),
+		[]byte(`{"a": [0.1, 0.2]}`),
+		[]byte(`[1,`),
+		[]byte(`[]`),
+	}
+
+	for _, workers := range []int{0, 1, 2, 16} {
+		workers := workers
+
+		t.Run(fmt.Sprintf("%d workers", workers), func(t *testing.T) {
+			t.Parallel()
+
+			service := NewDefaultService(zap.NewNop(), []byte("foo-key"), WithBatchWorkers(workers))
+			opts := SumOptions{Booleans: LiteralsReject}
+
+			observed, err := service.SumBatch(context.TODO(), docs, opts)
+			require.NoError(t, err)
+			require.Len(t, observed, len(docs))
+
+			for i, doc := range docs {
+				expected, expectedErr := service.SumStream(context.TODO(), bytes.NewReader(doc), opts)
+
+				assert.Equal(t, expected, observed[i].Result, i)
+				assert.Equal(t, expectedErr, observed[i].Err, i)
+			}
+
+			var pathErr *PathError
+			require.True(t, errors.As(observed[1].Err, &pathErr), observed[1].Err)
+			assert.Equal(t, "$.a", pathErr.Path)
+
+			assert.True(t, errors.Is(observed[3].Err, ErrDocumentInvalid), observed[3].Err)
+		})
+	}
+
+	t.Run("empty batch", func(t *testing.T) {
+		service := NewDefaultService(zap.NewNop(), []byte("foo-key"))
+
+		observed, err := service.SumBatch(context.TODO(), nil, SumOptions{})
+		require.NoError(t, err)
+		assert.Empty(t, observed)
+	})
+
+	t.Run("invalid options", func(t *testing.T) {
+		service := NewDefaultService(zap.NewNop(), []byte("foo-key"))
+
+		_, err := service.SumBatch(context.TODO(), docs, SumOptions{Strings: "loose"})
+		assert.True(t, errors.Is(err, ErrUnsupportedStringMode), err)
+	})
+
+	t.Run("workers are shared by batches", func(t *testing.T) {
+		service := NewDefaultService(zap.NewNop(), []byte("foo-key"), WithBatchWorkers(1))
+
+		// Another batch holds the only worker until this one gives up.
+		require.NoError(t, service.acquireBatchSlot(context.TODO()))
+		defer service.releaseBatchSlot()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		observed, err := service.SumBatch(ctx, docs, SumOptions{})
+		require.NoError(t, err)
+
+		for _, item := range observed {
+			assert.Nil(t, item.Result)
+			assert.True(t, errors.Is(item.Err, context.DeadlineExceeded), item.Err)
+		}
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		service := NewDefaultService(zap.NewNop(), []byte("foo-key"))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		observed, err := service.SumBatch(ctx, docs, SumOptions{})
+		require.NoError(t, err)
+
+		for _, item := range observed {
+			assert.Nil(t, item.Result)
+			assert.True(t, errors.Is(item.Err, context.Canceled), item.Err)
+		}
+	})
+}
@@ -1,23 +1,73 @@
 package service
 
-import "context"
+import (
+	"context"
+	"io"
+	"time"
+)
 
 var _ Service = &MockService{}
 
 type MockService struct {
 	GenerateTokenFunc func(ctx context.Context, creds Credentials) (*Token, error)
-	VerifyTokenFunc   func(ctx context.Context, token string) error
-	SumFunc           func(ctx context.Context, data any) (string, error)
+	RefreshTokenFunc  func(ctx context.Context, refreshToken string) (*Token, error)
+	GrantFunc         func(ctx context.Context, req GrantRequest) (*Token, error)
+	VerifyTokenFunc   func(ctx context.Context, token string) (*Claims, error)
+	LogoutFunc        func(ctx context.Context, accessToken, refreshToken string) error
+	RevokeTokenIDFunc func(ctx context.Context, jti string, expiresAt time.Time) error
+	SumFunc           func(ctx context.Context, data any, opts SumOptions) (*SumResult, error)
+
+	AuthenticateClientFunc func(ctx context.Context, creds ClientCredentials) (*Client, error)
+	ProviderMetadataFunc   func(ctx context.Context) (*ProviderMetadata, error)
+	SumStreamFunc          func(ctx context.Context, r io.Reader, opts SumOptions) (*SumResult, error)
+	SumBatchFunc           func(ctx context.Context, docs [][]byte, opts SumOptions) ([]SumBatchItem, error)
+	AggregateFunc          func(ctx context.Context, r io.Reader, ops []Operation, opts SumOptions) (*AggregateResult, error)
 }
 
 func (m *MockService) GenerateToken(ctx context.Context, creds Credentials) (*Token, error) {
 	return m.GenerateTokenFunc(ctx, creds)
 }
 
-func (m *MockService) VerifyToken(ctx context.Context, token string) error {
+func (m *MockService) RefreshToken(ctx context.Context, refreshToken string) (*Token, error) {
+	return m.RefreshTokenFunc(ctx, refreshToken)
+}
+
+func (m *MockService) Grant(ctx context.Context, req GrantRequest) (*Token, error) {
+	return m.GrantFunc(ctx, req)
+}
+
+func (m *MockService) VerifyToken(ctx context.Context, token string) (*Claims, error) {
 	return m.VerifyTokenFunc(ctx, token)
 }
 
-func (m *MockService) Sum(ctx context.Context, data any) (string, error) {
-	return m.SumFunc(ctx, data)
+func (m *MockService) AuthenticateClient(ctx context.Context, creds ClientCredentials) (*Client, error) {
+	return m.AuthenticateClientFunc(ctx, creds)
+}
+
+func (m *MockService) Logout(ctx context.Context, accessToken, refreshToken string) error {
+	return m.LogoutFunc(ctx, accessToken, refreshToken)
+}
+
+func (m *MockService) RevokeTokenID(ctx context.Context, jti string, expiresAt time.Time) error {
+	return m.RevokeTokenIDFunc(ctx, jti, expiresAt)
+}
+
+func (m *MockService) Sum(ctx context.Context, data any, opts SumOptions) (*SumResult, error) {
+	return m.SumFunc(ctx, data, opts)
+}
+
+func (m *MockService) SumStream(ctx context.Context, r io.Reader, opts SumOptions) (*SumResult, error) {
+	return m.SumStreamFunc(ctx, r, opts)
+}
+
+func (m *MockService) SumBatch(ctx context.Context, docs [][]byte, opts SumOptions) ([]SumBatchItem, error) {
+	return m.SumBatchFunc(ctx, docs, opts)
+}
+
+func (m *MockService) Aggregate(ctx context.Context, r io.Reader, ops []Operation, opts SumOptions) (*AggregateResult, error) {
+	return m.AggregateFunc(ctx, r, ops, opts)
+}
+
+func (m *MockService) ProviderMetadata(ctx context.Context) (*ProviderMetadata, error) {
+	return m.ProviderMetadataFunc(ctx)
 }
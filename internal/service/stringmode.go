@@ -0,0 +1,46 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+)
+
+// StringMode selects what Sum does with the strings found in a document.
+type StringMode string
+
+const (
+	// StringsIgnore sums JSON numbers only and skips every string, so {"a":[-1,1,"2"]} sums to 0.
+	StringsIgnore StringMode = "ignore"
+
+	// StringsLenient also sums the strings that hold a number, such as "2" or "1e3", and skips the others.
+	// Strings holding NaN or an infinity fail with ErrNonFiniteResult, in this mode as in StringsStrict.
+	StringsLenient StringMode = "lenient"
+
+	// StringsStrict sums the strings that hold a number and refuses documents with any other string
+	// with ErrUnsupportedValueType. Empty strings are skipped.
+	StringsStrict StringMode = "strict"
+)
+
+// ParseStringMode parses the name of a string mode.
+func ParseStringMode(s string) (StringMode, error) {
+	switch m := StringMode(s); m {
+	case StringsIgnore, StringsLenient, StringsStrict:
+		return m, nil
+	default:
+		return "", fmt.Errorf("unknown string mode %q: %w", s, ErrUnsupportedStringMode)
+	}
+}
+
+// addString adds the number held by the string s with add, as the mode has it, and reports whether it did.
+// add fails with ErrUnsupportedValueType on strings that don't hold a number.
+func (m StringMode) addString(s string, add func(string) error) (bool, error) {
+	if m == StringsIgnore || s == "" {
+		return false, nil
+	}
+
+	err := add(s)
+	if m == StringsLenient && errors.Is(err, ErrUnsupportedValueType) {
+		return false, nil
+	}
+	return err == nil, err
+}
@@ -0,0 +1,223 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// sumStreamCheckInterval is how many tokens sumStream reads between checks of its context.
+const sumStreamCheckInterval = 1024
+
+// sumStream sums the numbers of the JSON document read from r with the exact arithmetic,
+// treating strings, booleans and nulls as rules say, token by token, without decoding the document into memory.
+// It returns the same total as sumNumbers does for the decoded document, down to duplicate keys,
+// of which only the last value counts. That takes remembering the total of every key of the objects
+// being read, so memory grows with the keys of open objects, but not with arrays or closed objects.
+// Unlike sumNumbers, it fails on invalid values even when a later duplicate key replaces them.
+func sumStream(ctx context.Context, r io.Reader, rules sumRules) (string, error) {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+
+	s := streamSummer{valueCounter: valueCounter{limits: rules.limits}, rules: rules}
+
+	for n := 0; ; n++ {
+		if n%sumStreamCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return "", fmt.Errorf("could not finish sum: %w", err)
+			}
+		}
+
+		tok, err := dec.Token()
+		if err != nil {
+			return "", documentError(err)
+		}
+
+		total, done, err := s.feed(tok)
+		if err != nil {
+			return "", err
+		}
+
+		if done {
+			if err := documentEnd(dec); err != nil {
+				return "", err
+			}
+			return total.String(), nil
+		}
+	}
+}
+
+// documentError tells malformed documents apart from the errors of their reader.
+func documentError(err error) error {
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return fmt.Errorf("could not read document: %v: %w", err, ErrDocumentInvalid)
+	}
+	return fmt.Errorf("could not read document: %w", err)
+}
+
+// documentEnd checks that nothing but whitespace follows the document read by dec.
+func documentEnd(dec *json.Decoder) error {
+	_, err := dec.Token()
+	switch {
+	case err == nil:
+		return fmt.Errorf("could not read document: data after its end: %w", ErrDocumentInvalid)
+	case errors.Is(err, io.EOF):
+		return nil
+	default:
+		return documentError(err)
+	}
+}
+
+// streamSummer sums the tokens of a document.
+type streamSummer struct {
+	valueCounter
+	rules sumRules
+
+	// frames are the arrays and objects being read, innermost last.
+	frames []*streamFrame
+}
+
+// streamFrame is an array or an object being read.
+type streamFrame struct {
+	// sum is the total of the values of an array.
+	sum decimal
+
+	// members are the totals of the members of an object, by key. It is nil for arrays.
+	members map[string]decimal
+
+	// index is the index of the value being read in an array.
+	index int
+
+	// key is the key of the member being read, once hasKey is set.
+	key    string
+	hasKey bool
+}
+
+func (f *streamFrame) total() decimal {
+	if f.members == nil {
+		return f.sum
+	}
+
+	total := newDecimal()
+	for _, v := range f.members {
+		total = total.add(v)
+	}
+	return total
+}
+
+// feed reads the next token of the document, and returns its total once the document is over.
+func (s *streamSummer) feed(tok json.Token) (decimal, bool, error) {
+	var top *streamFrame
+	if len(s.frames) > 0 {
+		top = s.frames[len(s.frames)-1]
+	}
+
+	// Strings in an object alternate between keys and values.
+	if key, ok := tok.(string); ok && top != nil && top.members != nil && !top.hasKey {
+		top.key, top.hasKey = key, true
+		return decimal{}, false, nil
+	}
+
+	if delim, ok := tok.(json.Delim); ok && (delim == ']' || delim == '}') {
+		s.frames = s.frames[:len(s.frames)-1]
+		return s.value(top.total())
+	}
+
+	if err := s.visit(1, len(s.frames)); err != nil {
+		return decimal{}, false, err
+	}
+
+	if delim, ok := tok.(json.Delim); ok {
+		frame := streamFrame{sum: newDecimal()}
+		if delim == '{' {
+			frame.members = make(map[string]decimal)
+		}
+		s.frames = append(s.frames, &frame)
+		return decimal{}, false, nil
+	}
+
+	if s.rules.filter != nil && !s.rules.filter.selects(s.path()) {
+		return s.value(newDecimal())
+	}
+
+	num, err := s.number(tok)
+	if err != nil {
+		return decimal{}, false, &PathError{Path: jsonPath(s.path()), Err: err}
+	}
+	return s.value(num)
+}
+
+// number returns the number a scalar token stands for, which is zero for the values that are skipped.
+func (s *streamSummer) number(tok json.Token) (decimal, error) {
+	num := newDecimal()
+	add := func(literal string) error {
+		n, err := parseExactNumber(literal)
+		if err == nil {
+			num = n
+		}
+		return err
+	}
+
+	var err error
+	switch val := tok.(type) {
+	case json.Number:
+		err = add(val.String())
+
+	case string:
+		_, err = s.rules.strings.addString(val, add)
+
+	case bool:
+		err = s.literal(s.rules.booleans, val, add)
+
+	case nil:
+		err = s.literal(s.rules.nulls, nil, add)
+
+	default:
+		err = ErrUnsupportedValueType
+	}
+	return num, err
+}
+
+// literal adds the number a boolean or null stands for under policy.
+func (s *streamSummer) literal(policy LiteralPolicy, v any, add func(string) error) error {
+	num, err := policy.number(v)
+	if err != nil || num == "" {
+		return err
+	}
+	return add(num)
+}
+
+// path returns the path to the value being read.
+func (s *streamSummer) path() []pathStep {
+	path := make([]pathStep, 0, len(s.frames))
+	for _, f := range s.frames {
+		if f.members == nil {
+			path = append(path, indexStep(f.index))
+		} else {
+			path = append(path, keyStep(f.key))
+		}
+	}
+	return path
+}
+
+// value adds the total of a value to the array or object it belongs to,
+// or returns it when it is the whole document.
+func (s *streamSummer) value(total decimal) (decimal, bool, error) {
+	if len(s.frames) == 0 {
+		return total, true, nil
+	}
+
+	top := s.frames[len(s.frames)-1]
+	if top.members == nil {
+		top.sum = top.sum.add(total)
+		top.index++
+		return decimal{}, false, nil
+	}
+
+	top.members[top.key] = total
+	top.hasKey = false
+	return decimal{}, false, nil
+}
@@ -0,0 +1,215 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestSumStream(t *testing.T) {
+	t.Parallel()
+
+	// Every document must sum to what sumNumbers finds in it once decoded.
+	documents := []string{
+		`[1,2,3,4]`,
+		`{"a":6,"b":4}`,
+		`[[[2]]]`,
+		`{"a":{"b":4},"c":-2}`,
+		`{"a":[-1,1,"2"]}`,
+		`[-1,{"a":1,"b":"3"}]`,
+		`[]`,
+		`{}`,
+		`null`,
+		`"5"`,
+		`1.5`,
+		`["", null, [], {}]`,
+		`[0.1, 0.2, "0x1p-2"]`,
+		`{"a":[-1,1,"dark"],"b":["NaN","Inf"," 1"]}`,
+		`[9007199254740993, 1e-30, -1E+2]`,
+		`{"a":1,"a":2}`,
+		`{"a":{"x":1},"b":[2,3],"a":[4,{"a":5,"a":6}]}`,
+		`{"a":1,"b":[2,null,false],"ok":true}`,
+		`[[1,{"first name":[true]}]]`,
+		`null`,
+		`false`,
+	}
+
+	rules := []sumRules{
+		{strings: StringsIgnore, booleans: LiteralsIgnore, nulls: LiteralsIgnore},
+		{strings: StringsLenient, booleans: LiteralsNumeric, nulls: LiteralsNumeric},
+		{strings: StringsStrict, booleans: LiteralsReject, nulls: LiteralsReject},
+	}
+
+	for _, r := range rules {
+		r.arithmetic = ArithmeticExact
+
+		for _, doc := range documents {
+			t.Run(fmt.Sprintf("%s/%s/%s %s", r.strings, r.booleans, r.nulls, doc), func(t *testing.T) {
+				dec := json.NewDecoder(strings.NewReader(doc))
+				dec.UseNumber()
+
+				var data any
+				require.NoError(t, dec.Decode(&data))
+
+				expected, expectedErr := sumNumbers(data, r)
+
+				observed, observedErr := sumStream(context.TODO(), strings.NewReader(doc), r)
+
+				assert.Equal(t, expected, observed)
+				assert.Equal(t, expectedErr == nil, observedErr == nil, observedErr)
+
+				var expectedPathErr, observedPathErr *PathError
+				if errors.As(expectedErr, &expectedPathErr) {
+					require.True(t, errors.As(observedErr, &observedPathErr), observedErr)
+					assert.Equal(t, expectedPathErr.Path, observedPathErr.Path)
+				}
+			})
+		}
+	}
+}
+
+func TestSumStream_errors(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name          string
+		givenDocument string
+		givenRules    sumRules
+		expectedError error
+	}{
+		{
+			name:          "empty document",
+			givenDocument: ``,
+			expectedError: ErrDocumentInvalid,
+		},
+		{
+			name:          "truncated document",
+			givenDocument: `[1, 2`,
+			expectedError: ErrDocumentInvalid,
+		},
+		{
+			name:          "malformed document",
+			givenDocument: `{"a" 1}`,
+			expectedError: ErrDocumentInvalid,
+		},
+		{
+			name:          "data after the document",
+			givenDocument: `[1, 2] 3`,
+			expectedError: ErrDocumentInvalid,
+		},
+		{
+			name:          "malformed data after the document",
+			givenDocument: `[1, 2] ]`,
+			expectedError: ErrDocumentInvalid,
+		},
+		{
+			name:          "boolean",
+			givenDocument: `[1, true]`,
+			givenRules:    sumRules{booleans: LiteralsReject},
+			expectedError: ErrUnsupportedValueType,
+		},
+		{
+			name:          "non-numeric string",
+			givenDocument: `{"a": "dark"}`,
+			givenRules:    sumRules{strings: StringsStrict},
+			expectedError: ErrUnsupportedValueType,
+		},
+		{
+			name:          "too deep",
+			givenDocument: `[[[1]]]`,
+			givenRules:    sumRules{limits: SumLimits{MaxDepth: 2}},
+			expectedError: ErrDocumentTooDeep,
+		},
+		{
+			name:          "too many values",
+			givenDocument: `{"a": [1, 2], "b": 3}`,
+			givenRules:    sumRules{limits: SumLimits{MaxNodes: 4}},
+			expectedError: ErrDocumentTooLarge,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := sumStream(context.TODO(), strings.NewReader(tc.givenDocument), tc.givenRules)
+			assert.True(t, errors.Is(err, tc.expectedError), err)
+		})
+	}
+}
+
+func TestSumStream_cancel(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+
+	// An endless array, cancelled once the sum is well under way.
+	r := &endlessArray{afterReads: 100, cancel: cancel}
+
+	_, err := sumStream(ctx, r, sumRules{})
+	assert.True(t, errors.Is(err, context.Canceled), err)
+}
+
+// endlessArray reads as "[1,1,1,..." forever, calling cancel after afterReads reads.
+type endlessArray struct {
+	reads      int
+	afterReads int
+	cancel     context.CancelFunc
+}
+
+func (r *endlessArray) Read(p []byte) (int, error) {
+	r.reads++
+	if r.reads == r.afterReads {
+		r.cancel()
+	}
+
+	n := 0
+	if r.reads == 1 {
+		p[0] = '['
+		n++
+	}
+
+	for ; n+1 < len(p); n += 2 {
+		p[n], p[n+1] = '1', ','
+	}
+	return n, nil
+}
+
+func TestDefaultService_SumStream(t *testing.T) {
+	t.Parallel()
+
+	doc := `{"a": [0.1, 0.2], "b": "3", "c": {"d": 1e2}}`
+
+	for _, arithmetic := range []Arithmetic{ArithmeticExact, ArithmeticFloat} {
+		t.Run(string(arithmetic), func(t *testing.T) {
+			service := NewDefaultService(zap.NewNop(), []byte("foo-key"), WithArithmetic(arithmetic))
+
+			dec := json.NewDecoder(strings.NewReader(doc))
+			dec.UseNumber()
+
+			var data any
+			require.NoError(t, dec.Decode(&data))
+
+			expected, err := service.Sum(context.TODO(), data, SumOptions{})
+			require.NoError(t, err)
+
+			observed, err := service.SumStream(context.TODO(), strings.NewReader(doc), SumOptions{})
+			require.NoError(t, err)
+
+			assert.Equal(t, expected, observed)
+		})
+	}
+
+	t.Run("malformed document", func(t *testing.T) {
+		service := NewDefaultService(zap.NewNop(), []byte("foo-key"), WithArithmetic(ArithmeticFloat))
+
+		_, err := service.SumStream(context.TODO(), strings.NewReader(`{`), SumOptions{})
+		assert.True(t, errors.Is(err, ErrDocumentInvalid), err)
+	})
+}
@@ -0,0 +1,67 @@
+package service
+
+import "time"
+
+// TokenOptions tunes the access tokens a DefaultService issues and accepts.
+type TokenOptions struct {
+	// Issuer is stamped in the iss claim of issued tokens and required of verified ones.
+	Issuer string
+
+	// Audience is stamped in the aud claim of issued tokens.
+	Audience string
+
+	// AcceptedAudiences are the audiences verified tokens may have besides Audience,
+	// such as the previous audience while renaming it.
+	AcceptedAudiences []string
+
+	// TTL is how long access tokens live.
+	TTL time.Duration
+
+	// ClientTTLs override TTL for the tokens issued to the given client IDs.
+	ClientTTLs map[string]time.Duration
+
+	// Leeway is the clock drift tolerated when checking the time claims of verified tokens.
+	Leeway time.Duration
+}
+
+// DefaultTokenOptions returns the options used unless WithTokenOptions says otherwise.
+func DefaultTokenOptions() TokenOptions {
+	return TokenOptions{
+		Issuer:   "foo-issuer",
+		Audience: "foo-audience",
+		TTL:      time.Hour,
+	}
+}
+
+// ttl returns how long the tokens issued to clientID live.
+func (o *TokenOptions) ttl(clientID string) time.Duration {
+	if ttl, ok := o.ClientTTLs[clientID]; ok && clientID != "" {
+		return ttl
+	}
+	return o.TTL
+}
+
+// maxTTL returns the longest any token issued now could live.
+func (o *TokenOptions) maxTTL() time.Duration {
+	longest := o.TTL
+	for _, ttl := range o.ClientTTLs {
+		if ttl > longest {
+			longest = ttl
+		}
+	}
+	return longest
+}
+
+// acceptsAudience reports whether verified tokens may have the audience aud.
+func (o *TokenOptions) acceptsAudience(aud string) bool {
+	if aud == o.Audience {
+		return true
+	}
+
+	for _, accepted := range o.AcceptedAudiences {
+		if aud == accepted {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,34 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenOptions_ttl(t *testing.T) {
+	t.Parallel()
+
+	opts := TokenOptions{
+		TTL:        time.Hour,
+		ClientTTLs: map[string]time.Duration{"foo-client": 2 * time.Hour, "bar-client": time.Minute},
+	}
+
+	assert.Equal(t, time.Hour, opts.ttl(""))
+	assert.Equal(t, time.Hour, opts.ttl("baz-client"))
+	assert.Equal(t, 2*time.Hour, opts.ttl("foo-client"))
+	assert.Equal(t, time.Minute, opts.ttl("bar-client"))
+	assert.Equal(t, 2*time.Hour, opts.maxTTL())
+}
+
+func TestTokenOptions_acceptsAudience(t *testing.T) {
+	t.Parallel()
+
+	opts := TokenOptions{Audience: "foo", AcceptedAudiences: []string{"bar"}}
+
+	assert.True(t, opts.acceptsAudience("foo"))
+	assert.True(t, opts.acceptsAudience("bar"))
+	assert.False(t, opts.acceptsAudience("baz"))
+	assert.False(t, opts.acceptsAudience(""))
+}
@@ -0,0 +1,144 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// UserStore looks up the users allowed to request tokens.
+type UserStore interface {
+	// User returns the user registered under username, or ErrUserNotFound.
+	User(ctx context.Context, username string) (*User, error)
+}
+
+// User is a registered user.
+type User struct {
+	Username string
+
+	// PasswordHash is either a bcrypt hash ($2a$, $2b$ or $2y$)
+	// or an argon2id hash in PHC string format ($argon2id$v=19$m=...,t=...,p=...$salt$hash).
+	PasswordHash string
+
+	// Scopes are granted to the tokens of the user. Empty grants the service's default scopes.
+	Scopes []string
+
+	// Claims are added to the tokens of the user, so that relying parties can learn e.g. its tenant,
+	// roles or email without looking it up. They can't use the names of the registered claims.
+	Claims map[string]any
+}
+
+var errUnsupportedPasswordHash = errors.New("unsupported password hash")
+
+var (
+	// dummyHash is compared against when a user doesn't exist,
+	// so that unknown usernames take as long to reject as wrong passwords.
+	dummyHash     []byte
+	dummyHashOnce sync.Once
+)
+
+// checkPasswordHash reports whether hash has a format verifyPassword understands.
+func checkPasswordHash(hash string) error {
+	switch {
+	case isBcryptHash(hash):
+		_, err := bcrypt.Cost([]byte(hash))
+		return err
+	case strings.HasPrefix(hash, "$argon2id$"):
+		_, err := parseArgon2idHash(hash)
+		return err
+	default:
+		return errUnsupportedPasswordHash
+	}
+}
+
+// verifyPassword reports whether password matches hash.
+func verifyPassword(hash, password string) (bool, error) {
+	switch {
+	case isBcryptHash(hash):
+		err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, nil
+		}
+		return err == nil, err
+
+	case strings.HasPrefix(hash, "$argon2id$"):
+		params, err := parseArgon2idHash(hash)
+		if err != nil {
+			return false, err
+		}
+
+		key := argon2.IDKey([]byte(password), params.salt, params.time, params.memory, params.threads, uint32(len(params.key)))
+		return subtle.ConstantTimeCompare(key, params.key) == 1, nil
+
+	default:
+		return false, errUnsupportedPasswordHash
+	}
+}
+
+// burnPasswordCheck spends the time of a password verification without checking anything.
+func burnPasswordCheck(password string) {
+	dummyHashOnce.Do(func() {
+		dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy-password"), bcrypt.DefaultCost)
+	})
+	_ = bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+}
+
+func isBcryptHash(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+// maxArgon2idMemory caps the memory of the argon2id hashes accepted, in KiB,
+// so that a stored hash can't make every login of its user allocate gigabytes.
+const maxArgon2idMemory = 1 << 20
+
+type argon2idParams struct {
+	memory  uint32
+	time    uint32
+	threads uint8
+	salt    []byte
+	key     []byte
+}
+
+func parseArgon2idHash(hash string) (*argon2idParams, error) {
+	// "", "argon2id", "v=19", "m=65536,t=3,p=4", salt, key
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return nil, fmt.Errorf("could not parse argon2id hash: %w", errUnsupportedPasswordHash)
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return nil, fmt.Errorf("could not parse argon2id version %q: %w", parts[2], errUnsupportedPasswordHash)
+	}
+
+	var params argon2idParams
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.time, &params.threads); err != nil {
+		return nil, fmt.Errorf("could not parse argon2id parameters %q: %w", parts[3], errUnsupportedPasswordHash)
+	}
+
+	// argon2.IDKey panics on fewer rounds, threads or memory than these.
+	if params.time < 1 || params.threads < 1 || params.memory < 8*uint32(params.threads) || params.memory > maxArgon2idMemory {
+		return nil, fmt.Errorf("could not accept argon2id parameters %q: %w", parts[3], errUnsupportedPasswordHash)
+	}
+
+	var err error
+	if params.salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return nil, fmt.Errorf("could not decode argon2id salt: %w", err)
+	}
+
+	if params.key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil {
+		return nil, fmt.Errorf("could not decode argon2id key: %w", err)
+	}
+
+	if len(params.key) == 0 {
+		return nil, fmt.Errorf("could not parse argon2id hash without key: %w", errUnsupportedPasswordHash)
+	}
+	return &params, nil
+}
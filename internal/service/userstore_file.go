@@ -0,0 +1,116 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+var _ UserStore = &FileUserStore{}
+
+// FileUserStore reads users from an htpasswd-style file.
+// Each line holds "username:hash" where hash is a bcrypt or argon2id hash,
+// optionally followed by ":scopes", a space-delimited list of the scopes granted to the user,
+// and ":claims", a JSON object of the custom claims added to the tokens of the user.
+// Blank lines and lines starting with # are ignored.
+type FileUserStore struct {
+	path string
+
+	mu    sync.RWMutex
+	users map[string]User
+}
+
+// NewFileUserStore creates a FileUserStore and loads the users from path.
+func NewFileUserStore(path string) (*FileUserStore, error) {
+	s := FileUserStore{path: path}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// User returns the user registered under username.
+func (s *FileUserStore) User(_ context.Context, username string) (*User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	u, ok := s.users[username]
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+	return &u, nil
+}
+
+// Reload reads the file again, replacing the users in memory only if the whole file is valid.
+func (s *FileUserStore) Reload() error {
+	entries, err := readHashFile(s.path)
+	if err != nil {
+		return fmt.Errorf("could not load users file: %w", err)
+	}
+
+	users := make(map[string]User, len(entries))
+	for username, e := range entries {
+		users[username] = User{Username: username, PasswordHash: e.hash, Scopes: e.scopes, Claims: e.claims}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.users = users
+	return nil
+}
+
+// hashFileEntry is a line of a file read by readHashFile.
+type hashFileEntry struct {
+	hash   string
+	scopes []string
+	claims map[string]any
+}
+
+// readHashFile reads an htpasswd-style file of "name:hash[:scopes[:claims]]" lines into a map keyed by name.
+// Blank lines and lines starting with # are ignored.
+func readHashFile(path string) (map[string]hashFileEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open file: %w", err)
+	}
+	defer f.Close()
+
+	entries := make(map[string]hashFileEntry)
+
+	scanner := bufio.NewScanner(f)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		name, rest, ok := strings.Cut(line, ":")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("could not parse line %d: missing name", lineNo)
+		}
+
+		// Neither bcrypt nor argon2id hashes nor scopes contain colons, but the claims may.
+		hash, rest, _ := strings.Cut(rest, ":")
+		scopes, rawClaims, _ := strings.Cut(rest, ":")
+
+		if err := checkPasswordHash(hash); err != nil {
+			return nil, fmt.Errorf("could not parse line %d: %w", lineNo, err)
+		}
+
+		claims, err := parseCustomClaims(rawClaims)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse line %d: %w", lineNo, err)
+		}
+
+		entries[name] = hashFileEntry{hash: hash, scopes: ParseScopes(scopes), claims: claims}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("could not read file: %w", err)
+	}
+	return entries, nil
+}
@@ -0,0 +1,91 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileUserStore(t *testing.T) {
+	t.Parallel()
+
+	argon2idHash := testArgon2idHash("bar")
+
+	path := filepath.Join(t.TempDir(), "users")
+	content := "# users allowed to log in\n" +
+		"\n" +
+		"foo:" + argon2idHash + "\n" +
+		"qux:$2y$04$nEivM4Crnf2k4gxPF0hOvePdTMEDQgJ2HZFVoQoMFOsMiZn/.6ic6:sum read\n" +
+		"quux:" + argon2idHash + "::" + `{"tenant":"acme","website":"https://acme.example.com"}` + "\n"
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+	store, err := NewFileUserStore(path)
+	require.NoError(t, err)
+
+	observed, err := store.User(context.TODO(), "foo")
+	require.NoError(t, err)
+	assert.Equal(t, &User{Username: "foo", PasswordHash: argon2idHash}, observed)
+
+	observed, err = store.User(context.TODO(), "qux")
+	require.NoError(t, err)
+	assert.Equal(t, "qux", observed.Username)
+	assert.Equal(t, []string{"sum", "read"}, observed.Scopes)
+
+	observed, err = store.User(context.TODO(), "quux")
+	require.NoError(t, err)
+	assert.Nil(t, observed.Scopes)
+	assert.Equal(t, map[string]any{"tenant": "acme", "website": "https://acme.example.com"}, observed.Claims)
+
+	_, err = store.User(context.TODO(), "baz")
+	assert.True(t, errors.Is(err, ErrUserNotFound))
+}
+
+func TestFileUserStore_invalidFile(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name    string
+		content string
+	}{
+		{
+			name:    "missing separator",
+			content: "foo\n",
+		},
+		{
+			name:    "missing username",
+			content: ":" + testArgon2idHash("bar") + "\n",
+		},
+		{
+			name:    "unsupported hash",
+			content: "foo:{SHA}Ys23Ag/5IOWqZCw9QGaVDdHwH00=\n",
+		},
+		{
+			name:    "invalid claims",
+			content: "foo:" + testArgon2idHash("bar") + ":sum:{tenant}\n",
+		},
+		{
+			name:    "reserved claim",
+			content: "foo:" + testArgon2idHash("bar") + `:sum:{"iss":"evil"}` + "\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "users")
+			require.NoError(t, os.WriteFile(path, []byte(tc.content), 0o600))
+
+			_, err := NewFileUserStore(path)
+			assert.Error(t, err)
+		})
+	}
+
+	t.Run("missing file", func(t *testing.T) {
+		_, err := NewFileUserStore(filepath.Join(t.TempDir(), "missing"))
+		assert.Error(t, err)
+	})
+}
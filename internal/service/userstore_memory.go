@@ -0,0 +1,58 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+var _ UserStore = &MemoryUserStore{}
+
+// MemoryUserStore keeps users in memory.
+type MemoryUserStore struct {
+	mu    sync.RWMutex
+	users map[string]User
+}
+
+// NewMemoryUserStore creates a MemoryUserStore holding the given users.
+func NewMemoryUserStore(users ...User) (*MemoryUserStore, error) {
+	s := MemoryUserStore{
+		users: make(map[string]User, len(users)),
+	}
+
+	for _, u := range users {
+		if err := s.PutUser(u); err != nil {
+			return nil, err
+		}
+	}
+	return &s, nil
+}
+
+// User returns the user registered under username.
+func (s *MemoryUserStore) User(_ context.Context, username string) (*User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	u, ok := s.users[username]
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+	return &u, nil
+}
+
+// PutUser adds or replaces a user.
+func (s *MemoryUserStore) PutUser(u User) error {
+	if err := checkPasswordHash(u.PasswordHash); err != nil {
+		return fmt.Errorf("could not add user %q: %w", u.Username, err)
+	}
+
+	if err := checkCustomClaims(u.Claims); err != nil {
+		return fmt.Errorf("could not add user %q: %w", u.Username, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.users[u.Username] = u
+	return nil
+}
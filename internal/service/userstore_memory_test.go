@@ -0,0 +1,41 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryUserStore(t *testing.T) {
+	t.Parallel()
+
+	hash := testArgon2idHash("bar")
+
+	store, err := NewMemoryUserStore(User{Username: "foo", PasswordHash: hash})
+	require.NoError(t, err)
+
+	t.Run("existing user", func(t *testing.T) {
+		observed, err := store.User(context.TODO(), "foo")
+		require.NoError(t, err)
+
+		assert.Equal(t, &User{Username: "foo", PasswordHash: hash}, observed)
+	})
+
+	t.Run("unknown user", func(t *testing.T) {
+		_, err := store.User(context.TODO(), "baz")
+		assert.True(t, errors.Is(err, ErrUserNotFound))
+	})
+
+	t.Run("invalid hash", func(t *testing.T) {
+		err := store.PutUser(User{Username: "baz", PasswordHash: "plain-text"})
+		assert.True(t, errors.Is(err, errUnsupportedPasswordHash))
+	})
+
+	t.Run("reserved claim", func(t *testing.T) {
+		err := store.PutUser(User{Username: "baz", PasswordHash: hash, Claims: map[string]any{"scope": "admin"}})
+		assert.True(t, errors.Is(err, errReservedClaim))
+	})
+}
@@ -0,0 +1,113 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+var _ UserStore = &SQLiteUserStore{}
+
+const createUsersTable = `CREATE TABLE IF NOT EXISTS users (
+	username      TEXT PRIMARY KEY,
+	password_hash TEXT NOT NULL,
+	scopes        TEXT NOT NULL DEFAULT '',
+	claims        TEXT NOT NULL DEFAULT ''
+)`
+
+// usersMigrations add the columns missing from users tables created by earlier releases, in order.
+var usersMigrations = []struct {
+	column string
+	ddl    string
+}{
+	{column: "scopes", ddl: `ALTER TABLE users ADD COLUMN scopes TEXT NOT NULL DEFAULT ''`},
+	{column: "claims", ddl: `ALTER TABLE users ADD COLUMN claims TEXT NOT NULL DEFAULT ''`},
+}
+
+// SQLiteUserStore reads users from the users table of a SQLite database.
+// Scopes are stored space-delimited and custom claims as a JSON object.
+// The caller opens the database with the driver of its choice.
+type SQLiteUserStore struct {
+	db *sql.DB
+}
+
+// NewSQLiteUserStore creates a SQLiteUserStore, creating the users table if needed.
+func NewSQLiteUserStore(ctx context.Context, db *sql.DB) (*SQLiteUserStore, error) {
+	if _, err := db.ExecContext(ctx, createUsersTable); err != nil {
+		return nil, fmt.Errorf("could not create users table: %w", err)
+	}
+
+	for _, m := range usersMigrations {
+		var hasColumn bool
+		err := db.QueryRowContext(ctx,
+			`SELECT COUNT(*) > 0 FROM pragma_table_info('users') WHERE name = ?`,
+			m.column,
+		).Scan(&hasColumn)
+		if err != nil {
+			return nil, fmt.Errorf("could not inspect users table: %w", err)
+		}
+
+		if !hasColumn {
+			if _, err := db.ExecContext(ctx, m.ddl); err != nil {
+				return nil, fmt.Errorf("could not add %s to users table: %w", m.column, err)
+			}
+		}
+	}
+	return &SQLiteUserStore{db: db}, nil
+}
+
+// User returns the user registered under username.
+func (s *SQLiteUserStore) User(ctx context.Context, username string) (*User, error) {
+	var (
+		u      User
+		scopes string
+		claims string
+	)
+
+	err := s.db.QueryRowContext(ctx,
+		`SELECT username, password_hash, scopes, claims FROM users WHERE username = ?`,
+		username,
+	).Scan(&u.Username, &u.PasswordHash, &scopes, &claims)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrUserNotFound
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("could not query user: %w", err)
+	}
+
+	u.Scopes = ParseScopes(scopes)
+
+	if u.Claims, err = parseCustomClaims(claims); err != nil {
+		return nil, fmt.Errorf("could not read claims of user %q: %w", username, err)
+	}
+	return &u, nil
+}
+
+// PutUser adds or replaces a user.
+func (s *SQLiteUserStore) PutUser(ctx context.Context, u User) error {
+	if err := checkPasswordHash(u.PasswordHash); err != nil {
+		return fmt.Errorf("could not add user %q: %w", u.Username, err)
+	}
+
+	if err := checkCustomClaims(u.Claims); err != nil {
+		return fmt.Errorf("could not add user %q: %w", u.Username, err)
+	}
+
+	claims, err := formatCustomClaims(u.Claims)
+	if err != nil {
+		return fmt.Errorf("could not add user %q: %w", u.Username, err)
+	}
+
+	_, err = s.db.ExecContext(ctx,
+		`INSERT INTO users (username, password_hash, scopes, claims) VALUES (?, ?, ?, ?)
+		ON CONFLICT (username) DO UPDATE SET
+			password_hash = excluded.password_hash, scopes = excluded.scopes, claims = excluded.claims`,
+		u.Username, u.PasswordHash, FormatScopes(u.Scopes), claims,
+	)
+	if err != nil {
+		return fmt.Errorf("could not upsert user: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	_ "modernc.org/sqlite"
+)
+
+func TestSQLiteUserStore(t *testing.T) {
+	t.Parallel()
+
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "users.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	store, err := NewSQLiteUserStore(context.TODO(), db)
+	require.NoError(t, err)
+
+	hash := testArgon2idHash("bar")
+	require.NoError(t, store.PutUser(context.TODO(), User{Username: "foo", PasswordHash: "$2y$04$nEivM4Crnf2k4gxPF0hOvePdTMEDQgJ2HZFVoQoMFOsMiZn/.6ic6"}))
+	require.NoError(t, store.PutUser(context.TODO(), User{Username: "foo", PasswordHash: hash})) // replaces the first hash
+
+	observed, err := store.User(context.TODO(), "foo")
+	require.NoError(t, err)
+	assert.Equal(t, &User{Username: "foo", PasswordHash: hash}, observed)
+
+	_, err = store.User(context.TODO(), "baz")
+	assert.True(t, errors.Is(err, ErrUserNotFound))
+
+	err = store.PutUser(context.TODO(), User{Username: "baz", PasswordHash: "plain-text"})
+	assert.True(t, errors.Is(err, errUnsupportedPasswordHash))
+
+	require.NoError(t, store.PutUser(context.TODO(), User{Username: "qux", PasswordHash: hash, Scopes: []string{"sum", "read"}}))
+
+	observed, err = store.User(context.TODO(), "qux")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"sum", "read"}, observed.Scopes)
+
+	claims := map[string]any{"tenant": "acme", "roles": []any{"admin"}}
+	require.NoError(t, store.PutUser(context.TODO(), User{Username: "quux", PasswordHash: hash, Claims: claims}))
+
+	observed, err = store.User(context.TODO(), "quux")
+	require.NoError(t, err)
+	assert.Equal(t, claims, observed.Claims)
+
+	err = store.PutUser(context.TODO(), User{Username: "quux", PasswordHash: hash, Claims: map[string]any{"sub": "root"}})
+	assert.True(t, errors.Is(err, errReservedClaim))
+}
+
+func TestSQLiteUserStore_migratesScopes(t *testing.T) {
+	t.Parallel()
+
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "users.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	// The users table as created before scopes and claims existed.
+	_, err = db.Exec(`CREATE TABLE users (username TEXT PRIMARY KEY, password_hash TEXT NOT NULL)`)
+	require.NoError(t, err)
+
+	hash := testArgon2idHash("bar")
+	_, err = db.Exec(`INSERT INTO users (username, password_hash) VALUES (?, ?)`, "foo", hash)
+	require.NoError(t, err)
+
+	store, err := NewSQLiteUserStore(context.TODO(), db)
+	require.NoError(t, err)
+
+	observed, err := store.User(context.TODO(), "foo")
+	require.NoError(t, err)
+	assert.Equal(t, &User{Username: "foo", PasswordHash: hash}, observed)
+
+	// Opening the store again doesn't migrate twice.
+	_, err = NewSQLiteUserStore(context.TODO(), db)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,133 @@
+package service
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestVerifyPassword(t *testing.T) {
+	t.Parallel()
+
+	bcryptHash, err := bcrypt.GenerateFromPassword([]byte("bar"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	argon2idHash := testArgon2idHash("bar")
+
+	testCases := []struct {
+		name          string
+		hash          string
+		password      string
+		expected      bool
+		expectedError error
+	}{
+		{
+			name:     "bcrypt match",
+			hash:     string(bcryptHash),
+			password: "bar",
+			expected: true,
+		},
+		{
+			name:     "bcrypt mismatch",
+			hash:     string(bcryptHash),
+			password: "baz",
+			expected: false,
+		},
+		{
+			name:     "htpasswd bcrypt prefix",
+			hash:     "$2y$" + string(bcryptHash[4:]),
+			password: "bar",
+			expected: true,
+		},
+		{
+			name:     "argon2id match",
+			hash:     argon2idHash,
+			password: "bar",
+			expected: true,
+		},
+		{
+			name:     "argon2id mismatch",
+			hash:     argon2idHash,
+			password: "baz",
+			expected: false,
+		},
+		{
+			name:          "argon2id with wrong version",
+			hash:          "$argon2id$v=16$m=64,t=1,p=1$c2FsdA$a2V5",
+			password:      "bar",
+			expectedError: errUnsupportedPasswordHash,
+		},
+		{
+			name:          "argon2id without rounds",
+			hash:          "$argon2id$v=19$m=64,t=0,p=1$c2FsdA$a2V5",
+			password:      "bar",
+			expectedError: errUnsupportedPasswordHash,
+		},
+		{
+			name:          "argon2id without threads",
+			hash:          "$argon2id$v=19$m=64,t=1,p=0$c2FsdA$a2V5",
+			password:      "bar",
+			expectedError: errUnsupportedPasswordHash,
+		},
+		{
+			name:          "argon2id with too little memory for its threads",
+			hash:          "$argon2id$v=19$m=15,t=1,p=2$c2FsdA$a2V5",
+			password:      "bar",
+			expectedError: errUnsupportedPasswordHash,
+		},
+		{
+			name:          "argon2id with too much memory",
+			hash:          "$argon2id$v=19$m=4194304,t=1,p=1$c2FsdA$a2V5",
+			password:      "bar",
+			expectedError: errUnsupportedPasswordHash,
+		},
+		{
+			name:          "plain text",
+			hash:          "bar",
+			password:      "bar",
+			expectedError: errUnsupportedPasswordHash,
+		},
+		{
+			name:          "apache md5",
+			hash:          "$apr1$salt$hash",
+			password:      "bar",
+			expectedError: errUnsupportedPasswordHash,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			observed, err := verifyPassword(tc.hash, tc.password)
+
+			assert.Equal(t, tc.expected, observed)
+			assert.True(t, errors.Is(err, tc.expectedError))
+		})
+	}
+}
+
+func TestCheckPasswordHash(t *testing.T) {
+	t.Parallel()
+
+	assert.NoError(t, checkPasswordHash(testArgon2idHash("bar")))
+	assert.Error(t, checkPasswordHash("$2y$10$short"))
+	assert.True(t, errors.Is(checkPasswordHash("$argon2id$v=19$m=65536,t=0,p=1$c2FsdA$a2V5"), errUnsupportedPasswordHash))
+	assert.True(t, errors.Is(checkPasswordHash("{SHA}Ys23Ag/5IOWqZCw9QGaVDdHwH00="), errUnsupportedPasswordHash))
+}
+
+// testArgon2idHash hashes password with argon2id using cheap parameters.
+func testArgon2idHash(password string) string {
+	salt := []byte("foo-salt")
+	key := argon2.IDKey([]byte(password), salt, 1, 64, 1, 32)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=64,t=1,p=1$%s$%s",
+		argon2.Version,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	)
+}
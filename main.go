@@ -2,9 +2,14 @@ package main
 
 import (
 	"context"
+	"database/sql"
+	"fmt"
 	"log"
+	"net/netip"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"time"
 
 	"go.uber.org/zap"
@@ -14,13 +19,58 @@ import (
 
 	"github.com/go-chi/chi"
 	envars "github.com/netflix/go-env"
+	_ "modernc.org/sqlite"
 )
 
 const gracefullyShutdownTimeout = 5 * time.Second
 
 type config struct {
-	Port   string `env:"PORT,default=8080"`
-	JWTKey string `env:"JWT,default=secret"`
+	Port          string `env:"PORT,default=8080"`
+	JWTKey        string `env:"JWT,default=secret"`
+	SumArithmetic string `env:"SUM_ARITHMETIC,default=exact"`
+	SumStrings    string `env:"SUM_STRINGS,default=ignore"`
+	SumBooleans   string `env:"SUM_BOOLEANS,default=ignore"`
+	SumNulls      string `env:"SUM_NULLS,default=ignore"`
+	DigestAlg     string `env:"DIGEST_ALGORITHM,default=sha256"`
+	DigestEnc     string `env:"DIGEST_ENCODING,default=hex"`
+	DigestHMACKey string `env:"DIGEST_HMAC_KEY"`
+	UsersFile     string `env:"USERS_FILE"`
+	UsersDB       string `env:"USERS_DB"`
+	ClientsFile   string `env:"CLIENTS_FILE"`
+	DefaultScopes string `env:"DEFAULT_SCOPES,default=sum"`
+
+	RefreshTokenTTL time.Duration `env:"REFRESH_TOKEN_TTL,default=24h"`
+	RevocationsFile string        `env:"REVOCATIONS_FILE"`
+	AdminKey        string        `env:"ADMIN_KEY"`
+	PublicURL       string        `env:"PUBLIC_URL"`
+
+	JWTSigningKeyFile       string `env:"JWT_SIGNING_KEY_FILE"`
+	JWTVerificationKeyFiles string `env:"JWT_VERIFICATION_KEY_FILES"`
+	JWTVerifyHMAC           bool   `env:"JWT_VERIFY_HMAC,default=true"`
+
+	JWTIssuer            string        `env:"JWT_ISSUER,default=foo-issuer"`
+	JWTAudience          string        `env:"JWT_AUDIENCE,default=foo-audience"`
+	JWTAcceptedAudiences string        `env:"JWT_ACCEPTED_AUDIENCES"`
+	JWTTTL               time.Duration `env:"JWT_TTL,default=1h"`
+	JWTClientTTLs        string        `env:"JWT_CLIENT_TTLS"`
+	JWTLeeway            time.Duration `env:"JWT_LEEWAY,default=0s"`
+
+	LockoutUserThreshold int           `env:"LOCKOUT_USER_THRESHOLD,default=5"`
+	LockoutIPThreshold   int           `env:"LOCKOUT_IP_THRESHOLD,default=20"`
+	LockoutDuration      time.Duration `env:"LOCKOUT_DURATION,default=30s"`
+	LockoutMaxDuration   time.Duration `env:"LOCKOUT_MAX_DURATION,default=15m"`
+	LockoutWindow        time.Duration `env:"LOCKOUT_WINDOW,default=15m"`
+
+	SumMaxBytes int64 `env:"SUM_MAX_BYTES,default=1048576"`
+	SumMaxDepth int   `env:"SUM_MAX_DEPTH,default=64"`
+	SumMaxNodes int   `env:"SUM_MAX_NODES,default=100000"`
+
+	SumBatchWorkers  int   `env:"SUM_BATCH_WORKERS,default=0"`
+	SumBatchMaxBytes int64 `env:"SUM_BATCH_MAX_BYTES,default=16777216"`
+	SumBatchMaxItems int   `env:"SUM_BATCH_MAX_ITEMS,default=1000"`
+
+	RateLimits     string `env:"RATE_LIMITS,default=/sum=60/1m,/sum/batch=60/1m,/aggregate=60/1m"`
+	TrustedProxies string `env:"TRUSTED_PROXIES"`
 }
 
 func newConfig() *config {
@@ -31,6 +81,143 @@ func newConfig() *config {
 	return &cfg
 }
 
+// newUserStore picks the user store from the configuration.
+// The returned function releases the resources held by the store.
+func newUserStore(cfg *config) (service.UserStore, func(), error) {
+	switch {
+	case cfg.UsersDB != "":
+		db, err := sql.Open("sqlite", cfg.UsersDB)
+		if err != nil {
+			return nil, nil, fmt.Errorf("could not open users database: %w", err)
+		}
+
+		store, err := service.NewSQLiteUserStore(context.Background(), db)
+		if err != nil {
+			db.Close()
+			return nil, nil, err
+		}
+		return store, func() { db.Close() }, nil
+
+	case cfg.UsersFile != "":
+		store, err := service.NewFileUserStore(cfg.UsersFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		return store, func() {}, nil
+
+	default:
+		// Nobody can log in until users are configured.
+		store, err := service.NewMemoryUserStore()
+		return store, func() {}, err
+	}
+}
+
+// newKeySet loads the asymmetric token keys from the configuration, along with the JWT secret
+// as an HS256 verification key unless JWT_VERIFY_HMAC is false.
+// It returns nil when no signing key is configured, leaving the service on HS256 with the JWT secret.
+func newKeySet(cfg *config) (*service.KeySet, error) {
+	if cfg.JWTSigningKeyFile == "" {
+		return nil, nil
+	}
+
+	signing, err := service.LoadSigningKey(cfg.JWTSigningKeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var verification []*service.SigningKey
+	for _, path := range splitList(cfg.JWTVerificationKeyFiles) {
+		key, err := service.LoadSigningKey(path)
+		if err != nil {
+			return nil, err
+		}
+		verification = append(verification, key)
+	}
+
+	// Keep the HS256 tokens signed with the JWT secret valid while moving to asymmetric keys.
+	if cfg.JWTVerifyHMAC {
+		verification = append(verification, service.NewHMACKey(service.HMACKeyID, []byte(cfg.JWTKey)))
+	}
+	return service.NewKeySet(signing, verification...)
+}
+
+// newTokenOptions reads the access token settings from the configuration.
+// JWT_CLIENT_TTLS holds comma-separated client_id=duration pairs, e.g. "batch=15m,dashboard=8h".
+func newTokenOptions(cfg *config) (service.TokenOptions, error) {
+	opts := service.TokenOptions{
+		Issuer:            cfg.JWTIssuer,
+		Audience:          cfg.JWTAudience,
+		AcceptedAudiences: splitList(cfg.JWTAcceptedAudiences),
+		TTL:               cfg.JWTTTL,
+		ClientTTLs:        make(map[string]time.Duration),
+		Leeway:            cfg.JWTLeeway,
+	}
+
+	for _, pair := range splitList(cfg.JWTClientTTLs) {
+		clientID, value, ok := strings.Cut(pair, "=")
+		if !ok || clientID == "" {
+			return opts, fmt.Errorf("could not parse client TTL %q: expected client_id=duration", pair)
+		}
+
+		ttl, err := time.ParseDuration(value)
+		if err != nil {
+			return opts, fmt.Errorf("could not parse TTL of client %q: %w", clientID, err)
+		}
+		opts.ClientTTLs[clientID] = ttl
+	}
+	return opts, nil
+}
+
+// newRESTOptions reads the rate limits and trusted proxies from the configuration.
+// RATE_LIMITS holds comma-separated route=requests/duration items, e.g. "/sum=60/1m,*=600/1m",
+// and TRUSTED_PROXIES comma-separated IP addresses or CIDR prefixes.
+func newRESTOptions(cfg *config) ([]app.Option, error) {
+	var opts []app.Option
+	for _, item := range splitList(cfg.RateLimits) {
+		route, value, ok := strings.Cut(item, "=")
+		requests, per, ok2 := strings.Cut(value, "/")
+		if !ok || !ok2 || route == "" {
+			return nil, fmt.Errorf("could not parse rate limit %q: expected route=requests/duration", item)
+		}
+
+		n, err := strconv.Atoi(requests)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse requests of rate limit %q: %w", item, err)
+		}
+
+		d, err := time.ParseDuration(per)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse duration of rate limit %q: %w", item, err)
+		}
+		opts = append(opts, app.WithRateLimit(route, app.RateLimit{Requests: n, Per: d}))
+	}
+
+	var proxies []netip.Prefix
+	for _, item := range splitList(cfg.TrustedProxies) {
+		prefix, err := netip.ParsePrefix(item)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(item)
+			if addrErr != nil {
+				return nil, fmt.Errorf("could not parse trusted proxy %q: %w", item, err)
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		proxies = append(proxies, prefix)
+	}
+	return append(opts, app.WithTrustedProxies(proxies...)), nil
+}
+
+// splitList splits a comma-separated list, dropping blank items.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 func main() {
 	// Decide on dev or prod log based on env var.
 	// But keeping it simple here.
@@ -42,8 +229,110 @@ func main() {
 
 	cfg := newConfig()
 
-	svc := service.NewDefaultService(logger, []byte(cfg.JWTKey))
-	rest := app.NewRESTApp(logger, cfg.Port, chi.NewRouter(), svc)
+	arithmetic, err := service.ParseArithmetic(cfg.SumArithmetic)
+	if err != nil {
+		logger.Fatal("invalid configuration", zap.Error(err))
+	}
+
+	stringMode, err := service.ParseStringMode(cfg.SumStrings)
+	if err != nil {
+		logger.Fatal("invalid configuration", zap.Error(err))
+	}
+
+	booleans, err := service.ParseLiteralPolicy(cfg.SumBooleans)
+	if err != nil {
+		logger.Fatal("invalid configuration", zap.Error(err))
+	}
+
+	nulls, err := service.ParseLiteralPolicy(cfg.SumNulls)
+	if err != nil {
+		logger.Fatal("invalid configuration", zap.Error(err))
+	}
+
+	digests := service.NewDigestRegistry()
+	if cfg.DigestHMACKey != "" {
+		digests.RegisterAlgorithm(service.HMACSHA256([]byte(cfg.DigestHMACKey)))
+	}
+
+	if err := digests.Check(cfg.DigestAlg, cfg.DigestEnc); err != nil {
+		logger.Fatal("invalid configuration", zap.Error(err))
+	}
+
+	users, closeUsers, err := newUserStore(cfg)
+	if err != nil {
+		logger.Fatal("failed to create user store", zap.Error(err))
+	}
+	defer closeUsers()
+
+	var revocations service.RevocationStore = service.NewMemoryRevocationStore()
+	if cfg.RevocationsFile != "" {
+		if revocations, err = service.NewFileRevocationStore(cfg.RevocationsFile); err != nil {
+			logger.Fatal("failed to create revocation store", zap.Error(err))
+		}
+	}
+
+	opts := []service.Option{
+		service.WithArithmetic(arithmetic),
+		service.WithStringMode(stringMode),
+		service.WithBooleanPolicy(booleans),
+		service.WithNullPolicy(nulls),
+		service.WithDigests(digests, cfg.DigestAlg, cfg.DigestEnc),
+		service.WithSumLimits(service.SumLimits{MaxDepth: cfg.SumMaxDepth, MaxNodes: cfg.SumMaxNodes}),
+		service.WithUserStore(users),
+		service.WithDefaultScopes(service.ParseScopes(cfg.DefaultScopes)...),
+		service.WithRefreshTokens(service.NewMemoryRefreshTokenStore(), cfg.RefreshTokenTTL),
+		service.WithRevocationStore(revocations),
+		service.WithLockout(service.LockoutOptions{
+			UserThreshold: cfg.LockoutUserThreshold,
+			IPThreshold:   cfg.LockoutIPThreshold,
+			Lockout:       cfg.LockoutDuration,
+			MaxLockout:    cfg.LockoutMaxDuration,
+			Window:        cfg.LockoutWindow,
+		}, service.NewMemoryAttemptStore()),
+	}
+
+	// Batches are summed with one worker per CPU unless configured otherwise.
+	if cfg.SumBatchWorkers > 0 {
+		opts = append(opts, service.WithBatchWorkers(cfg.SumBatchWorkers))
+	}
+
+	// Without clients the OAuth token endpoint rejects every request.
+	if cfg.ClientsFile != "" {
+		clients, err := service.NewFileClientStore(cfg.ClientsFile)
+		if err != nil {
+			logger.Fatal("failed to create client store", zap.Error(err))
+		}
+		opts = append(opts, service.WithClientStore(clients))
+	}
+
+	tokenOpts, err := newTokenOptions(cfg)
+	if err != nil {
+		logger.Fatal("invalid configuration", zap.Error(err))
+	}
+	opts = append(opts, service.WithTokenOptions(tokenOpts))
+
+	keys, err := newKeySet(cfg)
+	if err != nil {
+		logger.Fatal("failed to load token keys", zap.Error(err))
+	}
+	if keys != nil {
+		opts = append(opts, service.WithKeySet(keys))
+	}
+
+	svc := service.NewDefaultService(logger, []byte(cfg.JWTKey), opts...)
+	restOpts, err := newRESTOptions(cfg)
+	if err != nil {
+		logger.Fatal("invalid configuration", zap.Error(err))
+	}
+
+	restOpts = append(restOpts,
+		app.WithAdminKey(cfg.AdminKey),
+		app.WithBaseURL(cfg.PublicURL),
+		app.WithMaxSumBytes(cfg.SumMaxBytes),
+		app.WithMaxBatchBytes(cfg.SumBatchMaxBytes),
+		app.WithMaxBatchItems(cfg.SumBatchMaxItems),
+	)
+	rest := app.NewRESTApp(logger, cfg.Port, chi.NewRouter(), svc, restOpts...)
 
 	go func() {
 		if err := rest.Start(); err != nil {